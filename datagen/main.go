@@ -0,0 +1,63 @@
+// datagen generates a synthetic listing dataset and writes it to a sqlite DB
+// or to a protobuf or JSON file.
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tony-yang/realtor-tracker/indexer/generator"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+var (
+	config = flag.String("config", "", "The JSON config of the generated regions and timeline, the Windsor area without it")
+	// seed and listings override the config when set.
+	seed     = flag.Int64("seed", 0, "The seed of the generated listings")
+	listings = flag.Int("listings", 0, "The number of listings to generate")
+	dbPath   = flag.String("db_path", "", "The sqlite DB to save the listings to")
+	out      = flag.String("out", "", "The file to write the listings to, JSON when it ends with .json and protobuf otherwise")
+)
+
+func main() {
+	flag.Parse()
+	if *dbPath == "" && *out == "" {
+		logrus.Fatal("Set -db_path or -out to write the listings to")
+	}
+
+	c := generator.DefaultConfig
+	if *config != "" {
+		var err error
+		if c, err = generator.LoadConfig(*config); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if *seed != 0 {
+		c.Seed = *seed
+	}
+	if *listings > 0 {
+		c.Listings = *listings
+	}
+
+	generated, err := generator.Generate(c)
+	if err != nil {
+		logrus.Fatalf("Failed to generate the listings: %v", err)
+	}
+	if *out != "" {
+		if err := generator.WriteFile(*out, generated); err != nil {
+			logrus.Fatalf("Failed to write %q: %v", *out, err)
+		}
+		logrus.Infof("Wrote %d listings to %q", len(generated), *out)
+	}
+	if *dbPath != "" {
+		db, err := storage.NewSqliteDB(*dbPath)
+		if err != nil {
+			logrus.Fatalf("Failed to open %q: %v", *dbPath, err)
+		}
+		if err := generator.Save(context.Background(), db, generated, c.End()); err != nil {
+			logrus.Fatalf("Failed to save the listings: %v", err)
+		}
+		logrus.Infof("Saved %d listings to %q", len(generated), *dbPath)
+	}
+}
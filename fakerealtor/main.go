@@ -0,0 +1,53 @@
+// fakerealtor is a local stand-in of the realtor.ca search, it serves a
+// synthetic or fixture dataset that changes over simulated days so the
+// collectors can run without the live endpoint.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tony-yang/realtor-tracker/fakerealtor/server"
+)
+
+var (
+	port    = flag.Int("port", 9090, "The port to serve the search on")
+	dataset = flag.String("dataset", "", "The JSON dataset to serve, a synthetic one is built without it")
+	// The synthetic dataset is placed in the default MLS Canada region.
+	listings   = flag.Int("listings", 500, "The number of synthetic listings")
+	seed       = flag.Int64("seed", 1, "The seed of the synthetic listings")
+	days       = flag.Int("days", 90, "The number of simulated days the synthetic listings are listed over")
+	startDay   = flag.Int("start_day", 30, "The simulated day to start on")
+	maxRecords = flag.Int("max_records", server.DefaultMaxRecords, "The number of results served at most for a search")
+	// dayLength advances the simulated day on its own, the clock endpoint
+	// advances it otherwise.
+	dayLength = flag.Duration("day_length", 0, "The real time of a simulated day, 0 only advances it on request")
+)
+
+func main() {
+	flag.Parse()
+
+	d := server.Synthetic(*listings, *seed, server.Windsor, *days)
+	if *dataset != "" {
+		var err error
+		if d, err = server.LoadDataset(*dataset); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	s := server.New(d, *startDay)
+	s.MaxRecords = *maxRecords
+
+	if *dayLength > 0 {
+		go func() {
+			for range time.Tick(*dayLength) {
+				s.Advance(1)
+			}
+		}()
+	}
+
+	logrus.Infof("Serving %d listings on :%d%s from day %d", len(d.Listings), *port, server.SearchPath, *startDay)
+	logrus.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), s))
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"strconv"
+)
+
+// PricePoint is the asking price of a listing from a simulated day on.
+type PricePoint struct {
+	Day   int `json:"day"`
+	Price int `json:"price"`
+}
+
+// Listing is a listing of the dataset with its timeline, it is listed on
+// ListedDay and removed on RemovedDay, or never when RemovedDay is 0.
+type Listing struct {
+	MlsNumber     string       `json:"mls_number"`
+	ID            string       `json:"id"`
+	PublicRemarks string       `json:"public_remarks"`
+	Bathrooms     string       `json:"bathrooms"`
+	Bedrooms      string       `json:"bedrooms"`
+	Stories       string       `json:"stories"`
+	BuildingType  string       `json:"building_type"`
+	PropertyType  string       `json:"property_type"`
+	Address       string       `json:"address"`
+	PostalCode    string       `json:"postal_code"`
+	LandSize      string       `json:"land_size"`
+	Latitude      float64      `json:"latitude"`
+	Longitude     float64      `json:"longitude"`
+	Parking       []string     `json:"parking"`
+	Photos        []string     `json:"photos"`
+	ListedDay     int          `json:"listed_day"`
+	RemovedDay    int          `json:"removed_day"`
+	Prices        []PricePoint `json:"prices"`
+}
+
+// Active reports whether the listing is on the market on the day.
+func (l *Listing) Active(day int) bool {
+	return l.ListedDay <= day && (l.RemovedDay == 0 || day < l.RemovedDay)
+}
+
+// PriceAt returns the asking price of the listing on the day.
+func (l *Listing) PriceAt(day int) int {
+	price := 0
+	for _, p := range l.Prices {
+		if p.Day <= day {
+			price = p.Price
+		}
+	}
+	return price
+}
+
+// Dataset is the listings served by the stand-in.
+type Dataset struct {
+	Listings []*Listing `json:"listings"`
+}
+
+// LoadDataset reads a JSON dataset file.
+func LoadDataset(path string) (*Dataset, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the dataset: %v", err)
+	}
+	var d *Dataset
+	if err := json.Unmarshal(content, &d); err != nil {
+		return nil, fmt.Errorf("failed to parse the dataset %q: %v", path, err)
+	}
+	return d, nil
+}
+
+// Bounds is a latitude and longitude box.
+type Bounds struct {
+	LatitudeMin  float64
+	LatitudeMax  float64
+	LongitudeMin float64
+	LongitudeMax float64
+}
+
+// Contains reports whether the coordinates are inside the box.
+func (b Bounds) Contains(latitude, longitude float64) bool {
+	return latitude >= b.LatitudeMin && latitude <= b.LatitudeMax &&
+		longitude >= b.LongitudeMin && longitude <= b.LongitudeMax
+}
+
+var (
+	// Windsor is the area of the default MLS Canada region.
+	Windsor = Bounds{LatitudeMin: 41.9947561, LatitudeMax: 42.3661983, LongitudeMin: -83.1245969, LongitudeMax: -82.4784635}
+
+	streets = []string{"OUELLETTE AVENUE", "WYANDOTTE STREET E", "HOWARD AVENUE", "DOUGALL AVENUE", "TECUMSEH ROAD E", "WALKER ROAD", "RIVERSIDE DRIVE E", "CALIFORNIA AVENUE"}
+	remarks = []string{
+		"Well kept family home close to schools and parks.",
+		"Renovated kitchen and bath, detached garage.",
+		"Handyman special, sold as is.",
+		"Bright bungalow with an inground pool.",
+		"Two storey home with an in law suite in the basement.",
+	}
+	buildingTypes = []string{"House", "House", "House", "Row / Townhouse", "Apartment"}
+)
+
+// Synthetic builds n listings inside the bounds over a timeline of days. A
+// listing is listed on a random day, it may drop its price and be removed
+// later. The same seed builds the same dataset.
+func Synthetic(n int, seed int64, bounds Bounds, days int) *Dataset {
+	r := rand.New(rand.NewSource(seed))
+	d := &Dataset{}
+	for i := 0; i < n; i++ {
+		id := 30000000 + i
+		price := 150000 + r.Intn(60)*10000
+		l := &Listing{
+			MlsNumber:     strconv.Itoa(20000000 + i),
+			ID:            strconv.Itoa(id),
+			PublicRemarks: remarks[r.Intn(len(remarks))],
+			Bathrooms:     strconv.Itoa(1 + r.Intn(3)),
+			Bedrooms:      fmt.Sprintf("%d + %d", 1+r.Intn(4), r.Intn(2)),
+			Stories:       []string{"1", "1.5", "2"}[r.Intn(3)],
+			BuildingType:  buildingTypes[r.Intn(len(buildingTypes))],
+			PropertyType:  "Single Family",
+			Address:       fmt.Sprintf("%d %s|Windsor, Ontario N9A%dB%d", 100+r.Intn(3900), streets[r.Intn(len(streets))], r.Intn(10), r.Intn(10)),
+			LandSize:      fmt.Sprintf("%d X %d FT", 30+r.Intn(30), 100+r.Intn(50)),
+			Latitude:      bounds.LatitudeMin + r.Float64()*(bounds.LatitudeMax-bounds.LatitudeMin),
+			Longitude:     bounds.LongitudeMin + r.Float64()*(bounds.LongitudeMax-bounds.LongitudeMin),
+			Parking:       []string{[]string{"Attached garage", "Detached garage", "Gravel", "Street"}[r.Intn(4)]},
+			Photos:        []string{fmt.Sprintf("%s%d.png", PhotoPath, id)},
+			ListedDay:     r.Intn(days + 1),
+			Prices:        []PricePoint{},
+		}
+		l.PostalCode = l.Address[len(l.Address)-6:]
+		l.Prices = append(l.Prices, PricePoint{Day: l.ListedDay, Price: price})
+		if r.Intn(3) == 0 {
+			// A third of the listings drop their price once.
+			drop := PricePoint{Day: l.ListedDay + 1 + r.Intn(30), Price: price - price/20}
+			l.Prices = append(l.Prices, drop)
+		}
+		if r.Intn(4) == 0 {
+			l.RemovedDay = l.ListedDay + 1 + r.Intn(60)
+		}
+		d.Listings = append(d.Listings, l)
+	}
+	return d
+}
@@ -0,0 +1,304 @@
+// Package server implements a stand-in of the realtor.ca PropertySearch_Post
+// endpoint, it serves a dataset whose listings change over simulated days.
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// SearchPath is the path of the search endpoint, as on realtor.ca.
+	SearchPath = "/Listing.svc/PropertySearch_Post"
+	// ClockPath reads the simulated day, a POST with the advance query moves
+	// it forward.
+	ClockPath = "/_clock"
+	// PhotoPath serves a generated photo for the relative photo paths of the
+	// dataset.
+	PhotoPath = "/photos/"
+
+	// DefaultMaxRecords is the number of results realtor.ca serves at most
+	// for a search, the listings past it are counted but not served.
+	DefaultMaxRecords = 600
+	maxRecordsPerPage = 200
+)
+
+// Server serves the active listings of Dataset on the simulated Day.
+type Server struct {
+	Dataset    *Dataset
+	MaxRecords int
+
+	lock sync.Mutex
+	day  int
+}
+
+// New creates a server of the dataset starting on day.
+func New(d *Dataset, day int) *Server {
+	return &Server{
+		Dataset:    d,
+		MaxRecords: DefaultMaxRecords,
+		day:        day,
+	}
+}
+
+// Day returns the simulated day.
+func (s *Server) Day() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.day
+}
+
+// Advance moves the simulated day forward.
+func (s *Server) Advance(days int) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.day += days
+	logrus.Infof("Simulated day %d", s.day)
+	return s.day
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case SearchPath:
+		s.search(w, r)
+	case ClockPath:
+		s.clock(w, r)
+	default:
+		if strings.HasPrefix(r.URL.Path, PhotoPath) {
+			s.photo(w, r)
+			return
+		}
+		http.Error(w, "Not Found", http.StatusNotFound)
+	}
+}
+
+func (s *Server) clock(w http.ResponseWriter, r *http.Request) {
+	day := s.Day()
+	if r.Method == http.MethodPost {
+		days, err := strconv.Atoi(r.FormValue("advance"))
+		if err != nil || days < 0 {
+			http.Error(w, "advance must be a number of days", http.StatusBadRequest)
+			return
+		}
+		day = s.Advance(days)
+	}
+	writeJSON(w, map[string]int{"day": day})
+}
+
+type searchForm struct {
+	bounds         Bounds
+	currentPage    int
+	recordsPerPage int
+	sort           string
+}
+
+func parseSearchForm(r *http.Request) (*searchForm, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+	f := &searchForm{currentPage: 1, recordsPerPage: 10, sort: r.PostFormValue("Sort")}
+	coordinates := []struct {
+		name  string
+		value *float64
+	}{
+		{"LatitudeMin", &f.bounds.LatitudeMin},
+		{"LatitudeMax", &f.bounds.LatitudeMax},
+		{"LongitudeMin", &f.bounds.LongitudeMin},
+		{"LongitudeMax", &f.bounds.LongitudeMax},
+	}
+	for _, c := range coordinates {
+		v, err := strconv.ParseFloat(r.PostFormValue(c.name), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", c.name, r.PostFormValue(c.name))
+		}
+		*c.value = v
+	}
+	if v := r.PostFormValue("CurrentPage"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return nil, fmt.Errorf("invalid CurrentPage %q", v)
+		}
+		f.currentPage = page
+	}
+	if v := r.PostFormValue("RecordsPerPage"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid RecordsPerPage %q", v)
+		}
+		if n > maxRecordsPerPage {
+			n = maxRecordsPerPage
+		}
+		f.recordsPerPage = n
+	}
+	if f.sort == "" {
+		f.sort = "6-D"
+	}
+	return f, nil
+}
+
+// sortListings sorts by the realtor.ca sort keys, 6 is the listing date and 1
+// the price, A and D are ascending and descending.
+func sortListings(listings []*Listing, key string, day int) error {
+	var less func(a, b *Listing) bool
+	switch key {
+	case "6-D":
+		less = func(a, b *Listing) bool { return a.ListedDay > b.ListedDay }
+	case "6-A":
+		less = func(a, b *Listing) bool { return a.ListedDay < b.ListedDay }
+	case "1-D":
+		less = func(a, b *Listing) bool { return a.PriceAt(day) > b.PriceAt(day) }
+	case "1-A":
+		less = func(a, b *Listing) bool { return a.PriceAt(day) < b.PriceAt(day) }
+	default:
+		return fmt.Errorf("invalid Sort %q", key)
+	}
+	sort.SliceStable(listings, func(i, j int) bool {
+		if less(listings[i], listings[j]) {
+			return true
+		}
+		if less(listings[j], listings[i]) {
+			return false
+		}
+		return listings[i].MlsNumber < listings[j].MlsNumber
+	})
+	return nil
+}
+
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST Method Required", http.StatusMethodNotAllowed)
+		return
+	}
+	f, err := parseSearchForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	day := s.Day()
+	found := []*Listing{}
+	for _, l := range s.Dataset.Listings {
+		if l.Active(day) && f.bounds.Contains(l.Latitude, l.Longitude) {
+			found = append(found, l)
+		}
+	}
+	if err := sortListings(found, f.sort, day); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	showing := len(found)
+	if showing > s.MaxRecords {
+		showing = s.MaxRecords
+	}
+	totalPages := (showing + f.recordsPerPage - 1) / f.recordsPerPage
+	results := []interface{}{}
+	base := "http://" + r.Host
+	for i := (f.currentPage - 1) * f.recordsPerPage; i < showing && i < f.currentPage*f.recordsPerPage; i++ {
+		results = append(results, result(found[i], day, base))
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"ErrorCode": map[string]interface{}{"Id": 200, "Description": "Success - OK"},
+		"Paging": map[string]interface{}{
+			"RecordsPerPage": f.recordsPerPage,
+			"CurrentPage":    f.currentPage,
+			"TotalRecords":   len(found),
+			"MaxRecords":     s.MaxRecords,
+			"TotalPages":     totalPages,
+			"RecordsShowing": showing,
+		},
+		"Results": results,
+	})
+}
+
+// result formats a listing as a realtor.ca search result, the relative photo
+// paths are served from base.
+func result(l *Listing, day int, base string) map[string]interface{} {
+	photos := []map[string]string{}
+	for i, p := range l.Photos {
+		if strings.HasPrefix(p, "/") {
+			p = base + p
+		}
+		photos = append(photos, map[string]string{"SequenceId": strconv.Itoa(i + 1), "HighResPath": p})
+	}
+	parking := []map[string]string{}
+	for _, p := range l.Parking {
+		parking = append(parking, map[string]string{"Name": p})
+	}
+	return map[string]interface{}{
+		"Id":            l.ID,
+		"MlsNumber":     l.MlsNumber,
+		"PublicRemarks": l.PublicRemarks,
+		"Building": map[string]string{
+			"BathroomTotal": l.Bathrooms,
+			"Bedrooms":      l.Bedrooms,
+			"StoriesTotal":  l.Stories,
+			"Type":          l.BuildingType,
+		},
+		"Property": map[string]interface{}{
+			"Price": formatPrice(l.PriceAt(day)),
+			"Type":  l.PropertyType,
+			"Address": map[string]string{
+				"AddressText": l.Address,
+				"Latitude":    strconv.FormatFloat(l.Latitude, 'f', 7, 64),
+				"Longitude":   strconv.FormatFloat(l.Longitude, 'f', 7, 64),
+			},
+			"Photo":   photos,
+			"Parking": parking,
+		},
+		"Land":               map[string]string{"SizeTotal": l.LandSize},
+		"PostalCode":         l.PostalCode,
+		"RelativeDetailsURL": fmt.Sprintf("/real-estate/%s/listing", l.ID),
+		"StatusId":           "1",
+	}
+}
+
+// formatPrice formats a price as realtor.ca does, ie. $449,900.
+func formatPrice(price int) string {
+	digits := strconv.Itoa(price)
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	groups = append([]string{digits}, groups...)
+	return "$" + strings.Join(groups, ",")
+}
+
+// photo serves a gradient image that differs by photo path, so the photo
+// hashes of the listings differ.
+func (s *Server) photo(w http.ResponseWriter, r *http.Request) {
+	h := fnv.New32a()
+	h.Write([]byte(r.URL.Path))
+	seed := h.Sum32()
+
+	img := image.NewGray(image.Rect(0, 0, 90, 80))
+	for x := 0; x < 90; x++ {
+		for y := 0; y < 80; y++ {
+			img.SetGray(x, y, color.Gray{Y: uint8(uint32(x*int(seed%7+1)+y*int(seed%5+1)) + seed)})
+		}
+	}
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, img); err != nil {
+		logrus.Errorf("Failed to write the photo: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Errorf("Failed to write the response: %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tony-yang/realtor-tracker/indexer/collector"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+type searchResponse struct {
+	Paging struct {
+		CurrentPage    int
+		TotalRecords   int
+		TotalPages     int
+		RecordsShowing int
+	}
+	Results []struct {
+		MlsNumber string
+		Property  struct{ Price string }
+	}
+}
+
+func testDataset() *Dataset {
+	return &Dataset{Listings: []*Listing{
+		{MlsNumber: "1", Latitude: 42.1, Longitude: -83, ListedDay: 0, Prices: []PricePoint{{Day: 0, Price: 300000}, {Day: 5, Price: 285000}}},
+		{MlsNumber: "2", Latitude: 42.2, Longitude: -83, ListedDay: 2, Prices: []PricePoint{{Day: 2, Price: 450000}}},
+		{MlsNumber: "3", Latitude: 42.3, Longitude: -83, ListedDay: 1, RemovedDay: 4, Prices: []PricePoint{{Day: 1, Price: 1250000}}},
+		{MlsNumber: "4", Latitude: 45.5, Longitude: -73.5, ListedDay: 0, Prices: []PricePoint{{Day: 0, Price: 500000}}},
+		{MlsNumber: "5", Latitude: 42.1, Longitude: -83, ListedDay: 10, Prices: []PricePoint{{Day: 10, Price: 200000}}},
+	}}
+}
+
+func search(t *testing.T, s *Server, form url.Values) *searchResponse {
+	t.Helper()
+	for k, v := range map[string]string{
+		"LatitudeMin": "42", "LatitudeMax": "42.5", "LongitudeMin": "-83.5", "LongitudeMax": "-82.5",
+	} {
+		if form.Get(k) == "" {
+			form.Set(k, v)
+		}
+	}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	resp, err := http.PostForm(srv.URL+SearchPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d", resp.StatusCode)
+	}
+	var r *searchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func mlsNumbers(r *searchResponse) string {
+	numbers := ""
+	for _, l := range r.Results {
+		numbers += l.MlsNumber
+	}
+	return numbers
+}
+
+func TestSearch(t *testing.T) {
+	t.Run("serve the active listings of the box newest first", func(t *testing.T) {
+		r := search(t, New(testDataset(), 3), url.Values{})
+		if got := mlsNumbers(r); got != "231" {
+			t.Errorf("got listings %q, want %q", got, "231")
+		}
+	})
+
+	t.Run("can page the results", func(t *testing.T) {
+		r := search(t, New(testDataset(), 3), url.Values{"RecordsPerPage": {"2"}, "CurrentPage": {"2"}})
+		if got := mlsNumbers(r); got != "1" || r.Paging.TotalPages != 2 || r.Paging.TotalRecords != 3 {
+			t.Errorf("got listings %q and paging %+v", got, r.Paging)
+		}
+	})
+
+	t.Run("cap the results served", func(t *testing.T) {
+		s := New(testDataset(), 3)
+		s.MaxRecords = 2
+		r := search(t, s, url.Values{"RecordsPerPage": {"1"}, "CurrentPage": {"3"}})
+		if len(r.Results) != 0 || r.Paging.TotalRecords != 3 || r.Paging.RecordsShowing != 2 || r.Paging.TotalPages != 2 {
+			t.Errorf("got %d results and paging %+v", len(r.Results), r.Paging)
+		}
+	})
+
+	t.Run("can sort by price", func(t *testing.T) {
+		r := search(t, New(testDataset(), 3), url.Values{"Sort": {"1-D"}})
+		if got := mlsNumbers(r); got != "321" {
+			t.Errorf("got listings %q, want %q", got, "321")
+		}
+		if price := r.Results[0].Property.Price; price != "$1,250,000" {
+			t.Errorf("got price %q, want $1,250,000", price)
+		}
+	})
+
+	t.Run("change the listings over simulated time", func(t *testing.T) {
+		s := New(testDataset(), 3)
+		s.Advance(7)
+		r := search(t, s, url.Values{"Sort": {"1-A"}})
+		if got := mlsNumbers(r); got != "512" || r.Results[1].Property.Price != "$285,000" {
+			t.Errorf("unexpected results %+v", r.Results)
+		}
+	})
+
+	t.Run("reject an invalid search", func(t *testing.T) {
+		srv := httptest.NewServer(New(testDataset(), 0))
+		defer srv.Close()
+		resp, err := http.PostForm(srv.URL+SearchPath, url.Values{"LatitudeMin": {"north"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("got status %d, want 400", resp.StatusCode)
+		}
+	})
+}
+
+func TestClock(t *testing.T) {
+	t.Run("can advance the simulated day", func(t *testing.T) {
+		srv := httptest.NewServer(New(testDataset(), 3))
+		defer srv.Close()
+		resp, err := http.PostForm(srv.URL+ClockPath, url.Values{"advance": {"2"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var clock map[string]int
+		json.NewDecoder(resp.Body).Decode(&clock)
+		if clock["day"] != 5 {
+			t.Errorf("got day %d, want 5", clock["day"])
+		}
+	})
+}
+
+func TestSynthetic(t *testing.T) {
+	t.Run("build the same listings inside the bounds from a seed", func(t *testing.T) {
+		a, b := Synthetic(50, 7, Windsor, 30), Synthetic(50, 7, Windsor, 30)
+		for i, l := range a.Listings {
+			if !Windsor.Contains(l.Latitude, l.Longitude) {
+				t.Errorf("listing %s is outside the bounds", l.MlsNumber)
+			}
+			if l.PriceAt(l.ListedDay) <= 0 || l.Address != b.Listings[i].Address {
+				t.Errorf("unexpected listing %+v", l)
+			}
+		}
+	})
+}
+
+func TestCollector(t *testing.T) {
+	t.Run("can run the collector against the stand-in", func(t *testing.T) {
+		s := New(Synthetic(40, 3, Windsor, 30), 30)
+		srv := httptest.NewServer(s)
+		defer srv.Close()
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := collector.NewMls(mDB, nil)
+		m.URL = srv.URL + SearchPath
+		m.RecordsPerPage = 7
+
+		active := func(day int) map[string]int {
+			prices := map[string]int{}
+			for _, l := range s.Dataset.Listings {
+				if l.Active(day) {
+					prices[l.MlsNumber] = l.PriceAt(day)
+				}
+			}
+			return prices
+		}
+		check := func(day int) {
+			t.Helper()
+			want := active(day)
+			savedListings, _ := mDB.ReadListings(context.Background())
+			open := 0
+			for _, p := range savedListings.Property {
+				if p.Status != "Open" {
+					if _, ok := want[p.MlsNumber]; ok {
+						t.Errorf("day %d: listing %s is %s, want Open", day, p.MlsNumber, p.Status)
+					}
+					continue
+				}
+				open++
+				price := p.Price[len(p.Price)-1].Price
+				if want[p.MlsNumber] != int(price) {
+					t.Errorf("day %d: listing %s has price %d, want %d", day, p.MlsNumber, price, want[p.MlsNumber])
+				}
+			}
+			if open != len(want) {
+				t.Errorf("day %d: got %d open listings, want %d", day, open, len(want))
+			}
+		}
+
+		if reflect.DeepEqual(active(30), active(50)) {
+			t.Fatal("expected the listings to change between the runs")
+		}
+
+		m.FetchListing(context.Background())
+		check(30)
+		if len(mDB.PhotoHash) == 0 {
+			t.Error("expected the photos of the stand-in to be hashed")
+		}
+		// Runs delist the listings not seen since their start second.
+		time.Sleep(time.Second)
+		s.Advance(20)
+		m.FetchListing(context.Background())
+		check(50)
+	})
+}
@@ -1,9 +1,10 @@
 module github.com/tony-yang/realtor-tracker
 
-go 1.12
+go 1.13
 
 require (
 	github.com/golang/protobuf v1.3.1
+	github.com/lib/pq v1.10.9
 	github.com/mattn/go-sqlite3 v1.10.0
 	github.com/sirupsen/logrus v1.4.2
 	google.golang.org/grpc v1.21.1
@@ -0,0 +1,219 @@
+// package cassette records the HTTP requests of a client with their responses
+// to a file, and replays them later so the collectors run offline.
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Mode is whether a cassette records or replays the interactions.
+type Mode int
+
+const (
+	// Replay serves the recorded responses and fails the unknown requests.
+	Replay Mode = iota
+	// Record sends the requests and records them with their responses.
+	Record
+)
+
+// ModeEnv is the environment variable that switches the cassettes of the
+// tests to Record when set to "record".
+const ModeEnv = "CASSETTE_MODE"
+
+// ModeFromEnv returns the mode set by ModeEnv, Replay by default.
+func ModeFromEnv() Mode {
+	if os.Getenv(ModeEnv) == "record" {
+		return Record
+	}
+	return Replay
+}
+
+// Request is the part of a request an interaction is matched on.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Form is the url encoded form body, with the fields sorted.
+	Form string `json:"form,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// Interaction is a request with its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is an http.RoundTripper that records or replays interactions.
+// Each recorded interaction is replayed once, in the order it was recorded,
+// so a repeated request gets the next response.
+type Cassette struct {
+	Path         string
+	Mode         Mode
+	Interactions []*Interaction
+
+	transport http.RoundTripper
+	lock      sync.Mutex
+	played    []bool
+}
+
+// New loads the cassette at path in Replay mode, or starts an empty one in
+// Record mode. transport sends the recorded requests, it defaults to
+// http.DefaultTransport.
+func New(path string, mode Mode, transport http.RoundTripper) (*Cassette, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	c := &Cassette{
+		Path:      path,
+		Mode:      mode,
+		transport: transport,
+	}
+	if mode == Record {
+		return c, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the cassette: %v", err)
+	}
+	if err := json.Unmarshal(content, &c.Interactions); err != nil {
+		return nil, fmt.Errorf("failed to parse the cassette %q: %v", path, err)
+	}
+	c.played = make([]bool, len(c.Interactions))
+	return c, nil
+}
+
+// Client returns a client sending its requests through the cassette.
+func (c *Cassette) Client() *http.Client {
+	return &http.Client{Transport: c}
+}
+
+// RoundTrip records or replays the request.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	r, err := newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.Mode == Record {
+		return c.record(req, r)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, interaction := range c.Interactions {
+		if !c.played[i] && interaction.Request == r {
+			c.played[i] = true
+			return interaction.Response.httpResponse(req), nil
+		}
+	}
+	return nil, fmt.Errorf("no interaction recorded for %s %s %s", r.Method, r.URL, r.Form)
+}
+
+func (c *Cassette) record(req *http.Request, r Request) (*http.Response, error) {
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response of %s: %v", r.URL, err)
+	}
+
+	interaction := &Interaction{
+		Request: r,
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       string(body),
+		},
+	}
+	c.lock.Lock()
+	c.Interactions = append(c.Interactions, interaction)
+	c.played = append(c.played, true)
+	c.lock.Unlock()
+	return interaction.Response.httpResponse(req), nil
+}
+
+// Save writes the recorded interactions to Path, it does nothing in Replay
+// mode.
+func (c *Cassette) Save() error {
+	if c.Mode != Record {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// Keep the forms and bodies readable, the cassettes are reviewed as
+	// testdata.
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(c.Interactions); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Path, content.Bytes(), 0644)
+}
+
+// newRequest reads the part of req to match on, the body is left for the
+// transport to read.
+func newRequest(req *http.Request) (Request, error) {
+	r := Request{Method: req.Method, URL: req.URL.String()}
+	if req.Body == nil {
+		return r, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return r, fmt.Errorf("failed to read the request body: %v", err)
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 {
+		return r, nil
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		// Match a body that is not a form as is.
+		r.Form = string(body)
+		return r, nil
+	}
+	r.Form = form.Encode()
+	return r, nil
+}
+
+func (r Response) httpResponse(req *http.Request) *http.Response {
+	header := r.Header
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.StatusCode, http.StatusText(r.StatusCode)),
+		StatusCode:    r.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewBufferString(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,96 @@
+package cassette
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cassette")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprintf(w, "page %s of %s, request %d", r.FormValue("CurrentPage"), r.URL.Path, requests)
+	}))
+	defer server.Close()
+
+	get := func(t *testing.T, c *http.Client, page string) string {
+		t.Helper()
+		// The fields are sent out of order to match on the sorted form.
+		resp, err := c.Post(server.URL+"/search", "application/x-www-form-urlencoded",
+			strings.NewReader("Sort=6-D&CurrentPage="+url.QueryEscape(page)))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	path := filepath.Join(dir, "testdata", "search.json")
+	t.Run("can record the interactions", func(t *testing.T) {
+		c, err := New(path, Record, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, page := range []string{"1", "2", "1"} {
+			get(t, c.Client(), page)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+		if len(c.Interactions) != 3 || requests != 3 {
+			t.Errorf("expected 3 recorded requests, got %d interactions and %d requests", len(c.Interactions), requests)
+		}
+	})
+
+	t.Run("can replay the interactions in order", func(t *testing.T) {
+		c, err := New(path, Replay, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"2": "page 2 of /search, request 2",
+			"1": "page 1 of /search, request 1",
+		}
+		for _, page := range []string{"2", "1"} {
+			if got := get(t, c.Client(), page); got != want[page] {
+				t.Errorf("got %q, want %q", got, want[page])
+			}
+		}
+		if got := get(t, c.Client(), "1"); got != "page 1 of /search, request 3" {
+			t.Errorf("expected the repeated request to get the next response, got %q", got)
+		}
+		if requests != 3 {
+			t.Errorf("expected no request to the server, got %d", requests-3)
+		}
+	})
+
+	t.Run("fail a request not recorded", func(t *testing.T) {
+		c, err := New(path, Replay, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Client().Get(server.URL + "/unknown"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("fail a missing cassette", func(t *testing.T) {
+		if _, err := New(filepath.Join(dir, "missing.json"), Replay, nil); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
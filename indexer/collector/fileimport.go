@@ -0,0 +1,338 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+const (
+	// reportSuffix is appended to an imported file name for its report.
+	reportSuffix = ".report.json"
+	// importBatchSize is the number of listings saved per transaction.
+	importBatchSize = 500
+)
+
+// ImportMapping maps the columns of a listing dump onto mlspb.Property. A
+// CSV column is read by its header, a JSON value by its dotted path.
+type ImportMapping struct {
+	// Source is the name the collector is registered and saved under.
+	Source string               `json:"source"`
+	Fields map[string]FieldSpec `json:"fields"`
+}
+
+// LoadImportMapping reads a JSON column mapping.
+func LoadImportMapping(path string) (*ImportMapping, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import mapping %q: %v", path, err)
+	}
+	var m *ImportMapping
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse import mapping %q: %v", path, err)
+	}
+	if err := m.spec().validate(); err != nil {
+		return nil, fmt.Errorf("invalid import mapping %q: %v", path, err)
+	}
+	return m, nil
+}
+
+// spec reuses the declarative spec mapping for the rows of a file.
+func (m *ImportMapping) spec() *Spec {
+	return &Spec{Source: m.Source, URL: "file", Fields: m.Fields}
+}
+
+// ImportRow is the outcome of importing one row, rows are numbered from 1
+// excluding the CSV header.
+type ImportRow struct {
+	Row       int    `json:"row"`
+	MlsNumber string `json:"mls_number,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ImportReport is written next to every imported file. A file whose checksum
+// matches its report is not imported again.
+type ImportReport struct {
+	File       string      `json:"file"`
+	Checksum   string      `json:"checksum"`
+	ImportedAt int64       `json:"imported_at"`
+	Accepted   []ImportRow `json:"accepted"`
+	Rejected   []ImportRow `json:"rejected"`
+}
+
+// FileImport imports CSV, JSON Lines and JSON listing dumps dropped in a
+// watched directory.
+type FileImport struct {
+	DB      storage.DBInterface
+	Dir     string
+	Mapping *ImportMapping
+	log     logrus.FieldLogger
+}
+
+// fileImportOptions are the config options of the file import collector.
+type fileImportOptions struct {
+	Dir     string `json:"dir"`
+	Mapping string `json:"mapping"`
+}
+
+func newFileImportCollector(config Config, deps Deps) (Collector, error) {
+	var options fileImportOptions
+	if err := decodeOptions(config, &options); err != nil {
+		return nil, err
+	}
+	if options.Dir == "" {
+		return nil, fmt.Errorf("missing dir")
+	}
+	m, err := LoadImportMapping(options.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	f := NewFileImport(options.Dir, m, deps.DB)
+	f.log = deps.Logger
+	return f, nil
+}
+
+// ImportConfigs returns the config of a file import collector for dir, named
+// after the source of the mapping.
+func ImportConfigs(dir, mapping string) ([]Config, error) {
+	m, err := LoadImportMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+	options, err := json.Marshal(fileImportOptions{Dir: dir, Mapping: mapping})
+	if err != nil {
+		return nil, err
+	}
+	return []Config{{Type: "file-import", Name: m.Source, Options: options}}, nil
+}
+
+// NewFileImport creates a collector importing the files of dir to s.
+func NewFileImport(dir string, m *ImportMapping, s storage.DBInterface) *FileImport {
+	return &FileImport{
+		DB:      s,
+		Dir:     dir,
+		Mapping: m,
+		log:     logrus.StandardLogger(),
+	}
+}
+
+// FetchListing imports the files of the directory that changed since their
+// last import.
+func (f *FileImport) FetchListing(ctx context.Context) {
+	entries, err := ioutil.ReadDir(f.Dir)
+	if err != nil {
+		f.log.Errorf("Failed to read the import directory %q: %v", f.Dir, err)
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || strings.HasSuffix(e.Name(), reportSuffix) || format(e.Name()) == "" {
+			continue
+		}
+		path := filepath.Join(f.Dir, e.Name())
+		report, err := f.ImportFile(ctx, path)
+		if err != nil {
+			f.log.Errorf("Failed to import %q: %v", path, err)
+			continue
+		}
+		if report != nil {
+			f.log.Infof("Imported %q: %d accepted, %d rejected", path, len(report.Accepted), len(report.Rejected))
+		}
+	}
+}
+
+// ImportFile imports one file and writes its report. It returns a nil report
+// when the file was already imported.
+func (f *FileImport) ImportFile(ctx context.Context, path string) (*ImportReport, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	if previous, err := readReport(path + reportSuffix); err == nil && previous.Checksum == checksum {
+		f.log.Debugf("Skip %q, already imported", path)
+		return nil, nil
+	}
+
+	rows, err := parseRows(format(path), content)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := f.Mapping.spec()
+	report := &ImportReport{
+		File:       filepath.Base(path),
+		Checksum:   checksum,
+		ImportedAt: time.Now().Unix(),
+		Accepted:   []ImportRow{},
+		Rejected:   []ImportRow{},
+	}
+	imported := make([]ImportRow, len(rows))
+	mapped := []*mlspb.Property{}
+	mappedRows := []int{}
+	for i, row := range rows {
+		r := &imported[i]
+		r.Row = i + 1
+		if parseErr, ok := row.(error); ok {
+			r.Error = parseErr.Error()
+			continue
+		}
+
+		p, err := spec.Map(row)
+		switch {
+		case err != nil:
+			r.Error = err.Error()
+		case p.MlsNumber == "":
+			r.Error = "missing mls number"
+		case len(p.Price) > 0 && p.Price[0].Price < 0:
+			r.MlsNumber = p.MlsNumber
+			r.Error = "invalid price"
+		default:
+			r.MlsNumber = p.MlsNumber
+			mapped = append(mapped, p)
+			mappedRows = append(mappedRows, i)
+		}
+	}
+	// The listings are saved by batch, a transaction each.
+	for start := 0; start < len(mapped); start += importBatchSize {
+		end := start + importBatchSize
+		if end > len(mapped) {
+			end = len(mapped)
+		}
+		results, err := f.DB.SaveListings(ctx, mapped[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to save the listings of %q: %v", path, err)
+		}
+		for i, result := range results {
+			if result.Err != nil {
+				imported[mappedRows[start+i]].Error = result.Err.Error()
+			}
+		}
+	}
+	for _, r := range imported {
+		if r.Error != "" {
+			report.Rejected = append(report.Rejected, r)
+		} else {
+			report.Accepted = append(report.Accepted, r)
+		}
+	}
+
+	// A dry run leaves the file to be imported by the next run.
+	if _, ok := f.DB.(*storage.DryRunDB); ok {
+		return report, nil
+	}
+	if err := writeReport(path+reportSuffix, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// format returns the file format from its extension, or "" when the file is
+// not a listing dump.
+func format(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return "csv"
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	case ".json":
+		return "json"
+	}
+	return ""
+}
+
+// parseRows decodes the rows of a file into the generic JSON values the spec
+// maps, a CSV row becomes an object keyed by the header.
+func parseRows(format string, content []byte) ([]interface{}, error) {
+	rows := []interface{}{}
+	switch format {
+	case "csv":
+		records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse csv: %v", err)
+		}
+		if len(records) == 0 {
+			return rows, nil
+		}
+		header := records[0]
+		for _, record := range records[1:] {
+			row := make(map[string]interface{})
+			for i, column := range header {
+				if i < len(record) {
+					row[strings.TrimSpace(column)] = record[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+	case "jsonl":
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			var row interface{}
+			if err := json.Unmarshal([]byte(text), &row); err != nil {
+				// Keep the bad line as a row so the report rejects it.
+				row = fmt.Errorf("line %d: %v", line, err)
+			}
+			rows = append(rows, row)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read json lines: %v", err)
+		}
+	case "json":
+		if err := json.Unmarshal(content, &rows); err != nil {
+			return nil, fmt.Errorf("failed to parse json, expected an array of listings: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file format")
+	}
+	return rows, nil
+}
+
+func readReport(path string) (*ImportReport, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var report *ImportReport
+	if err := json.Unmarshal(content, &report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func writeReport(path string, report *ImportReport) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write the import report: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// GetDB retrieves the DB instance
+func (f *FileImport) GetDB() storage.DBInterface {
+	return f.DB
+}
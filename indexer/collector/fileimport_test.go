@@ -0,0 +1,168 @@
+package collector
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+var importMapping = &ImportMapping{
+	Source: "agent-import",
+	Fields: map[string]FieldSpec{
+		"mls_number": {Paths: []string{"MLS #"}, Transforms: []string{"trim"}},
+		"address":    {Paths: []string{"Address"}, Transforms: []string{"trim"}},
+		"city":       {Paths: []string{"Address"}, Transforms: []string{"split_address:city"}},
+		"price":      {Paths: []string{"Price"}, Transforms: []string{"parse_price"}},
+		"bedrooms":   {Paths: []string{"Beds"}, Transforms: []string{"trim"}},
+		"parking":    {Paths: []string{"Parking"}, Transforms: []string{"split:;"}},
+	},
+}
+
+func writeImportFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileimport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("can import a csv file and report rejected rows", func(t *testing.T) {
+		path := writeImportFile(t, dir, "agent.csv", `MLS #,Address,Price,Beds,Parking
+19016318,"1234 street|city, province A0B1C2","$10,000",3,Garage; Gravel
+,"5 street|city, province A0B1C2","$20,000",2,
+19016319,"6 street|city, province A0B1C2",call agent,2,
+`)
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		f := NewFileImport(dir, importMapping, mDB)
+		report, err := f.ImportFile(context.Background(), path)
+		if err != nil {
+			t.Fatalf("failed to import: %v", err)
+		}
+
+		if len(report.Accepted) != 1 || report.Accepted[0].MlsNumber != "19016318" {
+			t.Errorf("unexpected accepted rows %v", report.Accepted)
+		}
+		if len(report.Rejected) != 2 || report.Rejected[0].Row != 2 || report.Rejected[1].Error != "invalid price" {
+			t.Errorf("unexpected rejected rows %v", report.Rejected)
+		}
+
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 1 {
+			t.Fatalf("expected 1 saved listing, got %d", len(savedListings.Property))
+		}
+		p := savedListings.Property[0]
+		AssertStringEqual(t, p.City, "city")
+		AssertStringEqual(t, p.Source, "agent-import")
+		AssertArrayEqual(t, p.Parking, []string{"Garage", "Gravel"})
+
+		if _, err := os.Stat(path + reportSuffix); err != nil {
+			t.Errorf("expected a report file: %v", err)
+		}
+	})
+
+	t.Run("skip a file already imported", func(t *testing.T) {
+		path := writeImportFile(t, dir, "again.jsonl", `{"MLS #": "1", "Price": "100"}
+not json
+{"MLS #": "2", "Price": "200"}
+`)
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		f := NewFileImport(dir, importMapping, mDB)
+		report, err := f.ImportFile(context.Background(), path)
+		if err != nil {
+			t.Fatalf("failed to import: %v", err)
+		}
+		if len(report.Accepted) != 2 || len(report.Rejected) != 1 {
+			t.Errorf("unexpected report %+v", report)
+		}
+
+		report, err = f.ImportFile(context.Background(), path)
+		if err != nil || report != nil {
+			t.Errorf("expected the unchanged file to be skipped, got %v, %v", report, err)
+		}
+
+		writeImportFile(t, dir, "again.jsonl", `{"MLS #": "3", "Price": "300"}`)
+		if report, _ = f.ImportFile(context.Background(), path); report == nil || len(report.Accepted) != 1 {
+			t.Errorf("expected the changed file to be imported again, got %v", report)
+		}
+	})
+
+	t.Run("leave the file to import after a dry run", func(t *testing.T) {
+		path := writeImportFile(t, dir, "dryrun.csv", "MLS #,Price\n4,400\n")
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		d := storage.NewDryRunDB(mDB)
+		report, err := NewFileImport(dir, importMapping, d).ImportFile(context.Background(), path)
+		if err != nil || report == nil || len(report.Accepted) != 1 {
+			t.Fatalf("unexpected report %v, %v", report, err)
+		}
+		if _, err := os.Stat(path + reportSuffix); !os.IsNotExist(err) {
+			t.Errorf("expected no report file, got %v", err)
+		}
+		if changes := d.Report().Changes; len(changes) != 1 || !changes[0].New {
+			t.Errorf("expected a new listing in the dry run, got %+v", changes)
+		}
+	})
+
+	t.Run("can import every file of the directory", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "fileimport")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		writeImportFile(t, dir, "a.json", `[{"MLS #": "1", "Price": "100"}, {"MLS #": "2", "Price": "200"}]`)
+		writeImportFile(t, dir, "b.csv", "MLS #,Price\n3,300\n")
+		writeImportFile(t, dir, "notes.txt", "not a listing dump")
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		f := NewFileImport(dir, importMapping, mDB)
+		f.FetchListing(context.Background())
+		f.FetchListing(context.Background())
+
+		savedListings, _ := f.GetDB().ReadListings(context.Background())
+		if len(savedListings.Property) != 3 {
+			t.Errorf("expected 3 saved listings, got %d", len(savedListings.Property))
+		}
+	})
+}
+
+func TestImportConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fileimport")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("can configure a file import collector named after its mapping", func(t *testing.T) {
+		mapping := writeImportFile(t, dir, "mapping.json", `{"source": "agent-import", "fields": {"mls_number": {"paths": ["MLS #"]}}}`)
+		configs, err := ImportConfigs(dir, mapping)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		collectors, err := NewCollectors(configs, Deps{DB: mDB})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if f, ok := collectors["agent-import"].(*FileImport); !ok || f.Dir != dir {
+			t.Errorf("expected a file import of %s, got %+v", dir, collectors)
+		}
+	})
+
+	t.Run("reject a missing mapping", func(t *testing.T) {
+		if _, err := ImportConfigs(dir, ""); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -3,24 +3,160 @@
 package collector
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
 
+	"github.com/sirupsen/logrus"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
 	"github.com/tony-yang/realtor-tracker/indexer/storage"
 )
 
 var (
-	Collectors = make(map[string]Collector)
+	// factories are the collector types built in, RegisterCollector adds the
+	// others.
+	factories = map[string]Factory{
+		source:        newMlsCollector,
+		resoSource:    newResoCollector,
+		"spec":        newSpecCollector,
+		"file-import": newFileImportCollector,
+		"plugin":      newPluginCollector,
+	}
 )
 
-// RegisterCollector registers the individual mls collector for each MLS data source.
-func RegisterCollector(name string, c Collector) error {
-	if _, ok := Collectors[name]; ok {
+// Deps are the dependencies injected into every collector.
+type Deps struct {
+	DB     storage.DBInterface
+	Client *http.Client
+	Logger logrus.FieldLogger
+}
+
+// Config configures one collector built from a registered factory.
+type Config struct {
+	// Type is the name the factory is registered under, ie. "mls-canada".
+	Type string `json:"type"`
+	// Name is the name the collector runs under, it defaults to Type.
+	Name string `json:"name"`
+	// Options are the collector specific settings.
+	Options json.RawMessage `json:"options"`
+}
+
+// Factory builds a collector from its config and dependencies.
+type Factory func(config Config, deps Deps) (Collector, error)
+
+// RegisterCollector registers the factory of the individual mls collector for
+// each MLS data source.
+func RegisterCollector(name string, f Factory) error {
+	if _, ok := factories[name]; ok {
 		return fmt.Errorf("Collector '%s' already existed. Register the new collector with a different name", name)
 	}
-	Collectors[name] = c
+	factories[name] = f
+	return nil
+}
+
+// Types lists the registered collector types.
+func Types() []string {
+	types := []string{}
+	for name := range factories {
+		types = append(types, name)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New builds a collector from its registered factory. The DB is required, the
+// HTTP client and logger default to a default client and the standard logger.
+func New(config Config, deps Deps) (Collector, error) {
+	f, ok := factories[config.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown collector type %q", config.Type)
+	}
+	if deps.DB == nil {
+		return nil, fmt.Errorf("the %q collector needs a DB", config.Type)
+	}
+	if deps.Client == nil {
+		deps.Client = &http.Client{}
+	}
+	if deps.Logger == nil {
+		deps.Logger = logrus.StandardLogger()
+	}
+	if config.Name == "" {
+		config.Name = config.Type
+	}
+
+	c, err := f(config, deps.withCollector(config.Name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the %q collector: %v", config.Name, err)
+	}
+	return c, nil
+}
+
+// NewCollectors builds the configured collectors keyed by name.
+func NewCollectors(configs []Config, deps Deps) (map[string]Collector, error) {
+	collectors := make(map[string]Collector)
+	for _, config := range configs {
+		c, err := New(config, deps)
+		if err != nil {
+			return nil, err
+		}
+		name := config.Name
+		if name == "" {
+			name = config.Type
+		}
+		if _, ok := collectors[name]; ok {
+			return nil, fmt.Errorf("collector %q is configured twice", name)
+		}
+		collectors[name] = c
+	}
+	return collectors, nil
+}
+
+// LoadConfigs reads the collector configs from a JSON file of the form
+// {"collectors": [{"type": "mls-canada", "options": {...}}]}.
+func LoadConfigs(path string) ([]Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collector config %q: %v", path, err)
+	}
+	var file struct {
+		Collectors []Config `json:"collectors"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse collector config %q: %v", path, err)
+	}
+	return file.Collectors, nil
+}
+
+// decodeOptions decodes the collector specific options of a config.
+func decodeOptions(config Config, options interface{}) error {
+	if len(config.Options) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(config.Options, options); err != nil {
+		return fmt.Errorf("invalid options: %v", err)
+	}
 	return nil
 }
 
+func (d Deps) withCollector(name string) Deps {
+	d.Logger = d.Logger.WithField("collector", name)
+	return d
+}
+
+// saveListing saves a new listing, or appends the new price to the listing
+// when it already exists. created reports whether the listing was new.
+func saveListing(ctx context.Context, db storage.DBInterface, p *mlspb.Property) (created bool, err error) {
+	outcome, err := db.SaveOrUpdateListing(ctx, p)
+	if err != nil {
+		return false, fmt.Errorf("failed to save listing %s: %v", p.MlsNumber, err)
+	}
+	logrus.Debugf("Saved listing %s: %s", p.MlsNumber, outcome)
+	return outcome == storage.Created, nil
+}
+
 type building struct {
 	Bathrooms    string `json:"BathroomTotal"`
 	Bedrooms     string `json:"Bedrooms"`
@@ -70,14 +206,20 @@ type listing struct {
 	URLEn         string   `json:"RelativeURLEn"`
 }
 
+type paging struct {
+	TotalPages int `json:"TotalPages"`
+}
+
 type listings struct {
+	Paging  paging    `json:"Paging"`
 	Listing []listing `json:"Results"`
 }
 
 // Collector defines the interface for individual collector implementation.
 type Collector interface {
-	// FetchListing retrieves from source listing and saves to DB
-	FetchListing()
+	// FetchListing retrieves from source listing and saves to DB, it stops
+	// early when ctx is done.
+	FetchListing(ctx context.Context)
 	// GetDB retrieves the DB instance
 	GetDB() storage.DBInterface
 }
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("can build an isolated collector with injected dependencies", func(t *testing.T) {
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		client := NewTestClient(func(req *http.Request) *http.Response { return nil })
+		c, err := New(Config{
+			Type:    "mls-canada",
+			Options: json.RawMessage(`{"languages": ["en", "fr"]}`),
+		}, Deps{DB: mDB, Client: client, Logger: logrus.New()})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		m, ok := c.(*Mls)
+		if !ok {
+			t.Fatalf("expected a *Mls, got %T", c)
+		}
+		if m.GetDB() != mDB || m.client != client {
+			t.Error("expected the injected DB and client")
+		}
+		AssertArrayEqual(t, m.Languages, []string{"en", "fr"})
+	})
+
+	t.Run("default the client and logger", func(t *testing.T) {
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		c, err := New(Config{Type: "mls-canada"}, Deps{DB: mDB})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if m := c.(*Mls); m.GetDB() != mDB || m.client == nil {
+			t.Error("expected the injected DB and a default client")
+		}
+	})
+
+	t.Run("require the DB", func(t *testing.T) {
+		if _, err := New(Config{Type: "mls-canada"}, Deps{}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("reject invalid configs", func(t *testing.T) {
+		configs := map[string]Config{
+			"unknown type":         {Type: "unknown"},
+			"unsupported language": {Type: "mls-canada", Options: json.RawMessage(`{"languages": ["de"]}`)},
+			"invalid options":      {Type: "mls-canada", Options: json.RawMessage(`[]`)},
+			"reso without url":     {Type: "reso"},
+			"missing spec":         {Type: "spec", Options: json.RawMessage(`{"path": "testdata/missing.json"}`)},
+		}
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		for name, config := range configs {
+			if _, err := New(config, Deps{DB: mDB}); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestNewCollectors(t *testing.T) {
+	t.Run("can build the collectors of a config file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "collector")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "collectors.json")
+		config := `{
+		  "collectors": [
+		    {"type": "mls-canada"},
+		    {"type": "reso", "name": "reso-east", "options": {"url": "http://localhost/odata", "page_size": 10}},
+		    {"type": "spec", "name": "mls-canada-spec", "options": {"path": "testdata/mls-canada.spec.json"}}
+		  ]
+		}`
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		configs, err := LoadConfigs(path)
+		if err != nil {
+			t.Fatalf("failed to load configs: %v", err)
+		}
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		collectors, err := NewCollectors(configs, Deps{DB: mDB})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(collectors) != 3 {
+			t.Fatalf("expected 3 collectors, got %d", len(collectors))
+		}
+		if r := collectors["reso-east"].(*Reso); r.PageSize != 10 {
+			t.Errorf("got page size %d, want 10", r.PageSize)
+		}
+	})
+
+	t.Run("reject a collector configured twice", func(t *testing.T) {
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		configs := []Config{{Type: "mls-canada"}, {Type: "mls-canada"}}
+		if _, err := NewCollectors(configs, Deps{DB: mDB}); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestIncrementalConfigs(t *testing.T) {
+	t.Run("run the mls canada collectors in incremental mode", func(t *testing.T) {
+		configs, err := IncrementalConfigs([]Config{
+			{Type: "mls-canada"},
+			{Type: "mls-canada", Name: "mls-fr", Options: json.RawMessage(`{"languages": ["fr"]}`)},
+			{Type: "spec", Name: "mls-canada-spec", Options: json.RawMessage(`{"path": "testdata/mls-canada.spec.json"}`)},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		collectors, err := NewCollectors(configs, Deps{DB: mDB})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, name := range []string{"mls-canada", "mls-fr"} {
+			if m := collectors[name].(*Mls); !m.Incremental {
+				t.Errorf("expected %s to run in incremental mode", name)
+			}
+		}
+		AssertArrayEqual(t, collectors["mls-fr"].(*Mls).Languages, []string{"fr"})
+		if string(configs[2].Options) != `{"path": "testdata/mls-canada.spec.json"}` {
+			t.Errorf("got spec options %s, want them unchanged", configs[2].Options)
+		}
+	})
+}
+
+func TestRegisterCollector(t *testing.T) {
+	t.Run("reject a duplicate factory", func(t *testing.T) {
+		if err := RegisterCollector("mls-canada", newMlsCollector); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package collector
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -11,45 +13,192 @@ import (
 
 	"github.com/sirupsen/logrus"
 	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/photohash"
 	"github.com/tony-yang/realtor-tracker/indexer/storage"
+	"github.com/tony-yang/realtor-tracker/indexer/tagger"
 )
 
 const (
 	source = "mls-canada"
+	// searchURL is the realtor.ca PropertySearch_Post endpoint.
+	searchURL = "https://api2.realtor.ca/Listing.svc/PropertySearch_Post"
 )
 
 var (
-	cityIndex = map[string]*storage.City{
-		"windsor,ontario": {Name: "Windsor", State: "Ontario", MlsNumber: make(map[string]bool)},
+	// DefaultRegions is the area crawled when no region is configured.
+	DefaultRegions = []Region{
+		{
+			Name:         "windsor",
+			LatitudeMin:  41.9947561,
+			LatitudeMax:  42.3661983,
+			LongitudeMin: -83.1245969,
+			LongitudeMax: -82.4784635,
+			Tiles:        1,
+		},
+	}
+
+	// cultureIDs maps the language of the listing text to the CultureId
+	// understood by MLS Canada.
+	cultureIDs = map[string]string{
+		"en": "1",
+		"fr": "2",
 	}
 )
 
-func init() {
-	db, err := storage.NewSqliteDB("/tmp/realtor_prod.db")
-	if err != nil {
-		logrus.Fatalf("Failed to initialize %q: %v", source, err)
+// Region is an area crawled by the MLS Canada collector. The area is split in
+// a Tiles x Tiles grid so every search stays small enough to page through.
+type Region struct {
+	Name         string  `json:"name"`
+	LatitudeMin  float64 `json:"latitude_min"`
+	LatitudeMax  float64 `json:"latitude_max"`
+	LongitudeMin float64 `json:"longitude_min"`
+	LongitudeMax float64 `json:"longitude_max"`
+	Tiles        int     `json:"tiles"`
+}
+
+type tile struct {
+	latitudeMin, latitudeMax, longitudeMin, longitudeMax float64
+}
+
+func (r Region) tiles() []tile {
+	n := r.Tiles
+	if n < 1 {
+		n = 1
 	}
-	RegisterCollector(source, NewMls(db, &http.Client{}))
+	latitudeStep := (r.LatitudeMax - r.LatitudeMin) / float64(n)
+	longitudeStep := (r.LongitudeMax - r.LongitudeMin) / float64(n)
+
+	tiles := []tile{}
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			tiles = append(tiles, tile{
+				latitudeMin:  r.LatitudeMin + float64(i)*latitudeStep,
+				latitudeMax:  r.LatitudeMin + float64(i+1)*latitudeStep,
+				longitudeMin: r.LongitudeMin + float64(j)*longitudeStep,
+				longitudeMax: r.LongitudeMin + float64(j+1)*longitudeStep,
+			})
+		}
+	}
+	return tiles
 }
 
-type Mls struct {
-	DB     storage.DBInterface
-	client *http.Client
+// mlsOptions are the config options of the MLS Canada collector.
+type mlsOptions struct {
+	Regions        []Region `json:"regions"`
+	RecordsPerPage int      `json:"records_per_page"`
+	MaxPages       int      `json:"max_pages"`
+	Incremental    bool     `json:"incremental"`
+	StopAfterKnown int      `json:"stop_after_known"`
+	Languages      []string `json:"languages"`
+	// TaggerRules is a JSON rule file replacing the default tagger rules.
+	TaggerRules string `json:"tagger_rules"`
+	// URL replaces the realtor.ca search endpoint, ie. with a local stand-in.
+	URL string `json:"url"`
 }
 
-// NewMls create a new client for the MLS Canada collector.
-func NewMls(s storage.DBInterface, c *http.Client) *Mls {
-	if s == nil {
-		s, _ = storage.NewMemoryDB(cityIndex)
+// IncrementalConfigs runs the MLS Canada collectors of the configs in
+// incremental mode.
+func IncrementalConfigs(configs []Config) ([]Config, error) {
+	incremental := []Config{}
+	for _, config := range configs {
+		if config.Type == source {
+			options := map[string]interface{}{}
+			if err := decodeOptions(config, &options); err != nil {
+				return nil, fmt.Errorf("collector %q: %v", config.Name, err)
+			}
+			options["incremental"] = true
+			encoded, err := json.Marshal(options)
+			if err != nil {
+				return nil, err
+			}
+			config.Options = encoded
+		}
+		incremental = append(incremental, config)
+	}
+	return incremental, nil
+}
+
+func newMlsCollector(config Config, deps Deps) (Collector, error) {
+	var options mlsOptions
+	if err := decodeOptions(config, &options); err != nil {
+		return nil, err
+	}
+
+	m := NewMls(deps.DB, deps.Client)
+	m.log = deps.Logger
+	if len(options.Regions) > 0 {
+		m.Regions = options.Regions
+	}
+	if options.RecordsPerPage > 0 {
+		m.RecordsPerPage = options.RecordsPerPage
+	}
+	if options.MaxPages > 0 {
+		m.MaxPages = options.MaxPages
+	}
+	m.Incremental = options.Incremental
+	if options.StopAfterKnown > 0 {
+		m.StopAfterKnown = options.StopAfterKnown
+	}
+	if len(options.Languages) > 0 {
+		for _, lang := range options.Languages {
+			if _, ok := cultureIDs[lang]; !ok {
+				return nil, fmt.Errorf("language %q is not supported", lang)
+			}
+		}
+		m.Languages = options.Languages
 	}
+	if options.URL != "" {
+		m.URL = options.URL
+	}
+	if options.TaggerRules != "" {
+		t, err := tagger.Load(options.TaggerRules)
+		if err != nil {
+			return nil, err
+		}
+		m.Tagger = t
+	}
+	return m, nil
+}
+
+type Mls struct {
+	// URL is the PropertySearch_Post endpoint of the search.
+	URL     string
+	DB      storage.DBInterface
+	Tagger  *tagger.Tagger
+	Regions []Region
+	// RecordsPerPage is the page size of a search, MaxPages caps the pages
+	// read in every tile.
+	RecordsPerPage int
+	MaxPages       int
+	// Incremental runs only read the newest listings of every tile, until
+	// StopAfterKnown listings already saved with the same price are found.
+	Incremental    bool
+	StopAfterKnown int
+	// Languages lists the languages the listing text is collected in. The
+	// first language fills the listing fields, every language is kept in the
+	// listing localized text.
+	Languages []string
+	client    *http.Client
+	log       logrus.FieldLogger
+}
 
+// NewMls create a new client for the MLS Canada collector saving to s.
+func NewMls(s storage.DBInterface, c *http.Client) *Mls {
 	if c == nil {
 		c = &http.Client{}
 	}
 
 	return &Mls{
-		DB:     s,
-		client: c,
+		URL:            searchURL,
+		DB:             s,
+		Tagger:         tagger.New(tagger.DefaultRules),
+		Regions:        DefaultRegions,
+		RecordsPerPage: 2,
+		MaxPages:       50,
+		StopAfterKnown: 20,
+		Languages:      []string{"en"},
+		client:         c,
+		log:            logrus.StandardLogger(),
 	}
 }
 
@@ -77,10 +226,7 @@ func formatListing(listings *listings) map[string]*mlspb.Property {
 			mlsURL = strings.TrimSpace(l.URLEn)
 		}
 
-		houseType := strings.TrimSpace(l.Building.BuildingType)
-		if houseType == "" {
-			houseType = strings.TrimSpace(l.Property.PropertyType)
-		}
+		houseType := propertyType(l)
 
 		p, err := strconv.Atoi(strings.ReplaceAll(strings.TrimLeft(l.Property.Price, "$"), ",", ""))
 		if err != nil {
@@ -141,56 +287,339 @@ func formatListing(listings *listings) map[string]*mlspb.Property {
 	return properties
 }
 
-// FetchListing retrieves the mls listing from MLS Canada.
-func (m *Mls) FetchListing() {
-	listingUrl := "https://api2.realtor.ca/Listing.svc/PropertySearch_Post"
+func propertyType(l listing) string {
+	houseType := strings.TrimSpace(l.Building.BuildingType)
+	if houseType == "" {
+		houseType = strings.TrimSpace(l.Property.PropertyType)
+	}
+	return houseType
+}
+
+// localizeListing adds the text of the listings fetched in language lang to
+// the matching properties.
+func localizeListing(properties map[string]*mlspb.Property, listings *listings, lang string) {
+	for _, l := range listings.Listing {
+		p, ok := properties[strings.TrimSpace(l.MlsNumber)]
+		if !ok {
+			continue
+		}
+		if p.LocalizedText == nil {
+			p.LocalizedText = make(map[string]*mlspb.LocalizedText)
+		}
+		p.LocalizedText[lang] = &mlspb.LocalizedText{
+			PublicRemarks: strings.TrimSpace(l.PublicRemarks),
+			PropertyType:  propertyType(l),
+		}
+	}
+}
+
+// FetchListing retrieves the mls listing from MLS Canada, with a full sweep or
+// an incremental run.
+func (m *Mls) FetchListing(ctx context.Context) {
+	regions := []string{}
+	for _, region := range m.Regions {
+		regions = append(regions, region.Name)
+	}
+	r := startRun(ctx, m.DB, m.log, source, regions...)
+
+	var err error
+	if m.Incremental {
+		m.fetchIncremental(r)
+		err = ctx.Err()
+	} else {
+		err = m.fetchAll(r)
+	}
+	if err != nil {
+		m.log.Error(err)
+	}
+	r.finish(err)
+}
+
+// fetchIncremental reads the newest first search results of every tile and
+// moves to the next tile once StopAfterKnown listings are already known and
+// unchanged. It does not checkpoint nor delist, the full sweep does.
+func (m *Mls) fetchIncremental(r *run) {
+	for _, region := range m.Regions {
+		for ti, t := range region.tiles() {
+			known := 0
+			for page := 1; page <= m.MaxPages && known < m.StopAfterKnown; page++ {
+				if r.ctx.Err() != nil {
+					return
+				}
+				properties, totalPages, err := m.fetchPage(r, t, page)
+				if err != nil {
+					m.log.Errorf("Failed to fetch region %q tile %d page %d: %v", region.Name, ti, page, err)
+					break
+				}
+				for _, p := range properties {
+					if m.unchanged(r.ctx, p) {
+						if err := m.DB.MarkListingSeen(r.ctx, p.MlsNumber, time.Now().Unix()); err != nil {
+							m.log.Errorf("Failed to mark listing seen: %v", err)
+						}
+						if known++; known >= m.StopAfterKnown {
+							m.log.Infof("Region %q tile %d reached %d known listings on page %d", region.Name, ti, known, page)
+							break
+						}
+						continue
+					}
+					m.saveProperty(r, p)
+				}
+				if page >= totalPages {
+					break
+				}
+			}
+		}
+	}
+}
+
+// unchanged reports whether the listing is already saved with the same price.
+func (m *Mls) unchanged(ctx context.Context, p *mlspb.Property) bool {
+	if len(p.Price) == 0 {
+		return false
+	}
+	price, found, err := m.DB.ReadLatestPrice(ctx, p.MlsNumber)
+	if err != nil {
+		m.log.Errorf("Failed to read the price of listing %s: %v", p.MlsNumber, err)
+		return false
+	}
+	return found && price == p.Price[len(p.Price)-1].Price
+}
+
+// fetchAll crawls every page of every tile of the regions. A checkpoint is
+// saved after every page so a run that stops resumes where it left off. Once
+// every tile is covered, the listings not seen during the run are delisted. The
+// coverage is checkpointed too, so a resumed run delists nothing when a tile
+// was not covered before it stopped.
+func (m *Mls) fetchAll(r *run) error {
+	checkpoint, err := m.DB.ReadCheckpoint(r.ctx, source)
+	if err != nil {
+		return fmt.Errorf("failed to read the checkpoint: %v", err)
+	}
+	startRegion := -1
+	if checkpoint != nil {
+		startRegion = m.regionIndex(checkpoint.Region)
+	}
+	if startRegion < 0 {
+		now := time.Now()
+		checkpoint = &storage.Checkpoint{
+			RunID:     strconv.FormatInt(now.UnixNano(), 10),
+			Source:    source,
+			Page:      1,
+			StartedAt: now.Unix(),
+			Covered:   true,
+		}
+		startRegion = 0
+	} else {
+		m.log.Infof("Resume run %s at region %q tile %d page %d", checkpoint.RunID, checkpoint.Region, checkpoint.Tile, checkpoint.Page)
+	}
+	startTile, startPage := checkpoint.Tile, checkpoint.Page
+
+	for ri := startRegion; ri < len(m.Regions); ri++ {
+		region := m.Regions[ri]
+		tiles := region.tiles()
+		for ti := range tiles {
+			page := 1
+			if ri == startRegion {
+				if ti < startTile {
+					continue
+				}
+				if ti == startTile && startPage > 1 {
+					page = startPage
+				}
+			}
+
+			for ; page <= m.MaxPages; page++ {
+				// A cancelled run resumes from the checkpoint.
+				if err := r.ctx.Err(); err != nil {
+					return fmt.Errorf("run %s stopped at region %q tile %d page %d: %v", checkpoint.RunID, region.Name, ti, page, err)
+				}
+				properties, totalPages, err := m.fetchPage(r, tiles[ti], page)
+				if err != nil {
+					return fmt.Errorf("failed to fetch region %q tile %d page %d: %v", region.Name, ti, page, err)
+				}
+				for _, p := range properties {
+					m.saveProperty(r, p)
+				}
+				checkpoint.Region, checkpoint.Tile, checkpoint.Page = region.Name, ti, page+1
+				if page >= totalPages {
+					// The tile is covered, resume from the next one.
+					checkpoint.Tile, checkpoint.Page = ti+1, 1
+				} else if page == m.MaxPages {
+					m.log.Warnf("Region %q tile %d has more than %d pages", region.Name, ti, m.MaxPages)
+					checkpoint.Tile, checkpoint.Page, checkpoint.Covered = ti+1, 1, false
+				}
+				if err := m.DB.SaveCheckpoint(r.ctx, checkpoint); err != nil {
+					m.log.Errorf("Failed to save the checkpoint: %v", err)
+				}
+				if page >= totalPages {
+					break
+				}
+			}
+		}
+	}
+
+	if err := m.DB.DeleteCheckpoint(r.ctx, source); err != nil {
+		m.log.Errorf("Failed to delete the checkpoint: %v", err)
+	}
+	if !checkpoint.Covered {
+		m.log.Infof("Run %s completed without covering every tile, skip delisting", checkpoint.RunID)
+		return nil
+	}
+	delisted, err := m.DB.DelistUnseen(r.ctx, source, checkpoint.StartedAt)
+	if err != nil {
+		return fmt.Errorf("failed to delist the listings not seen by run %s: %v", checkpoint.RunID, err)
+	}
+	r.delisted(delisted)
+	m.log.Infof("Run %s completed, %d listings delisted", checkpoint.RunID, delisted)
+	return nil
+}
+
+func (m *Mls) regionIndex(name string) int {
+	for i, r := range m.Regions {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// fetchPage fetches one page of listings of a tile in every language. It
+// returns the listings in the search order and the number of pages of the
+// tile.
+func (m *Mls) fetchPage(r *run, t tile, page int) ([]*mlspb.Property, int, error) {
+	var (
+		properties map[string]*mlspb.Property
+		order      []string
+	)
+	totalPages := 1
+	for i, lang := range m.Languages {
+		listings, err := m.fetchListings(r.ctx, lang, t, page)
+		if err != nil {
+			r.failed()
+			if i == 0 {
+				return nil, 0, err
+			}
+			m.log.Errorf("Failed to fetch the %q listings: %v", lang, err)
+			continue
+		}
+		if i == 0 {
+			properties = formatListing(listings)
+			for _, l := range listings.Listing {
+				order = append(order, strings.TrimSpace(l.MlsNumber))
+			}
+			if listings.Paging.TotalPages > totalPages {
+				totalPages = listings.Paging.TotalPages
+			}
+		}
+		localizeListing(properties, listings, lang)
+	}
+
+	r.fetched()
+	result := []*mlspb.Property{}
+	for _, mlsNumber := range order {
+		if p, ok := properties[mlsNumber]; ok {
+			result = append(result, p)
+			// Keep a listing repeated in the results once.
+			delete(properties, mlsNumber)
+		}
+	}
+	return result, totalPages, nil
+}
+
+// saveProperty tags and saves a listing, and hashes the photos of a new one.
+func (m *Mls) saveProperty(r *run, p *mlspb.Property) {
+	p.Tags = m.Tagger.Tags(p.PublicRemarks)
+	created, err := r.save(p)
+	if err != nil {
+		m.log.Error(err)
+		return
+	}
+	if created {
+		m.hashPhotos(r.ctx, p)
+	}
+
+	// Saving a listing without tags keeps the saved ones, the remarks no
+	// longer mention them.
+	if !created && len(p.Tags) == 0 {
+		if err := m.DB.SaveListingTags(r.ctx, p.MlsNumber, nil); err != nil {
+			m.log.Errorf("Failed to clear listing tags: %v", err)
+		}
+	}
+	if err := m.DB.MarkListingSeen(r.ctx, p.MlsNumber, time.Now().Unix()); err != nil {
+		m.log.Errorf("Failed to mark listing seen: %v", err)
+	}
+}
+
+// fetchListings retrieves one page of the listings of a tile with the text in
+// language lang.
+func (m *Mls) fetchListings(ctx context.Context, lang string, t tile, page int) (*listings, error) {
+	cultureID, ok := cultureIDs[lang]
+	if !ok {
+		return nil, fmt.Errorf("language %q is not supported", lang)
+	}
+
 	data := url.Values{
 		"ZoomLevel":            {"11"},
-		"LatitudeMax":          {"42.3661983"},
-		"LongitudeMax":         {"-82.4784635"},
-		"LatitudeMin":          {"41.9947561"},
-		"LongitudeMin":         {"-83.1245969"},
-		"CurrentPage":          {"1"},
+		"LatitudeMax":          {formatCoordinate(t.latitudeMax)},
+		"LongitudeMax":         {formatCoordinate(t.longitudeMax)},
+		"LatitudeMin":          {formatCoordinate(t.latitudeMin)},
+		"LongitudeMin":         {formatCoordinate(t.longitudeMin)},
+		"CurrentPage":          {strconv.Itoa(page)},
 		"Sort":                 {"6-D"},
-		"RecordsPerPage":       {"2"},
+		"RecordsPerPage":       {strconv.Itoa(m.RecordsPerPage)},
 		"PropertyTypeGroupID":  {"1"},
 		"PropertySearchTypeId": {"1"},
 		"TransactionTypeId":    {"2"},
 		"ApplicationId":        {"1"},
-		"CultureId":            {"1"},
+		"CultureId":            {cultureID},
 		"Version":              {"7.0"},
 	}
 
-	resp, err := m.client.PostForm(listingUrl, data)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.URL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := m.client.Do(req)
 	if err != nil {
-		logrus.Error("HTTP post form error:", err)
+		return nil, fmt.Errorf("HTTP post form error: %v", err)
 	}
 
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, m.URL)
+	}
 	bodyContent, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		logrus.Error("Failed to read response:", err)
+		return nil, fmt.Errorf("failed to read response: %v", err)
 	}
 
 	var listings *listings
 	err = json.Unmarshal(bodyContent, &listings)
 	if err != nil {
-		logrus.Fatalf("Failed to parse the json response into listing: %v", err)
+		return nil, fmt.Errorf("failed to parse the json response into listing: %v", err)
 	}
+	if listings == nil {
+		return nil, fmt.Errorf("no listings in the response from %s", m.URL)
+	}
+	return listings, nil
+}
+
+func formatCoordinate(c float64) string {
+	return strconv.FormatFloat(c, 'f', 7, 64)
+}
 
-	properties := formatListing(listings)
-	for _, p := range properties {
-		err := m.DB.SaveNewListing(p)
+// hashPhotos saves the perceptual hash of every photo of a new listing so the
+// same pictures can be recognized when the home is relisted.
+func (m *Mls) hashPhotos(ctx context.Context, p *mlspb.Property) {
+	for _, url := range p.PhotoUrl {
+		hash, err := photohash.Fetch(ctx, m.client, url)
 		if err != nil {
-			if strings.HasPrefix(err.Error(), "listing exists") {
-				logrus.Debugf("Failed to save new listing: %v", err)
-				err = m.DB.UpdateListing(p)
-				if err != nil {
-					logrus.Errorf("Failed to update listing: %v", err)
-				}
-			} else {
-				logrus.Errorf("Failed to save new listing: %v", err)
-			}
+			m.log.Debugf("Failed to hash photo: %v", err)
+			continue
+		}
+		if err := m.DB.SavePhotoHash(ctx, url, hash); err != nil {
+			m.log.Errorf("Failed to save photo hash: %v", err)
 		}
 	}
 }
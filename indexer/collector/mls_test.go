@@ -2,12 +2,21 @@ package collector
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 
+	"github.com/tony-yang/realtor-tracker/indexer/cassette"
 	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/photohash"
 	"github.com/tony-yang/realtor-tracker/indexer/storage"
 )
 
@@ -110,13 +119,450 @@ func TestFetchListing(t *testing.T) {
 		}
 		mDB, _ := storage.NewMemoryDB(cityIndex)
 		m := NewMls(mDB, c)
-		m.FetchListing()
-		savedListings, _ := mDB.ReadListings()
+		m.FetchListing(context.Background())
+		savedListings, _ := mDB.ReadListings(context.Background())
 
 		if savedListings.Property[0].MlsId != "20552312" {
 			t.Errorf("mlsID incorrectly saved, expected %s, got %s", "20552312", savedListings.Property[0].MlsId)
 		}
 	})
+
+	t.Run("can hash the photos of a new listing", func(t *testing.T) {
+		img := image.NewGray(image.Rect(0, 0, 90, 80))
+		for x := 0; x < 90; x++ {
+			for y := 0; y < 80; y++ {
+				img.SetGray(x, y, color.Gray{Y: uint8(x * 2)})
+			}
+		}
+		var photo bytes.Buffer
+		if err := png.Encode(&photo, img); err != nil {
+			t.Fatal(err)
+		}
+
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			body := ioutil.NopCloser(bytes.NewBufferString(respContent))
+			if r.URL.Host == "picture" {
+				body = ioutil.NopCloser(bytes.NewReader(photo.Bytes()))
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       body,
+				Header:     make(http.Header),
+			}
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c)
+		m.FetchListing(context.Background())
+
+		photoURL := "https://picture/listings/high/456.jpg"
+		hash, ok := mDB.PhotoHash[photoURL]
+		if !ok {
+			t.Fatalf("photo hash of %s was not saved", photoURL)
+		}
+		if hash != photohash.DHash(img) {
+			t.Errorf("photo hash incorrectly saved, expected %x, got %x", photohash.DHash(img), hash)
+		}
+	})
+
+	t.Run("can tag listing from the remarks", func(t *testing.T) {
+		remarks := strings.Replace(respContent, "HOUSE DESCRIPTION", "Renovated home with a pool, no garage.", 1)
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(remarks)),
+				Header:     make(http.Header),
+			}
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c)
+		m.FetchListing(context.Background())
+
+		AssertArrayEqual(t, mDB.Tags["19016318"], []string{"pool", "renovated"})
+	})
+
+	t.Run("can collect the listing text in english and french", func(t *testing.T) {
+		french := strings.Replace(respContent, "HOUSE DESCRIPTION", "DESCRIPTION DE LA MAISON", 1)
+		french = strings.Replace(french, `"Type": "House"`, `"Type": "Maison"`, 1)
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			body := respContent
+			if r.FormValue("CultureId") == "2" {
+				body = french
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+				Header:     make(http.Header),
+			}
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c)
+		m.Languages = []string{"en", "fr"}
+		m.FetchListing(context.Background())
+		savedListings, _ := mDB.ReadListings(context.Background())
+
+		localized := savedListings.Property[0].LocalizedText
+		AssertStringEqual(t, savedListings.Property[0].PublicRemarks, "HOUSE DESCRIPTION")
+		AssertStringEqual(t, localized["en"].PublicRemarks, "HOUSE DESCRIPTION")
+		AssertStringEqual(t, localized["en"].PropertyType, "House")
+		AssertStringEqual(t, localized["fr"].PublicRemarks, "DESCRIPTION DE LA MAISON")
+		AssertStringEqual(t, localized["fr"].PropertyType, "Maison")
+	})
+}
+
+// pageResponse is a search response of one listing per page.
+func pageResponse(mlsNumber string, totalPages int) *http.Response {
+	body := fmt.Sprintf(`{
+	  "Paging": {"RecordsPerPage": 1, "TotalPages": %d},
+	  "Results": [{
+	    "Id": "id-%[2]s",
+	    "MlsNumber": "%[2]s",
+	    "Property": {
+	      "Price": "$10,000",
+	      "Address": {"AddressText": "%[2]s street|city, province A0B1C2"}
+	    }
+	  }]
+	}`, totalPages, mlsNumber)
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(body)),
+		Header:     make(http.Header),
+	}
+}
+
+// TestCrawlCassette crawls a recorded search, run it with CASSETTE_MODE=record
+// to record the cassette again from realtor.ca.
+func TestCrawlCassette(t *testing.T) {
+	t.Run("can crawl a recorded multi-page search", func(t *testing.T) {
+		c, err := cassette.New("testdata/cassettes/mls-windsor.json", cassette.ModeFromEnv(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c.Client())
+		m.FetchListing(context.Background())
+		if err := c.Save(); err != nil {
+			t.Fatalf("failed to save the cassette: %v", err)
+		}
+		if c.Mode == cassette.Record {
+			return
+		}
+
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 5 {
+			t.Fatalf("expected 5 saved listings, got %d", len(savedListings.Property))
+		}
+		AssertArrayEqual(t, mDB.Tags["20001234"], []string{"garage", "renovated"})
+		price, _, _ := mDB.ReadLatestPrice(context.Background(), "20001255")
+		if price != 379900 {
+			t.Errorf("got price %d, want 379900", price)
+		}
+		runs, _ := mDB.ReadCollectionRuns(context.Background(), 1)
+		if r := runs.Run[0]; r.Pages != 3 || r.NewListings != 5 || r.HttpErrors != 0 {
+			t.Errorf("unexpected run %v", r)
+		}
+	})
+}
+
+func TestCrawl(t *testing.T) {
+	t.Run("can resume a run from the checkpoint", func(t *testing.T) {
+		pages := []string{}
+		failPage := "2"
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			page := r.FormValue("CurrentPage")
+			pages = append(pages, page)
+			if page == failPage {
+				return &http.Response{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}
+			}
+			return pageResponse("page-"+page, 3)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c)
+		m.FetchListing(context.Background())
+
+		checkpoint, _ := mDB.ReadCheckpoint(context.Background(), source)
+		if checkpoint == nil || checkpoint.Region != "windsor" || checkpoint.Tile != 0 || checkpoint.Page != 2 {
+			t.Fatalf("unexpected checkpoint %v", checkpoint)
+		}
+
+		failPage = ""
+		m.FetchListing(context.Background())
+		AssertArrayEqual(t, pages, []string{"1", "2", "2", "3"})
+		if checkpoint, _ := mDB.ReadCheckpoint(context.Background(), source); checkpoint != nil {
+			t.Errorf("expected the checkpoint of a completed run to be deleted, got %v", checkpoint)
+		}
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 3 {
+			t.Errorf("expected 3 saved listings, got %d", len(savedListings.Property))
+		}
+	})
+
+	t.Run("stop a cancelled run and resume it from the checkpoint", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		pages := []string{}
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			page := r.FormValue("CurrentPage")
+			pages = append(pages, page)
+			// The indexer shuts down while the first page is fetched.
+			cancel()
+			return pageResponse("page-"+page, 3)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c)
+		m.FetchListing(ctx)
+
+		runs, _ := mDB.ReadCollectionRuns(context.Background(), 0)
+		if len(runs.Run) != 1 || runs.Run[0].Pages != 1 || runs.Run[0].Error == "" {
+			t.Fatalf("expected the cancelled run to be recorded, got %v", runs.Run)
+		}
+		checkpoint, _ := mDB.ReadCheckpoint(context.Background(), source)
+		if checkpoint == nil || checkpoint.Page != 2 {
+			t.Fatalf("unexpected checkpoint %v", checkpoint)
+		}
+
+		m.FetchListing(context.Background())
+		AssertArrayEqual(t, pages, []string{"1", "2", "3"})
+	})
+
+	t.Run("can crawl every tile of the regions", func(t *testing.T) {
+		boxes := map[string]bool{}
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			box := strings.Join([]string{r.FormValue("LatitudeMin"), r.FormValue("LongitudeMin")}, ",")
+			boxes[box] = true
+			return pageResponse(box, 1)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		m := NewMls(mDB, c)
+		m.Regions = []Region{
+			{Name: "a", LatitudeMin: 0, LatitudeMax: 2, LongitudeMin: 0, LongitudeMax: 2, Tiles: 2},
+			{Name: "b", LatitudeMin: 10, LatitudeMax: 11, LongitudeMin: 10, LongitudeMax: 11},
+		}
+		m.FetchListing(context.Background())
+
+		if len(boxes) != 5 {
+			t.Errorf("expected 5 tiles crawled, got %v", boxes)
+		}
+		if !boxes["1.0000000,1.0000000"] || !boxes["10.0000000,10.0000000"] {
+			t.Errorf("unexpected tiles %v", boxes)
+		}
+	})
+
+	t.Run("delist the listings not seen by a complete run only", func(t *testing.T) {
+		fail := true
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			if fail && r.FormValue("CurrentPage") == "2" {
+				return &http.Response{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}
+			}
+			return pageResponse("page-"+r.FormValue("CurrentPage"), 2)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		mDB.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "sold", Source: source})
+		m := NewMls(mDB, c)
+
+		status := func() map[string]string {
+			status := map[string]string{}
+			savedListings, _ := mDB.ReadListings(context.Background())
+			for _, p := range savedListings.Property {
+				status[p.MlsNumber] = p.Status
+			}
+			return status
+		}
+
+		m.FetchListing(context.Background())
+		if s := status(); s["sold"] != "Open" {
+			t.Errorf("expected no delisting before the run completes, got %v", s)
+		}
+
+		fail = false
+		m.FetchListing(context.Background())
+		if s := status(); s["sold"] != "Delisted" || s["page-1"] != "Open" || s["page-2"] != "Open" {
+			t.Errorf("unexpected status %v", s)
+		}
+	})
+
+	t.Run("record the collection runs", func(t *testing.T) {
+		fail := true
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			if fail && r.FormValue("CurrentPage") == "2" {
+				return &http.Response{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}
+			}
+			return pageResponse("page-"+r.FormValue("CurrentPage"), 2)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		mDB.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "sold", Source: source})
+		m := NewMls(mDB, c)
+
+		m.FetchListing(context.Background())
+		fail = false
+		m.FetchListing(context.Background())
+
+		runs, _ := mDB.ReadCollectionRuns(context.Background(), 0)
+		if len(runs.Run) != 2 {
+			t.Fatalf("expected 2 runs, got %d", len(runs.Run))
+		}
+		// Both runs may start within the same second.
+		completed, failed := runs.Run[0], runs.Run[1]
+		if completed.Error != "" {
+			completed, failed = failed, completed
+		}
+		if failed.Pages != 1 || failed.HttpErrors != 1 || failed.NewListings != 1 || failed.Error == "" {
+			t.Errorf("unexpected failed run %v", failed)
+		}
+		if completed.Pages != 1 || completed.HttpErrors != 0 || completed.NewListings != 1 ||
+			completed.UpdatedListings != 0 || completed.DelistedListings != 1 || completed.Error != "" {
+			t.Errorf("unexpected completed run %v", completed)
+		}
+		AssertArrayEqual(t, completed.Region, []string{"windsor"})
+		if completed.Source != source || completed.EndedAt < completed.StartedAt {
+			t.Errorf("unexpected completed run %v", completed)
+		}
+	})
+
+	t.Run("stop an incremental run after enough known and unchanged listings", func(t *testing.T) {
+		pages := 0
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			pages++
+			page, _ := strconv.Atoi(r.FormValue("CurrentPage"))
+			if r.FormValue("Sort") != "6-D" {
+				t.Errorf("expected the newest listings first, got sort %q", r.FormValue("Sort"))
+			}
+			if page <= 3 {
+				return pageResponse(fmt.Sprintf("new-%d", page), 10)
+			}
+			return pageResponse(fmt.Sprintf("known-%d", page), 10)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		for page := 4; page <= 10; page++ {
+			mDB.SaveNewListing(context.Background(), &mlspb.Property{
+				MlsNumber: fmt.Sprintf("known-%d", page),
+				Source:    source,
+				Price:     []*mlspb.PriceHistory{{Price: 10000, Timestamp: 1}},
+			})
+		}
+		m := NewMls(mDB, c)
+		m.Incremental = true
+		m.StopAfterKnown = 2
+		m.FetchListing(context.Background())
+
+		if pages != 5 {
+			t.Errorf("expected 5 pages fetched, got %d", pages)
+		}
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 10 {
+			t.Errorf("expected 10 listings, got %d", len(savedListings.Property))
+		}
+		for _, p := range savedListings.Property {
+			if strings.HasPrefix(p.MlsNumber, "known") && len(p.Price) != 1 {
+				t.Errorf("expected the unchanged listing %s not to be updated, got prices %v", p.MlsNumber, p.Price)
+			}
+		}
+		if checkpoint, _ := mDB.ReadCheckpoint(context.Background(), source); checkpoint != nil {
+			t.Errorf("expected no checkpoint from an incremental run, got %v", checkpoint)
+		}
+	})
+
+	t.Run("update a known listing with a new price in an incremental run", func(t *testing.T) {
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			return pageResponse("known", 1)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		mDB.SaveNewListing(context.Background(), &mlspb.Property{
+			MlsNumber: "known",
+			Source:    source,
+			Price:     []*mlspb.PriceHistory{{Price: 12000, Timestamp: 1}},
+		})
+		m := NewMls(mDB, c)
+		m.Incremental = true
+		m.FetchListing(context.Background())
+
+		if price, _, _ := mDB.ReadLatestPrice(context.Background(), "known"); price != 10000 {
+			t.Errorf("expected the new price to be saved, got %d", price)
+		}
+	})
+
+	t.Run("skip delisting when a tile has more pages than the cap", func(t *testing.T) {
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			return pageResponse("page-"+r.FormValue("CurrentPage"), 5)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		mDB.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "sold", Source: source})
+		m := NewMls(mDB, c)
+		m.MaxPages = 2
+		m.FetchListing(context.Background())
+
+		savedListings, _ := mDB.ReadListings(context.Background())
+		for _, p := range savedListings.Property {
+			if p.Status != "Open" {
+				t.Errorf("expected listing %s to stay open, got %s", p.MlsNumber, p.Status)
+			}
+		}
+	})
+
+	t.Run("skip delisting when a page is an error or empty", func(t *testing.T) {
+		responses := map[string]*http.Response{
+			"error status": {StatusCode: 503, Body: ioutil.NopCloser(bytes.NewBufferString(`{"ErrorCode":{"Id":503}}`)), Header: make(http.Header)},
+			"null body":    {StatusCode: 200, Body: ioutil.NopCloser(bytes.NewBufferString(`null`)), Header: make(http.Header)},
+		}
+		for name, resp := range responses {
+			resp := resp
+			c := NewTestClient(func(r *http.Request) *http.Response {
+				return resp
+			})
+			mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+			mDB.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "open", Source: source})
+			m := NewMls(mDB, c)
+			m.FetchListing(context.Background())
+
+			p, err := mDB.ReadListing(context.Background(), "open")
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			if p.Status != "Open" {
+				t.Errorf("%s: expected the listing to stay open, got %s", name, p.Status)
+			}
+		}
+	})
+
+	t.Run("skip delisting when a resumed run had a tile with more pages than the cap", func(t *testing.T) {
+		fail, firstLatitude := true, ""
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			if firstLatitude == "" {
+				firstLatitude = r.FormValue("LatitudeMin")
+			}
+			// The run stops at the first tile of the second row, after the
+			// tiles of the first row overflowed.
+			if fail && r.FormValue("LatitudeMin") != firstLatitude {
+				fail = false
+				return &http.Response{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}
+			}
+			pages := 1
+			if r.FormValue("LatitudeMin") == firstLatitude {
+				pages = 5
+			}
+			return pageResponse("page-"+r.FormValue("LatitudeMin")+"-"+r.FormValue("CurrentPage"), pages)
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		mDB.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "sold", Source: source})
+		m := NewMls(mDB, c)
+		m.MaxPages = 2
+		m.Regions = []Region{{Name: "a", LatitudeMin: 0, LatitudeMax: 2, LongitudeMin: 0, LongitudeMax: 1, Tiles: 2}}
+		m.FetchListing(context.Background())
+
+		checkpoint, _ := mDB.ReadCheckpoint(context.Background(), source)
+		if checkpoint == nil || checkpoint.Tile == 0 || checkpoint.Covered {
+			t.Fatalf("expected an uncovered checkpoint past the first tile, got %v", checkpoint)
+		}
+
+		m.FetchListing(context.Background())
+		if checkpoint, _ := mDB.ReadCheckpoint(context.Background(), source); checkpoint != nil {
+			t.Fatalf("expected the resumed run to complete, got %v", checkpoint)
+		}
+		savedListings, _ := mDB.ReadListings(context.Background())
+		for _, p := range savedListings.Property {
+			if p.Status != "Open" {
+				t.Errorf("expected listing %s to stay open, got %s", p.MlsNumber, p.Status)
+			}
+		}
+	})
 }
 
 func TestFormatListing(t *testing.T) {
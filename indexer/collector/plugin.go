@@ -0,0 +1,297 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/sirupsen/logrus"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+// The plugin protocol lets a standalone executable scrape a source while the
+// indexer keeps storage, validation and scheduling. The indexer writes a start
+// frame to the plugin's stdin, then reads one JSON frame per line from its
+// stdout:
+//
+//	{"type": "handshake", "protocol": 1, "source": "my-source"}
+//	{"type": "listing", "listing": {"mlsNumber": "123", ...}}
+//	{"type": "progress", "message": "page 2", "done": 20, "total": 100}
+//	{"type": "error", "message": "page 3 timed out"}
+//	{"type": "done"}
+//
+// The handshake must be the first frame, the listing is a mlspb.Property in
+// the protobuf JSON mapping. Anything the plugin writes to stderr is logged.
+const (
+	PluginProtocol = 1
+
+	FrameStart     = "start"
+	FrameHandshake = "handshake"
+	FrameListing   = "listing"
+	FrameProgress  = "progress"
+	FrameError     = "error"
+	FrameDone      = "done"
+
+	defaultPluginTimeout = 30 * time.Minute
+)
+
+// PluginFrame is a message exchanged with a plugin.
+type PluginFrame struct {
+	Type     string `json:"type"`
+	Protocol int    `json:"protocol,omitempty"`
+	// Source is the name the plugin collects for, sent with the handshake.
+	Source string `json:"source,omitempty"`
+	// Config is sent to the plugin with the start frame.
+	Config  map[string]string `json:"config,omitempty"`
+	Listing json.RawMessage   `json:"listing,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Done    int               `json:"done,omitempty"`
+	Total   int               `json:"total,omitempty"`
+}
+
+// PluginConfig configures one plugin executable.
+type PluginConfig struct {
+	// Name is the name the collector is registered and saved under.
+	Name   string            `json:"name"`
+	Path   string            `json:"path"`
+	Args   []string          `json:"args"`
+	Config map[string]string `json:"config"`
+	// Timeout is a duration such as "10m", the default is 30 minutes.
+	Timeout string `json:"timeout"`
+}
+
+// PluginResult summarizes a plugin run.
+type PluginResult struct {
+	Saved    int
+	Rejected int
+	Errors   []string
+}
+
+// Plugin is a collector running an out-of-process plugin executable.
+type Plugin struct {
+	DB      storage.DBInterface
+	Config  PluginConfig
+	Timeout time.Duration
+	log     logrus.FieldLogger
+}
+
+func newPluginCollector(config Config, deps Deps) (Collector, error) {
+	var options PluginConfig
+	if err := decodeOptions(config, &options); err != nil {
+		return nil, err
+	}
+	options.Name = config.Name
+
+	p, err := NewPlugin(options, deps.DB)
+	if err != nil {
+		return nil, err
+	}
+	p.log = deps.Logger
+	return p, nil
+}
+
+// PluginConfigs returns the config of a plugin collector for every plugin of
+// the JSON list at path.
+func PluginConfigs(path string) ([]Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins %q: %v", path, err)
+	}
+	var plugins []PluginConfig
+	if err := json.Unmarshal(content, &plugins); err != nil {
+		return nil, fmt.Errorf("failed to parse plugins %q: %v", path, err)
+	}
+	configs := []Config{}
+	for _, p := range plugins {
+		options, err := json.Marshal(p)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, Config{Type: "plugin", Name: p.Name, Options: options})
+	}
+	return configs, nil
+}
+
+// NewPlugin creates a collector saving the listings of the plugin executable
+// to s.
+func NewPlugin(config PluginConfig, s storage.DBInterface) (*Plugin, error) {
+	if s == nil {
+		return nil, fmt.Errorf("plugin %q needs a DB", config.Name)
+	}
+	if config.Name == "" || config.Path == "" {
+		return nil, fmt.Errorf("a plugin needs a name and a path")
+	}
+
+	timeout := defaultPluginTimeout
+	if config.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return nil, fmt.Errorf("plugin %q: invalid timeout: %v", config.Name, err)
+		}
+	}
+
+	return &Plugin{
+		DB:      s,
+		Config:  config,
+		Timeout: timeout,
+		log:     logrus.StandardLogger(),
+	}, nil
+}
+
+// FetchListing runs the plugin and saves the listings it sends to DB.
+func (p *Plugin) FetchListing(ctx context.Context) {
+	result, err := p.Run(ctx)
+	if err != nil {
+		p.log.Errorf("Plugin %q failed: %v", p.Config.Name, err)
+	}
+	if result != nil {
+		p.log.Infof("Plugin %q saved %d listings, rejected %d", p.Config.Name, result.Saved, result.Rejected)
+	}
+}
+
+// Run runs the plugin to completion, or until ctx is done. The result counts
+// the listings saved before any error.
+func (p *Plugin) Run(ctx context.Context) (*PluginResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, p.Config.Path, p.Config.Args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stderr = &pluginLog{p.log}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %q: %v", p.Config.Path, err)
+	}
+
+	start := PluginFrame{Type: FrameStart, Protocol: PluginProtocol, Source: p.Config.Name, Config: p.Config.Config}
+	if err := json.NewEncoder(stdin).Encode(start); err != nil {
+		p.log.Errorf("Plugin %q: failed to send the start frame: %v", p.Config.Name, err)
+	}
+	stdin.Close()
+
+	result, readErr := p.readFrames(ctx, stdout)
+	if readErr != nil {
+		// Stop a plugin breaking the protocol.
+		cancel()
+	}
+	// Wait needs the output fully read.
+	io.Copy(ioutil.Discard, stdout)
+	waitErr := cmd.Wait()
+	if readErr != nil {
+		return result, readErr
+	}
+	if waitErr != nil {
+		return result, fmt.Errorf("plugin exited: %v", waitErr)
+	}
+	return result, nil
+}
+
+func (p *Plugin) readFrames(ctx context.Context, r io.Reader) (*PluginResult, error) {
+	result := &PluginResult{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	handshake := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var frame PluginFrame
+		if err := json.Unmarshal([]byte(line), &frame); err != nil {
+			return result, fmt.Errorf("invalid frame %q: %v", line, err)
+		}
+
+		if !handshake {
+			if frame.Type != FrameHandshake {
+				return result, fmt.Errorf("expected a handshake, got %q", frame.Type)
+			}
+			if frame.Protocol != PluginProtocol {
+				return result, fmt.Errorf("unsupported protocol %d, want %d", frame.Protocol, PluginProtocol)
+			}
+			handshake = true
+			continue
+		}
+
+		switch frame.Type {
+		case FrameListing:
+			if err := p.saveFrame(ctx, frame); err != nil {
+				p.log.Errorf("Plugin %q: %v", p.Config.Name, err)
+				result.Rejected++
+				continue
+			}
+			result.Saved++
+		case FrameProgress:
+			p.log.Infof("Plugin %q: %s (%d/%d)", p.Config.Name, frame.Message, frame.Done, frame.Total)
+		case FrameError:
+			p.log.Errorf("Plugin %q reported: %s", p.Config.Name, frame.Message)
+			result.Errors = append(result.Errors, frame.Message)
+		case FrameDone:
+			return result, nil
+		default:
+			return result, fmt.Errorf("unknown frame type %q", frame.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, err
+	}
+	if !handshake {
+		return result, fmt.Errorf("plugin exited without a handshake")
+	}
+	return result, fmt.Errorf("plugin exited without a done frame")
+}
+
+// saveFrame validates the listing of a frame and saves it under the plugin's
+// name.
+func (p *Plugin) saveFrame(ctx context.Context, frame PluginFrame) error {
+	property := &mlspb.Property{}
+	if err := jsonpb.UnmarshalString(string(frame.Listing), property); err != nil {
+		return fmt.Errorf("invalid listing: %v", err)
+	}
+	if property.MlsNumber == "" {
+		return fmt.Errorf("listing without mls number")
+	}
+	for _, price := range property.Price {
+		if price.Price < 0 {
+			return fmt.Errorf("listing %s: invalid price %d", property.MlsNumber, price.Price)
+		}
+		if price.Timestamp == 0 {
+			price.Timestamp = time.Now().Unix()
+		}
+	}
+	property.Source = p.Config.Name
+
+	_, err := saveListing(ctx, p.DB, property)
+	return err
+}
+
+// pluginLog logs what a plugin writes to stderr.
+type pluginLog struct {
+	log logrus.FieldLogger
+}
+
+func (l *pluginLog) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		l.log.Debugf("Plugin stderr: %s", line)
+	}
+	return len(b), nil
+}
+
+// GetDB retrieves the DB instance
+func (p *Plugin) GetDB() storage.DBInterface {
+	return p.DB
+}
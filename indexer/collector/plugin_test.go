@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+// TestPluginHelperProcess is not a real test, it is the plugin executable run
+// by the plugin tests. It answers the start frame as the "mode" of its config
+// asks.
+func TestPluginHelperProcess(t *testing.T) {
+	if len(os.Args) < 2 || os.Args[len(os.Args)-1] != "plugin-helper" {
+		return
+	}
+	defer os.Exit(0)
+
+	var start PluginFrame
+	if err := json.NewDecoder(os.Stdin).Decode(&start); err != nil || start.Type != FrameStart {
+		fmt.Fprintf(os.Stderr, "bad start frame: %v", err)
+		os.Exit(2)
+	}
+
+	switch start.Config["mode"] {
+	case "no-handshake":
+		fmt.Println(`{"type": "done"}`)
+	case "wrong-protocol":
+		fmt.Println(`{"type": "handshake", "protocol": 99}`)
+	case "crash":
+		fmt.Println(`{"type": "handshake", "protocol": 1}`)
+		fmt.Println(`{"type": "listing", "listing": {"mlsNumber": "1"}}`)
+		os.Exit(1)
+	default:
+		fmt.Println(`{"type": "handshake", "protocol": 1, "source": "helper"}`)
+		fmt.Println(`{"type": "progress", "message": "page 1", "done": 0, "total": 3}`)
+		fmt.Println(`{"type": "listing", "listing": {"mlsNumber": "19016318", "address": "1234 street|city, province A0B1C2", "price": [{"price": 10000}], "source": "spoofed"}}`)
+		fmt.Println(`{"type": "listing", "listing": {"address": "no mls number"}}`)
+		fmt.Println(`{"type": "listing", "listing": {"mlsNumber": "19016319", "price": [{"price": -5}]}}`)
+		fmt.Println(`{"type": "error", "message": "page 2 timed out"}`)
+		fmt.Println(`{"type": "done"}`)
+	}
+}
+
+func helperPlugin(t *testing.T, mode string) *Plugin {
+	t.Helper()
+	mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+	p, err := NewPlugin(PluginConfig{
+		Name:   "test-plugin",
+		Path:   os.Args[0],
+		Args:   []string{"-test.run=TestPluginHelperProcess", "--", "plugin-helper"},
+		Config: map[string]string{"mode": mode},
+	}, mDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPlugin(t *testing.T) {
+	t.Run("can save the listings of a plugin", func(t *testing.T) {
+		p := helperPlugin(t, "ok")
+		result, err := p.Run(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if result.Saved != 1 || result.Rejected != 2 || len(result.Errors) != 1 {
+			t.Errorf("unexpected result %+v", result)
+		}
+
+		savedListings, _ := p.GetDB().ReadListings(context.Background())
+		if len(savedListings.Property) != 1 {
+			t.Fatalf("expected 1 saved listing, got %d", len(savedListings.Property))
+		}
+		l := savedListings.Property[0]
+		AssertStringEqual(t, l.MlsNumber, "19016318")
+		AssertStringEqual(t, l.Source, "test-plugin")
+		if l.Price[0].Timestamp == 0 {
+			t.Error("expected the price to be timestamped")
+		}
+	})
+
+	t.Run("reject a plugin breaking the protocol", func(t *testing.T) {
+		for _, mode := range []string{"no-handshake", "wrong-protocol"} {
+			if _, err := helperPlugin(t, mode).Run(context.Background()); err == nil {
+				t.Errorf("%s: expected an error", mode)
+			}
+		}
+	})
+
+	t.Run("keep the listings of a crashed plugin", func(t *testing.T) {
+		p := helperPlugin(t, "crash")
+		result, err := p.Run(context.Background())
+		if err == nil {
+			t.Error("expected an error")
+		}
+		if result.Saved != 1 {
+			t.Errorf("expected 1 saved listing, got %d", result.Saved)
+		}
+	})
+
+	t.Run("reject a plugin config without a path", func(t *testing.T) {
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		if _, err := NewPlugin(PluginConfig{Name: "no-path"}, mDB); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("reject a plugin without a DB", func(t *testing.T) {
+		if _, err := NewPlugin(PluginConfig{Name: "no-db", Path: "/bin/true"}, nil); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestPluginConfigs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("can configure the plugin collectors of a plugin list", func(t *testing.T) {
+		path := filepath.Join(dir, "plugins.json")
+		plugins := `[{"name": "plugin-a", "path": "/bin/a", "timeout": "1m"}, {"name": "plugin-b", "path": "/bin/b"}]`
+		if err := ioutil.WriteFile(path, []byte(plugins), 0644); err != nil {
+			t.Fatal(err)
+		}
+		configs, err := PluginConfigs(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		collectors, err := NewCollectors(configs, Deps{DB: mDB})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(collectors) != 2 {
+			t.Fatalf("expected 2 collectors, got %d", len(collectors))
+		}
+		if p := collectors["plugin-a"].(*Plugin); p.Config.Path != "/bin/a" || p.Timeout.Minutes() != 1 {
+			t.Errorf("unexpected plugin %+v", p)
+		}
+	})
+
+	t.Run("reject a missing plugin list", func(t *testing.T) {
+		if _, err := PluginConfigs(filepath.Join(dir, "missing.json")); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,348 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+const (
+	resoSource = "reso"
+	// resoMaxPages guards against a server that never stops paging.
+	resoMaxPages = 1000
+)
+
+var (
+	// resoSelect lists the RESO Data Dictionary Property fields mapped onto
+	// mlspb.Property.
+	resoSelect = []string{
+		"ListingKey", "ListingId", "ListPrice", "PropertyType", "PropertySubType",
+		"UnparsedAddress", "City", "StateOrProvince", "PostalCode", "Latitude",
+		"Longitude", "BedroomsTotal", "BathroomsTotalInteger", "Stories",
+		"LotSizeArea", "LotSizeUnits", "ParkingFeatures", "PublicRemarks",
+		"OnMarketDate", "StandardStatus",
+	}
+
+	// resoStatuses maps the RESO StandardStatus onto the listing status, the
+	// other statuses keep the saved status.
+	resoStatuses = map[string]string{
+		"Active":              "Open",
+		"ActiveUnderContract": "Pending",
+		"Pending":             "Pending",
+		"Closed":              "Sold",
+		"Withdrawn":           "Delisted",
+		"Canceled":            "Delisted",
+		"Expired":             "Delisted",
+	}
+)
+
+// resoOptions are the config options of the RESO collector.
+type resoOptions struct {
+	URL string `json:"url"`
+	// Token defaults to the RESO_API_TOKEN environment variable so it can be
+	// kept out of the config file.
+	Token    string `json:"token"`
+	Filter   string `json:"filter"`
+	PageSize int    `json:"page_size"`
+}
+
+func newResoCollector(config Config, deps Deps) (Collector, error) {
+	var options resoOptions
+	if err := decodeOptions(config, &options); err != nil {
+		return nil, err
+	}
+	if options.URL == "" {
+		return nil, fmt.Errorf("missing url")
+	}
+	if options.Token == "" {
+		options.Token = os.Getenv("RESO_API_TOKEN")
+	}
+
+	r := NewReso(options.URL, options.Token, deps.DB, deps.Client)
+	r.log = deps.Logger
+	if options.Filter != "" {
+		r.Filter = options.Filter
+	}
+	if options.PageSize > 0 {
+		r.PageSize = options.PageSize
+	}
+	return r, nil
+}
+
+type resoMedia struct {
+	MediaURL string `json:"MediaURL"`
+	Order    int    `json:"Order"`
+}
+
+// resoProperty is the subset of the RESO Data Dictionary Property resource
+// used by the collector.
+type resoProperty struct {
+	ListingKey            string      `json:"ListingKey"`
+	ListingID             string      `json:"ListingId"`
+	ListPrice             float64     `json:"ListPrice"`
+	PropertyType          string      `json:"PropertyType"`
+	PropertySubType       string      `json:"PropertySubType"`
+	UnparsedAddress       string      `json:"UnparsedAddress"`
+	City                  string      `json:"City"`
+	StateOrProvince       string      `json:"StateOrProvince"`
+	PostalCode            string      `json:"PostalCode"`
+	Latitude              float64     `json:"Latitude"`
+	Longitude             float64     `json:"Longitude"`
+	BedroomsTotal         *int        `json:"BedroomsTotal"`
+	BathroomsTotalInteger *int        `json:"BathroomsTotalInteger"`
+	Stories               *float64    `json:"Stories"`
+	LotSizeArea           *float64    `json:"LotSizeArea"`
+	LotSizeUnits          string      `json:"LotSizeUnits"`
+	ParkingFeatures       []string    `json:"ParkingFeatures"`
+	PublicRemarks         string      `json:"PublicRemarks"`
+	OnMarketDate          string      `json:"OnMarketDate"`
+	StandardStatus        string      `json:"StandardStatus"`
+	Media                 []resoMedia `json:"Media"`
+}
+
+type resoPage struct {
+	Value    []resoProperty `json:"value"`
+	NextLink string         `json:"@odata.nextLink"`
+}
+
+// Reso collects listings from a RESO Web API (OData) server.
+type Reso struct {
+	DB storage.DBInterface
+	// Endpoint is the OData service root, the Property resource is under it.
+	Endpoint string
+	// Token is sent as the bearer token when it is set.
+	Token string
+	// Filter is the OData $filter applied to the Property resource. It
+	// fetches the closed and withdrawn listings too so the saved ones leave
+	// the open listings, narrow it on a feed keeping every closed listing.
+	Filter string
+	// PageSize is the $top of each request.
+	PageSize int
+	client   *http.Client
+	log      logrus.FieldLogger
+}
+
+// NewReso creates a new client for a RESO Web API collector saving to s.
+func NewReso(endpoint, token string, s storage.DBInterface, c *http.Client) *Reso {
+	if c == nil {
+		c = &http.Client{}
+	}
+
+	return &Reso{
+		DB:       s,
+		Endpoint: strings.TrimSuffix(endpoint, "/"),
+		Token:    token,
+		Filter:   "StandardStatus eq 'Active' or StandardStatus eq 'Pending' or StandardStatus eq 'Closed' or StandardStatus eq 'Withdrawn'",
+		PageSize: 200,
+		client:   c,
+		log:      logrus.StandardLogger(),
+	}
+}
+
+func (r *Reso) firstPageURL() string {
+	query := url.Values{}
+	if r.Filter != "" {
+		query.Set("$filter", r.Filter)
+	}
+	query.Set("$select", strings.Join(resoSelect, ","))
+	query.Set("$expand", "Media($select=MediaURL,Order)")
+	query.Set("$top", strconv.Itoa(r.PageSize))
+	query.Set("$skip", "0")
+	return r.Endpoint + "/Property?" + query.Encode()
+}
+
+// nextPageURL follows @odata.nextLink when the server gives one, otherwise
+// pages with $skip until a page is not full.
+func (r *Reso) nextPageURL(current string, page *resoPage) (string, error) {
+	if page.NextLink != "" {
+		next, err := url.Parse(page.NextLink)
+		if err != nil {
+			return "", fmt.Errorf("invalid @odata.nextLink %q: %v", page.NextLink, err)
+		}
+		base, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(next).String(), nil
+	}
+	if len(page.Value) < r.PageSize {
+		return "", nil
+	}
+
+	u, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	skip, _ := strconv.Atoi(query.Get("$skip"))
+	query.Set("$skip", strconv.Itoa(skip+len(page.Value)))
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func (r *Reso) fetchPage(ctx context.Context, pageURL string) (*resoPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP get error: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, pageURL)
+	}
+
+	var page *resoPage
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to parse the json response into listing: %v", err)
+	}
+	return page, nil
+}
+
+// FetchListing retrieves every page of the Property resource and saves the
+// listings to DB.
+func (r *Reso) FetchListing(ctx context.Context) {
+	run := startRun(ctx, r.DB, r.log, resoSource)
+	err := r.fetchAll(run)
+	if err != nil {
+		r.log.Error(err)
+	}
+	run.finish(err)
+}
+
+func (r *Reso) fetchAll(run *run) error {
+	pageURL := r.firstPageURL()
+	for i := 0; pageURL != "" && i < resoMaxPages; i++ {
+		page, err := r.fetchPage(run.ctx, pageURL)
+		if err != nil {
+			run.failed()
+			return fmt.Errorf("failed to fetch the %q listings: %v", resoSource, err)
+		}
+		run.fetched()
+
+		for _, l := range page.Value {
+			p := formatResoListing(l)
+			if p.MlsNumber == "" {
+				r.log.Errorf("Skip a %q listing without listing id", resoSource)
+				continue
+			}
+			if _, err := run.save(p); err != nil {
+				r.log.Error(err)
+			}
+		}
+
+		if pageURL, err = r.nextPageURL(pageURL, page); err != nil {
+			return fmt.Errorf("failed to page the %q listings: %v", resoSource, err)
+		}
+	}
+	return nil
+}
+
+func formatResoListing(l resoProperty) *mlspb.Property {
+	mlsNumber := strings.TrimSpace(l.ListingID)
+	if mlsNumber == "" {
+		mlsNumber = strings.TrimSpace(l.ListingKey)
+	}
+
+	houseType := strings.TrimSpace(l.PropertySubType)
+	if houseType == "" {
+		houseType = strings.TrimSpace(l.PropertyType)
+	}
+
+	parkings := []string{}
+	for _, p := range l.ParkingFeatures {
+		parkings = append(parkings, strings.TrimSpace(p))
+	}
+
+	sort.SliceStable(l.Media, func(i, j int) bool { return l.Media[i].Order < l.Media[j].Order })
+	photos := []string{}
+	for _, m := range l.Media {
+		if m.MediaURL != "" {
+			photos = append(photos, strings.TrimSpace(m.MediaURL))
+		}
+	}
+
+	listTimestamp := int64(0)
+	if t, err := time.Parse("2006-01-02", l.OnMarketDate); err == nil {
+		listTimestamp = t.Unix()
+	}
+
+	city := strings.TrimSpace(l.City)
+	state := strings.TrimSpace(l.StateOrProvince)
+	zipcode := strings.TrimSpace(l.PostalCode)
+
+	return &mlspb.Property{
+		// Keep the "street|city, state zipcode" address format of the other
+		// collectors.
+		Address:       fmt.Sprintf("%s|%s, %s %s", strings.TrimSpace(l.UnparsedAddress), city, state, zipcode),
+		Bathrooms:     optionalInt(l.BathroomsTotalInteger),
+		Bedrooms:      optionalInt(l.BedroomsTotal),
+		LandSize:      lotSize(l.LotSizeArea, l.LotSizeUnits),
+		MlsId:         strings.TrimSpace(l.ListingKey),
+		MlsNumber:     mlsNumber,
+		Parking:       parkings,
+		PhotoUrl:      photos,
+		Price:         []*mlspb.PriceHistory{{Price: int32(l.ListPrice), Timestamp: time.Now().Unix()}},
+		PublicRemarks: strings.TrimSpace(l.PublicRemarks),
+		Stories:       optionalFloat(l.Stories),
+		PropertyType:  houseType,
+		ListTimestamp: listTimestamp,
+		Source:        resoSource,
+		Status:        resoStatus(l.StandardStatus),
+		Latitude:      l.Latitude,
+		Longitude:     l.Longitude,
+		City:          city,
+		State:         state,
+		Zipcode:       zipcode,
+	}
+}
+
+// resoStatus maps the StandardStatus, written as its enum name or with
+// spaces, onto the listing status.
+func resoStatus(standardStatus string) string {
+	return resoStatuses[strings.Replace(strings.TrimSpace(standardStatus), " ", "", -1)]
+}
+
+func optionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func optionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func lotSize(area *float64, units string) string {
+	if area == nil {
+		return ""
+	}
+	return strings.TrimSpace(optionalFloat(area) + " " + units)
+}
+
+// GetDB retrieves the DB instance
+func (r *Reso) GetDB() storage.DBInterface {
+	return r.DB
+}
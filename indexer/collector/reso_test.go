@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+func resoListing(id string) string {
+	return fmt.Sprintf(`{
+	  "ListingKey": "key-%[1]s",
+	  "ListingId": "%[1]s",
+	  "ListPrice": 499900,
+	  "PropertyType": "Residential",
+	  "PropertySubType": "Single Family Residence",
+	  "UnparsedAddress": "1234 street",
+	  "City": "city",
+	  "StateOrProvince": "province",
+	  "PostalCode": "A0B1C2",
+	  "Latitude": 42.01,
+	  "Longitude": -82.49,
+	  "BedroomsTotal": 3,
+	  "BathroomsTotalInteger": 2,
+	  "Stories": 1.5,
+	  "LotSizeArea": 0.25,
+	  "LotSizeUnits": "Acres",
+	  "ParkingFeatures": ["Attached", "Garage"],
+	  "PublicRemarks": "HOUSE DESCRIPTION",
+	  "OnMarketDate": "2019-05-04",
+	  "StandardStatus": "Active",
+	  "Media": [
+	    {"MediaURL": "https://picture/%[1]s/2.jpg", "Order": 2},
+	    {"MediaURL": "https://picture/%[1]s/1.jpg", "Order": 1}
+	  ]
+	}`, id)
+}
+
+func TestResoFetchListing(t *testing.T) {
+	t.Run("can page with skip and save listings", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			if r.URL.Path != "/odata/Property" {
+				t.Errorf("unexpected path %s", r.URL.Path)
+			}
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				t.Errorf("unexpected authorization header %q", r.Header.Get("Authorization"))
+			}
+			query := r.URL.Query()
+			if query.Get("$filter") != "StandardStatus eq 'Active' or StandardStatus eq 'Pending' or StandardStatus eq 'Closed' or StandardStatus eq 'Withdrawn'" || query.Get("$top") != "2" {
+				t.Errorf("unexpected query %v", query)
+			}
+			switch query.Get("$skip") {
+			case "0":
+				fmt.Fprintf(w, `{"value": [%s, %s]}`, resoListing("1"), resoListing("2"))
+			case "2":
+				fmt.Fprintf(w, `{"value": [%s]}`, resoListing("3"))
+			default:
+				t.Errorf("unexpected $skip %q", query.Get("$skip"))
+			}
+		}))
+		defer server.Close()
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		r := NewReso(server.URL+"/odata/", "secret", mDB, server.Client())
+		r.PageSize = 2
+		r.FetchListing(context.Background())
+
+		if requests != 2 {
+			t.Errorf("expected 2 requests, got %d", requests)
+		}
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 3 {
+			t.Fatalf("expected 3 saved listings, got %d", len(savedListings.Property))
+		}
+	})
+
+	t.Run("can follow the next link", func(t *testing.T) {
+		var server *httptest.Server
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				fmt.Fprintf(w, `{"value": [%s]}`, resoListing("2"))
+				return
+			}
+			fmt.Fprintf(w, `{"value": [%s], "@odata.nextLink": "%s/Property?page=2"}`, resoListing("1"), server.URL)
+		}))
+		defer server.Close()
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		r := NewReso(server.URL, "", mDB, server.Client())
+		r.FetchListing(context.Background())
+
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 2 {
+			t.Fatalf("expected 2 saved listings, got %d", len(savedListings.Property))
+		}
+	})
+
+	t.Run("save the standard status", func(t *testing.T) {
+		status := "Active"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"value": [%s]}`, strings.Replace(resoListing("1"), `"Active"`, `"`+status+`"`, 1))
+		}))
+		defer server.Close()
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		r := NewReso(server.URL, "", mDB, server.Client())
+		for _, status = range []string{"Active", "Active Under Contract", "Closed", "Coming Soon"} {
+			r.FetchListing(context.Background())
+		}
+
+		p, err := mDB.ReadListing(context.Background(), "1")
+		if err != nil {
+			t.Fatalf("Failed to read the listing: %v", err)
+		}
+		statuses := []string{}
+		for _, s := range p.StatusHistory {
+			statuses = append(statuses, s.Status)
+		}
+		if p.Status != "Sold" || strings.Join(statuses, ",") != "Open,Pending,Sold" {
+			t.Errorf("expected a sold listing, got %s with history %v", p.Status, statuses)
+		}
+	})
+
+	t.Run("stop on a server error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		r := NewReso(server.URL, "wrong", mDB, server.Client())
+		r.FetchListing(context.Background())
+
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 0 {
+			t.Errorf("expected no saved listing, got %d", len(savedListings.Property))
+		}
+	})
+
+	t.Run("send no request for a cancelled run", func(t *testing.T) {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			fmt.Fprintf(w, `{"value": [%s]}`, resoListing("1"))
+		}))
+		defer server.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		NewReso(server.URL, "", mDB, server.Client()).FetchListing(ctx)
+
+		if requests != 0 {
+			t.Errorf("expected no request, got %d", requests)
+		}
+	})
+}
+
+func TestFormatResoListing(t *testing.T) {
+	t.Run("can map the RESO property", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"value": [%s]}`, resoListing("19016318"))
+		}))
+		defer server.Close()
+
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		NewReso(server.URL, "", mDB, server.Client()).FetchListing(context.Background())
+		savedListings, _ := mDB.ReadListings(context.Background())
+		p := savedListings.Property[0]
+
+		AssertStringEqual(t, p.Address, "1234 street|city, province A0B1C2")
+		AssertStringEqual(t, p.Bathrooms, "2")
+		AssertStringEqual(t, p.Bedrooms, "3")
+		AssertStringEqual(t, p.LandSize, "0.25 Acres")
+		AssertStringEqual(t, p.MlsId, "key-19016318")
+		AssertStringEqual(t, p.MlsNumber, "19016318")
+		AssertArrayEqual(t, p.Parking, []string{"Attached", "Garage"})
+		AssertArrayEqual(t, p.PhotoUrl, []string{"https://picture/19016318/1.jpg", "https://picture/19016318/2.jpg"})
+		AssertStringEqual(t, p.PublicRemarks, "HOUSE DESCRIPTION")
+		AssertStringEqual(t, p.Stories, "1.5")
+		AssertStringEqual(t, p.PropertyType, "Single Family Residence")
+		AssertStringEqual(t, p.Source, "reso")
+		AssertFloat64Equal(t, p.Latitude, 42.01)
+		AssertStringEqual(t, p.City, "city")
+		AssertStringEqual(t, p.State, "province")
+		AssertStringEqual(t, p.Zipcode, "A0B1C2")
+		if p.Price[0].Price != 499900 {
+			t.Errorf("got price %d, want %d", p.Price[0].Price, 499900)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+// run counts what a collector run did, it is saved to DB as a collection run
+// once the run finishes.
+type run struct {
+	ctx    context.Context
+	db     storage.DBInterface
+	log    logrus.FieldLogger
+	record *mlspb.CollectionRun
+}
+
+func startRun(ctx context.Context, db storage.DBInterface, log logrus.FieldLogger, source string, regions ...string) *run {
+	now := time.Now()
+	return &run{
+		ctx: ctx,
+		db:  db,
+		log: log,
+		record: &mlspb.CollectionRun{
+			RunId:     source + "-" + strconv.FormatInt(now.UnixNano(), 10),
+			Source:    source,
+			Region:    regions,
+			StartedAt: now.Unix(),
+		},
+	}
+}
+
+// fetched counts a page fetched from the source.
+func (r *run) fetched() {
+	r.record.Pages++
+}
+
+// failed counts a request to the source that failed.
+func (r *run) failed() {
+	r.record.HttpErrors++
+}
+
+// save saves a listing and counts it as new or updated.
+func (r *run) save(p *mlspb.Property) (created bool, err error) {
+	created, err = saveListing(r.ctx, r.db, p)
+	if err != nil {
+		return false, err
+	}
+	if created {
+		r.record.NewListings++
+	} else {
+		r.record.UpdatedListings++
+	}
+	return created, nil
+}
+
+// delisted counts the listings delisted by the run.
+func (r *run) delisted(n int64) {
+	r.record.DelistedListings += int32(n)
+}
+
+// finish saves the run with the error that ended it, if any.
+func (r *run) finish(err error) {
+	r.record.EndedAt = time.Now().Unix()
+	if err != nil {
+		r.record.Error = err.Error()
+	}
+	// The run is recorded even when its context is cancelled.
+	if err := r.db.SaveCollectionRun(context.Background(), r.record); err != nil {
+		r.log.Errorf("Failed to save the collection run %s: %v", r.record.RunId, err)
+	}
+}
@@ -0,0 +1,541 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+var (
+	numberPattern = regexp.MustCompile(`[0-9]+(\.[0-9]+)?`)
+
+	// transforms are the functions a field spec can apply to the values read
+	// from the source. Each column holds the values read from one path.
+	transforms = map[string]func(columns [][]string, arg string) ([][]string, error){
+		"trim":            mapValues(strings.TrimSpace),
+		"parse_price":     mapValues(parsePrice),
+		"parse_area":      mapValues(parseArea),
+		"first_non_empty": firstNonEmpty,
+		"split_address":   splitAddress,
+		"split":           split,
+	}
+)
+
+// Spec declares how a source is requested and how its JSON listings map onto
+// mlspb.Property, so simple sources can be added without writing Go.
+type Spec struct {
+	// Source is the name the collector is registered and saved under.
+	Source string `json:"source"`
+	URL    string `json:"url"`
+	// Method is GET or POST, a POST sends Form as a urlencoded body.
+	Method  string            `json:"method"`
+	Form    map[string]string `json:"form"`
+	Headers map[string]string `json:"headers"`
+	// ResultsPath is the path to the array of listings in the response.
+	ResultsPath string `json:"results_path"`
+	// Fields maps the mlspb.Property field names, ie. "mls_number", to the
+	// listing values.
+	Fields map[string]FieldSpec `json:"fields"`
+}
+
+// FieldSpec reads a field from one or more paths of a listing, ie.
+// "Property.Photo[].HighResPath", and applies the transforms in order.
+// A transform argument follows a colon, ie. "split_address:city".
+type FieldSpec struct {
+	Paths      []string `json:"paths"`
+	Transforms []string `json:"transforms"`
+}
+
+// LoadSpec reads a JSON mapping spec.
+func LoadSpec(path string) (*Spec, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec %q: %v", path, err)
+	}
+	var spec *Spec
+	if err := json.Unmarshal(content, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec %q: %v", path, err)
+	}
+	if err := spec.validate(); err != nil {
+		return nil, fmt.Errorf("invalid spec %q: %v", path, err)
+	}
+	return spec, nil
+}
+
+func (s *Spec) validate() error {
+	if s.Source == "" {
+		return fmt.Errorf("missing source")
+	}
+	if s.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+	if _, ok := s.Fields["mls_number"]; !ok {
+		return fmt.Errorf("missing the mls_number field")
+	}
+	for name, f := range s.Fields {
+		if err := setField(&mlspb.Property{}, name, nil); err != nil {
+			return err
+		}
+		for _, t := range f.Transforms {
+			if _, ok := transforms[strings.SplitN(t, ":", 2)[0]]; !ok {
+				return fmt.Errorf("field %q: unknown transform %q", name, t)
+			}
+		}
+	}
+	return nil
+}
+
+// Map converts one listing of the source into a property.
+func (s *Spec) Map(listing interface{}) (*mlspb.Property, error) {
+	p := &mlspb.Property{Source: s.Source}
+	for name, f := range s.Fields {
+		columns := [][]string{}
+		for _, path := range f.Paths {
+			columns = append(columns, lookup(listing, path))
+		}
+
+		for _, t := range f.Transforms {
+			parts := strings.SplitN(t, ":", 2)
+			arg := ""
+			if len(parts) == 2 {
+				arg = parts[1]
+			}
+			var err error
+			columns, err = transforms[parts[0]](columns, arg)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", name, err)
+			}
+		}
+
+		values := []string{}
+		for _, c := range columns {
+			values = append(values, c...)
+		}
+		if err := setField(p, name, values); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// lookup returns the values found at a dotted path, a "[]" suffix walks every
+// element of an array.
+func lookup(value interface{}, path string) []string {
+	if path == "" {
+		return []string{toString(value)}
+	}
+
+	parts := strings.SplitN(path, ".", 2)
+	key, rest := parts[0], ""
+	if len(parts) == 2 {
+		rest = parts[1]
+	}
+
+	each := strings.HasSuffix(key, "[]")
+	key = strings.TrimSuffix(key, "[]")
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	child, ok := obj[key]
+	if !ok || child == nil {
+		return nil
+	}
+
+	if !each {
+		return lookup(child, rest)
+	}
+	items, ok := child.([]interface{})
+	if !ok {
+		return nil
+	}
+	values := []string{}
+	for _, item := range items {
+		v := lookup(item, rest)
+		if len(v) == 0 {
+			// Keep the elements aligned across paths for first_non_empty.
+			v = []string{""}
+		}
+		values = append(values, v...)
+	}
+	return values
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func mapValues(fn func(string) string) func([][]string, string) ([][]string, error) {
+	return func(columns [][]string, arg string) ([][]string, error) {
+		result := make([][]string, len(columns))
+		for i, c := range columns {
+			for _, v := range c {
+				result[i] = append(result[i], fn(v))
+			}
+		}
+		return result, nil
+	}
+}
+
+// firstNonEmpty merges the columns into one, keeping at each position the
+// first non empty value, ie. the highest resolution of each photo.
+func firstNonEmpty(columns [][]string, arg string) ([][]string, error) {
+	size := 0
+	for _, c := range columns {
+		if len(c) > size {
+			size = len(c)
+		}
+	}
+
+	merged := []string{}
+	for i := 0; i < size; i++ {
+		for _, c := range columns {
+			if i < len(c) && strings.TrimSpace(c[i]) != "" {
+				merged = append(merged, c[i])
+				break
+			}
+		}
+	}
+	return [][]string{merged}, nil
+}
+
+// split breaks every value on the separator given as argument, ie. a
+// "Garage;Gravel" spreadsheet cell. The separator defaults to a comma.
+func split(columns [][]string, arg string) ([][]string, error) {
+	if arg == "" {
+		arg = ","
+	}
+	result := make([][]string, len(columns))
+	for i, c := range columns {
+		for _, v := range c {
+			for _, part := range strings.Split(v, arg) {
+				if part = strings.TrimSpace(part); part != "" {
+					result[i] = append(result[i], part)
+				}
+			}
+		}
+	}
+	return result, nil
+}
+
+// splitAddress extracts one part of an address in the
+// "street|city, state zipcode" format.
+func splitAddress(columns [][]string, arg string) ([][]string, error) {
+	switch arg {
+	case "street", "city", "state", "zipcode":
+	default:
+		return nil, fmt.Errorf("split_address needs one of street, city, state or zipcode, got %q", arg)
+	}
+
+	result := make([][]string, len(columns))
+	for i, c := range columns {
+		for _, v := range c {
+			var city, state, zipcode string
+			cityInfo := strings.Split(v, "|")
+			street := strings.TrimSpace(cityInfo[0])
+			if len(cityInfo) == 2 {
+				cityStateZipcode := strings.Fields(cityInfo[1])
+				if len(cityStateZipcode) > 0 {
+					city = strings.TrimSuffix(cityStateZipcode[0], ",")
+				}
+				if len(cityStateZipcode) > 1 {
+					state = cityStateZipcode[1]
+				}
+				if len(cityStateZipcode) > 2 {
+					zipcode = strings.Join(cityStateZipcode[2:], "")
+				}
+			}
+			part := map[string]string{"street": street, "city": city, "state": state, "zipcode": zipcode}[arg]
+			result[i] = append(result[i], part)
+		}
+	}
+	return result, nil
+}
+
+// parsePrice turns "$10,000" into "10000", unknown prices become "".
+func parsePrice(v string) string {
+	n := numberPattern.FindString(strings.ReplaceAll(v, ",", ""))
+	if n == "" {
+		return ""
+	}
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		return ""
+	}
+	return strconv.Itoa(int(math.Round(f)))
+}
+
+// parseArea normalizes a land or floor area to square feet, ie.
+// "50 x 120 ft" or "0.25 ac". Unknown areas become "".
+func parseArea(v string) string {
+	v = strings.ToLower(strings.ReplaceAll(v, ",", ""))
+	numbers := numberPattern.FindAllString(v, -1)
+	if len(numbers) == 0 {
+		return ""
+	}
+
+	area, err := strconv.ParseFloat(numbers[0], 64)
+	if err != nil {
+		return ""
+	}
+	if len(numbers) > 1 && strings.Contains(v, "x") {
+		depth, err := strconv.ParseFloat(numbers[1], 64)
+		if err != nil {
+			return ""
+		}
+		area *= depth
+	}
+
+	switch {
+	case strings.Contains(v, "ac"):
+		area *= 43560
+	case strings.Contains(v, "hec") || strings.Contains(v, " ha"):
+		area *= 107639
+	case strings.Contains(v, "m2") || strings.Contains(v, "sqm") || strings.Contains(v, "sq m"):
+		area *= 10.7639
+	}
+	return strconv.FormatFloat(math.Round(area), 'f', -1, 64)
+}
+
+func single(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// setField sets a property field by its proto field name.
+func setField(p *mlspb.Property, name string, values []string) error {
+	switch name {
+	case "address":
+		p.Address = single(values)
+	case "bathrooms":
+		p.Bathrooms = single(values)
+	case "bedrooms":
+		p.Bedrooms = single(values)
+	case "land_size":
+		p.LandSize = single(values)
+	case "mls_id":
+		p.MlsId = single(values)
+	case "mls_number":
+		p.MlsNumber = single(values)
+	case "mls_url":
+		p.MlsUrl = single(values)
+	case "parking":
+		p.Parking = values
+	case "photo_url":
+		p.PhotoUrl = values
+	case "price":
+		price, err := strconv.Atoi(single(values))
+		if err != nil {
+			price = -1
+		}
+		p.Price = []*mlspb.PriceHistory{
+			{
+				Price:     int32(price),
+				Timestamp: time.Now().Unix(),
+			},
+		}
+	case "public_remarks":
+		p.PublicRemarks = single(values)
+	case "stories":
+		p.Stories = single(values)
+	case "property_type":
+		p.PropertyType = single(values)
+	case "latitude":
+		p.Latitude, _ = strconv.ParseFloat(single(values), 64)
+	case "longitude":
+		p.Longitude, _ = strconv.ParseFloat(single(values), 64)
+	case "city":
+		p.City = single(values)
+	case "state":
+		p.State = single(values)
+	case "zipcode":
+		p.Zipcode = single(values)
+	default:
+		return fmt.Errorf("unknown property field %q", name)
+	}
+	return nil
+}
+
+// SpecCollector is a generic collector driven by a mapping spec.
+type SpecCollector struct {
+	DB     storage.DBInterface
+	Spec   *Spec
+	client *http.Client
+	log    logrus.FieldLogger
+}
+
+// specOptions are the config options of a declarative collector.
+type specOptions struct {
+	Path string `json:"path"`
+}
+
+func newSpecCollector(config Config, deps Deps) (Collector, error) {
+	var options specOptions
+	if err := decodeOptions(config, &options); err != nil {
+		return nil, err
+	}
+	spec, err := LoadSpec(options.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewSpecCollector(spec, deps.DB, deps.Client)
+	s.log = deps.Logger
+	return s, nil
+}
+
+// NewSpecCollector creates a collector for the source described by spec,
+// saving to s.
+func NewSpecCollector(spec *Spec, s storage.DBInterface, c *http.Client) *SpecCollector {
+	if c == nil {
+		c = &http.Client{}
+	}
+
+	return &SpecCollector{
+		DB:     s,
+		Spec:   spec,
+		client: c,
+		log:    logrus.StandardLogger(),
+	}
+}
+
+// SpecConfigs returns the config of a declarative collector for every JSON
+// spec in dir, named after the spec source.
+func SpecConfigs(dir string) ([]Config, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	configs := []Config{}
+	for _, path := range paths {
+		spec, err := LoadSpec(path)
+		if err != nil {
+			return nil, err
+		}
+		options, err := json.Marshal(specOptions{Path: path})
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, Config{Type: "spec", Name: spec.Source, Options: options})
+	}
+	return configs, nil
+}
+
+func (s *SpecCollector) request(ctx context.Context) (*http.Response, error) {
+	if strings.ToUpper(s.Spec.Method) == http.MethodPost {
+		form := url.Values{}
+		for k, v := range s.Spec.Form {
+			form.Set(k, v)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.Spec.URL, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for k, v := range s.Spec.Headers {
+			req.Header.Set(k, v)
+		}
+		return s.client.Do(req)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Spec.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.Spec.Headers {
+		req.Header.Set(k, v)
+	}
+	return s.client.Do(req)
+}
+
+// FetchListing retrieves the listings from the source and saves them to DB.
+func (s *SpecCollector) FetchListing(ctx context.Context) {
+	run := startRun(ctx, s.DB, s.log, s.Spec.Source)
+	err := s.fetchAll(run)
+	if err != nil {
+		s.log.Error(err)
+	}
+	run.finish(err)
+}
+
+func (s *SpecCollector) fetchAll(run *run) error {
+	resp, err := s.request(run.ctx)
+	if err != nil {
+		run.failed()
+		return fmt.Errorf("failed to request %q: %v", s.Spec.Source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		run.failed()
+		return fmt.Errorf("unexpected status %d from %q", resp.StatusCode, s.Spec.Source)
+	}
+
+	var body interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return fmt.Errorf("failed to parse the %q response: %v", s.Spec.Source, err)
+	}
+	run.fetched()
+
+	results := body
+	for _, key := range strings.Split(s.Spec.ResultsPath, ".") {
+		if key == "" {
+			continue
+		}
+		obj, ok := results.(map[string]interface{})
+		if !ok {
+			results = nil
+			break
+		}
+		results = obj[key]
+	}
+	listings, ok := results.([]interface{})
+	if !ok {
+		return fmt.Errorf("failed to find the listings at %q in the %q response", s.Spec.ResultsPath, s.Spec.Source)
+	}
+
+	for _, l := range listings {
+		p, err := s.Spec.Map(l)
+		if err != nil {
+			s.log.Errorf("Failed to map a %q listing: %v", s.Spec.Source, err)
+			continue
+		}
+		if p.MlsNumber == "" {
+			s.log.Errorf("Skip a %q listing without mls number", s.Spec.Source)
+			continue
+		}
+		if _, err := run.save(p); err != nil {
+			s.log.Error(err)
+		}
+	}
+	return nil
+}
+
+// GetDB retrieves the DB instance
+func (s *SpecCollector) GetDB() storage.DBInterface {
+	return s.DB
+}
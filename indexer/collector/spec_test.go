@@ -0,0 +1,195 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+const specResponse = `{
+  "Results": [{
+    "Id": "20552312",
+    "MlsNumber": "19016318",
+    "PublicRemarks": " HOUSE DESCRIPTION ",
+    "Building": {
+      "BathroomTotal": "1",
+      "Bedrooms": "3 + 0"
+    },
+    "Property": {
+      "Price": "$10,000",
+      "Type": "Single Family",
+      "Address": {
+        "AddressText": "1234 street|city, province A0B1C2",
+        "Longitude": "-12.345678",
+        "Latitude": "98.765432"
+      },
+      "Photo": [{
+        "SequenceId": "1",
+        "HighResPath": "https:\/\/picture\/listings\/high\/456.jpg",
+        "LowResPath": "https:\/\/picture\/listings\/low\/456.jpg"
+      }, {
+        "SequenceId": "2",
+        "LowResPath": "https:\/\/picture\/listings\/low\/789.jpg"
+      }],
+      "Parking": [{
+        "Name": "Attached Garage"
+      }, {
+        "Name": "Gravel"
+      }]
+    },
+    "Land": {
+      "SizeTotal": "50 x 120 ft"
+    },
+    "RelativeURLEn": "\/abc\/20552312\/house"
+  }]
+}`
+
+func TestSpecMap(t *testing.T) {
+	t.Run("can map a listing with the spec", func(t *testing.T) {
+		spec, err := LoadSpec("testdata/mls-canada.spec.json")
+		if err != nil {
+			t.Fatalf("failed to load the spec: %v", err)
+		}
+		spec.Fields["land_size"] = FieldSpec{Paths: []string{"Land.SizeTotal"}, Transforms: []string{"parse_area"}}
+
+		var body map[string][]interface{}
+		if err := json.Unmarshal([]byte(specResponse), &body); err != nil {
+			t.Fatal(err)
+		}
+		p, err := spec.Map(body["Results"][0])
+		if err != nil {
+			t.Fatalf("failed to map the listing: %v", err)
+		}
+
+		AssertStringEqual(t, p.Address, "1234 street|city, province A0B1C2")
+		AssertStringEqual(t, p.Bedrooms, "3 + 0")
+		AssertStringEqual(t, p.LandSize, "6000")
+		AssertStringEqual(t, p.MlsId, "20552312")
+		AssertStringEqual(t, p.MlsNumber, "19016318")
+		AssertStringEqual(t, p.MlsUrl, "/abc/20552312/house")
+		AssertArrayEqual(t, p.Parking, []string{"Attached Garage", "Gravel"})
+		AssertArrayEqual(t, p.PhotoUrl, []string{"https://picture/listings/high/456.jpg", "https://picture/listings/low/789.jpg"})
+		AssertStringEqual(t, p.PublicRemarks, "HOUSE DESCRIPTION")
+		AssertStringEqual(t, p.Stories, "")
+		AssertStringEqual(t, p.PropertyType, "Single Family")
+		AssertStringEqual(t, p.Source, "mls-canada-spec")
+		AssertFloat64Equal(t, p.Latitude, 98.765432)
+		AssertFloat64Equal(t, p.Longitude, -12.345678)
+		AssertStringEqual(t, p.City, "city")
+		AssertStringEqual(t, p.State, "province")
+		AssertStringEqual(t, p.Zipcode, "A0B1C2")
+		if p.Price[0].Price != 10000 {
+			t.Errorf("got price %d, want %d", p.Price[0].Price, 10000)
+		}
+	})
+}
+
+func TestTransforms(t *testing.T) {
+	areas := map[string]string{
+		"1,234 sqft":  "1234",
+		"50 x 120 ft": "6000",
+		"0.5 ac":      "21780",
+		"100 m2":      "1076",
+		"0X":          "0",
+		"unknown":     "",
+	}
+	for area, want := range areas {
+		t.Run("parse area "+area, func(t *testing.T) {
+			AssertStringEqual(t, parseArea(area), want)
+		})
+	}
+
+	prices := map[string]string{
+		"$10,000":     "10000",
+		"$499,900.50": "499901",
+		"N/A":         "",
+	}
+	for price, want := range prices {
+		t.Run("parse price "+price, func(t *testing.T) {
+			AssertStringEqual(t, parsePrice(price), want)
+		})
+	}
+}
+
+func TestLoadSpec(t *testing.T) {
+	t.Run("reject a spec with an unknown field", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "spec")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "bad.json")
+		spec := `{"source": "bad", "url": "http://source", "fields": {"mls_number": {"paths": ["Id"]}, "colour": {"paths": ["Colour"]}}}`
+		if err := ioutil.WriteFile(path, []byte(spec), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSpec(path); err == nil {
+			t.Error("expected an error loading a spec with an unknown field")
+		}
+	})
+}
+
+func TestSpecCollectorFetchListing(t *testing.T) {
+	t.Run("can fetch and save listings with the spec", func(t *testing.T) {
+		spec, err := LoadSpec("testdata/mls-canada.spec.json")
+		if err != nil {
+			t.Fatalf("failed to load the spec: %v", err)
+		}
+
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			if r.Method != http.MethodPost || r.FormValue("Sort") != "6-D" {
+				t.Errorf("unexpected request %s with form %v", r.Method, r.Form)
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(specResponse)),
+				Header:     make(http.Header),
+			}
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		s := NewSpecCollector(spec, mDB, c)
+		s.FetchListing(context.Background())
+
+		savedListings, _ := mDB.ReadListings(context.Background())
+		if len(savedListings.Property) != 1 {
+			t.Fatalf("expected 1 saved listing, got %d", len(savedListings.Property))
+		}
+		AssertStringEqual(t, savedListings.Property[0].MlsNumber, "19016318")
+		AssertStringEqual(t, savedListings.Property[0].Source, "mls-canada-spec")
+	})
+
+	t.Run("fail the run on an error response", func(t *testing.T) {
+		spec, err := LoadSpec("testdata/mls-canada.spec.json")
+		if err != nil {
+			t.Fatalf("failed to load the spec: %v", err)
+		}
+
+		c := NewTestClient(func(r *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: 503,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("<html>Service Unavailable</html>")),
+				Header:     make(http.Header),
+			}
+		})
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		s := NewSpecCollector(spec, mDB, c)
+		s.FetchListing(context.Background())
+
+		runs, err := mDB.ReadCollectionRuns(context.Background(), 1)
+		if err != nil || len(runs.Run) != 1 {
+			t.Fatalf("expected 1 collection run, got %v, %v", runs, err)
+		}
+		if runs.Run[0].HttpErrors != 1 || !strings.Contains(runs.Run[0].Error, "unexpected status 503") {
+			t.Errorf("expected the run to fail on the error response, got %v", runs.Run[0])
+		}
+	})
+}
@@ -0,0 +1,250 @@
+// package generator builds synthetic MLS listings with plausible prices,
+// sizes and locations, and their price drops and delistings over a simulated
+// timeline, for demos, load tests and analyzer development.
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/tagger"
+)
+
+const (
+	// Source is the source of the generated listings.
+	Source = "synthetic"
+	day    = int64(24 * time.Hour / time.Second)
+)
+
+// Region is an area listings are generated in, with the median price of a
+// three bedroom house.
+type Region struct {
+	City         string  `json:"city"`
+	State        string  `json:"state"`
+	ZipPrefix    string  `json:"zip_prefix"`
+	LatitudeMin  float64 `json:"latitude_min"`
+	LatitudeMax  float64 `json:"latitude_max"`
+	LongitudeMin float64 `json:"longitude_min"`
+	LongitudeMax float64 `json:"longitude_max"`
+	MedianPrice  int     `json:"median_price"`
+	// Weight is the share of the listings generated in the region, relative
+	// to the other regions.
+	Weight float64 `json:"weight"`
+}
+
+// Config sets how many listings are generated and how they behave over the
+// timeline of Days days from Start.
+type Config struct {
+	Seed     int64    `json:"seed"`
+	Listings int      `json:"listings"`
+	Start    int64    `json:"start"`
+	Days     int      `json:"days"`
+	Regions  []Region `json:"regions"`
+	// MedianDaysOnMarket is the median time a listing stays listed before
+	// it is delisted.
+	MedianDaysOnMarket int `json:"median_days_on_market"`
+	// DropRate is the share of the listings that drop their price, each drop
+	// comes DropInterval days after the previous price.
+	DropRate     float64 `json:"drop_rate"`
+	DropInterval int     `json:"drop_interval"`
+}
+
+// DefaultConfig generates listings in Windsor and its suburbs over 90 days.
+var DefaultConfig = Config{
+	Seed:     1,
+	Listings: 1000,
+	Start:    time.Date(2019, time.May, 1, 0, 0, 0, 0, time.UTC).Unix(),
+	Days:     90,
+	Regions: []Region{
+		{City: "Windsor", State: "Ontario", ZipPrefix: "N9A", LatitudeMin: 42.25, LatitudeMax: 42.33, LongitudeMin: -83.08, LongitudeMax: -82.93, MedianPrice: 329000, Weight: 6},
+		{City: "Tecumseh", State: "Ontario", ZipPrefix: "N8N", LatitudeMin: 42.28, LatitudeMax: 42.32, LongitudeMin: -82.93, LongitudeMax: -82.85, MedianPrice: 459000, Weight: 2},
+		{City: "LaSalle", State: "Ontario", ZipPrefix: "N9J", LatitudeMin: 42.19, LatitudeMax: 42.25, LongitudeMin: -83.11, LongitudeMax: -83.03, MedianPrice: 499000, Weight: 2},
+	},
+	MedianDaysOnMarket: 35,
+	DropRate:           0.3,
+	DropInterval:       21,
+}
+
+// LoadConfig reads a JSON config, the missing fields keep the DefaultConfig
+// values.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read the generator config: %v", err)
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return config, fmt.Errorf("failed to parse the generator config %q: %v", path, err)
+	}
+	return config, nil
+}
+
+// Listing is a generated listing with its price history, it is delisted at
+// DelistedAt or still open when DelistedAt is 0.
+type Listing struct {
+	Property   *mlspb.Property
+	DelistedAt int64
+}
+
+// End is the last second of the timeline of the config.
+func (c Config) End() int64 {
+	return c.Start + int64(c.Days)*day
+}
+
+var (
+	streets = []string{
+		"OUELLETTE AVENUE", "WYANDOTTE STREET E", "HOWARD AVENUE", "DOUGALL AVENUE", "TECUMSEH ROAD E",
+		"WALKER ROAD", "RIVERSIDE DRIVE E", "CALIFORNIA AVENUE", "LAUZON PARKWAY", "MALDEN ROAD",
+		"TODD LANE", "DIVISION ROAD", "CABANA ROAD W", "BANWELL ROAD", "MANNING ROAD",
+	}
+	features = []string{
+		"renovated kitchen", "updated bathroom", "new roof", "inground pool", "detached garage",
+		"attached garage", "finished basement", "in law suite", "large fenced yard", "hardwood floors",
+	}
+	conditions = []string{
+		"Move in ready", "Well kept", "Handyman special, sold as is", "Fully renovated", "Original condition",
+	}
+	// bedroomWeights is the share of the listings with 1 to 5 bedrooms.
+	bedroomWeights = []float64{0.08, 0.27, 0.38, 0.2, 0.07}
+)
+
+// Generate builds the listings of the config, the same config builds the
+// same listings.
+func Generate(config Config) ([]*Listing, error) {
+	if len(config.Regions) == 0 {
+		return nil, fmt.Errorf("no region to generate listings in")
+	}
+	if config.Days <= 0 {
+		return nil, fmt.Errorf("the timeline must last at least one day")
+	}
+	if config.MedianDaysOnMarket <= 0 {
+		return nil, fmt.Errorf("the median days on market must be at least one day")
+	}
+	totalWeight := 0.0
+	for _, region := range config.Regions {
+		if region.Weight < 0 || region.MedianPrice <= 0 {
+			return nil, fmt.Errorf("invalid region %q", region.City)
+		}
+		totalWeight += region.Weight
+	}
+	if totalWeight == 0 {
+		return nil, fmt.Errorf("every region has a zero weight")
+	}
+
+	r := rand.New(rand.NewSource(config.Seed))
+	t := tagger.New(tagger.DefaultRules)
+	listings := []*Listing{}
+	for i := 0; i < config.Listings; i++ {
+		l := generate(r, config, pickRegion(r, config.Regions, totalWeight), i)
+		l.Property.Tags = t.Tags(l.Property.PublicRemarks)
+		listings = append(listings, l)
+	}
+	return listings, nil
+}
+
+func pickRegion(r *rand.Rand, regions []Region, totalWeight float64) Region {
+	n := r.Float64() * totalWeight
+	for _, region := range regions {
+		if n < region.Weight {
+			return region
+		}
+		n -= region.Weight
+	}
+	return regions[len(regions)-1]
+}
+
+func pickWeighted(r *rand.Rand, weights []float64) int {
+	n := r.Float64()
+	for i, w := range weights {
+		if n < w {
+			return i
+		}
+		n -= w
+	}
+	return len(weights) - 1
+}
+
+func generate(r *rand.Rand, config Config, region Region, i int) *Listing {
+	bedrooms := 1 + pickWeighted(r, bedroomWeights)
+	// Prices are log-normal around the region median, 12% more or less per
+	// bedroom from three.
+	price := float64(region.MedianPrice) * math.Pow(1.12, float64(bedrooms-3)) * math.Exp(r.NormFloat64()*0.25)
+	listedAt := config.Start + r.Int63n(int64(config.Days)*day)
+	mlsNumber := strconv.Itoa(19000000 + i)
+	address := fmt.Sprintf("%d %s|%s, %s %s%d%c%d", 100+r.Intn(4900), streets[r.Intn(len(streets))],
+		region.City, region.State, region.ZipPrefix, r.Intn(10), 'A'+rune(r.Intn(26)), r.Intn(10))
+
+	p := &mlspb.Property{
+		Address:       address,
+		Bathrooms:     strconv.Itoa(1 + r.Intn(bedrooms/2+1)),
+		Bedrooms:      fmt.Sprintf("%d + %d", bedrooms, r.Intn(2)),
+		LandSize:      fmt.Sprintf("%d X %d FT", 30+r.Intn(40), 100+r.Intn(80)),
+		MlsId:         strconv.Itoa(21000000 + i),
+		MlsNumber:     mlsNumber,
+		MlsUrl:        fmt.Sprintf("/real-estate/%d/synthetic", 21000000+i),
+		Parking:       []string{[]string{"Attached garage", "Detached garage", "Gravel", "Street", "None"}[r.Intn(5)]},
+		PhotoUrl:      []string{fmt.Sprintf("https://photos.invalid/%s/1.jpg", mlsNumber)},
+		PublicRemarks: remarks(r, bedrooms),
+		Stories:       []string{"1", "1.5", "2", "3"}[r.Intn(4)],
+		PropertyType:  []string{"House", "House", "House", "Row / Townhouse", "Apartment"}[r.Intn(5)],
+		ListTimestamp: listedAt,
+		Source:        Source,
+		Latitude:      region.LatitudeMin + r.Float64()*(region.LatitudeMax-region.LatitudeMin),
+		Longitude:     region.LongitudeMin + r.Float64()*(region.LongitudeMax-region.LongitudeMin),
+		City:          region.City,
+		State:         region.State,
+		Zipcode:       address[len(address)-6:],
+		Status:        "Open",
+		Price:         []*mlspb.PriceHistory{{Price: roundPrice(price), Timestamp: listedAt}},
+	}
+
+	// The days on market are exponential around the median.
+	onMarket := int64(-math.Log(1-r.Float64()) / math.Ln2 * float64(config.MedianDaysOnMarket) * float64(day))
+	l := &Listing{Property: p}
+	end := config.End()
+	if listedAt+onMarket < end {
+		l.DelistedAt = listedAt + onMarket
+		p.Status = "Delisted"
+	} else {
+		onMarket = end - listedAt
+	}
+
+	if config.DropInterval > 0 && r.Float64() < config.DropRate {
+		// A listing that drops keeps dropping 2 to 6% every interval.
+		for at := listedAt + int64(config.DropInterval)*day; at < listedAt+onMarket; at += int64(config.DropInterval) * day {
+			price *= 1 - (0.02 + r.Float64()*0.04)
+			p.Price = append(p.Price, &mlspb.PriceHistory{Price: roundPrice(price), Timestamp: at})
+		}
+	}
+	return l
+}
+
+func remarks(r *rand.Rand, bedrooms int) string {
+	picked := r.Perm(len(features))[:2+r.Intn(2)]
+	sort.Ints(picked)
+	text := fmt.Sprintf("%s %d bedroom home with", conditions[r.Intn(len(conditions))], bedrooms)
+	for j, f := range picked {
+		switch {
+		case j == 0:
+			text += " " + features[f]
+		case j == len(picked)-1:
+			text += " and " + features[f]
+		default:
+			text += ", " + features[f]
+		}
+	}
+	return text + "."
+}
+
+// roundPrice sets the price to end in 900 as asking prices usually do, ie.
+// 329900.
+func roundPrice(price float64) int32 {
+	return int32(math.Floor(price/1000)*1000 + 900)
+}
@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/jsonpb"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+func testConfig() Config {
+	config := DefaultConfig
+	config.Listings = 200
+	return config
+}
+
+func TestGenerate(t *testing.T) {
+	t.Run("build the same listings from the same config", func(t *testing.T) {
+		a, _ := Generate(testConfig())
+		b, _ := Generate(testConfig())
+		if !reflect.DeepEqual(a, b) {
+			t.Error("expected the same listings")
+		}
+	})
+
+	t.Run("build plausible listings over the timeline", func(t *testing.T) {
+		config := testConfig()
+		listings, err := Generate(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listings) != config.Listings {
+			t.Fatalf("got %d listings, want %d", len(listings), config.Listings)
+		}
+
+		regions := map[string]Region{}
+		for _, r := range config.Regions {
+			regions[r.City] = r
+		}
+		delisted, dropped := 0, 0
+		for _, l := range listings {
+			p := l.Property
+			r, ok := regions[p.City]
+			if !ok || p.Latitude < r.LatitudeMin || p.Latitude > r.LatitudeMax || p.Longitude < r.LongitudeMin || p.Longitude > r.LongitudeMax {
+				t.Errorf("listing %s is outside its region: %v", p.MlsNumber, p)
+			}
+			if p.ListTimestamp < config.Start || p.ListTimestamp >= config.End() {
+				t.Errorf("listing %s is listed outside the timeline", p.MlsNumber)
+			}
+			for i, price := range p.Price {
+				if price.Price <= 0 || i > 0 && (price.Price >= p.Price[i-1].Price || price.Timestamp <= p.Price[i-1].Timestamp) {
+					t.Errorf("listing %s has an unexpected price history %v", p.MlsNumber, p.Price)
+				}
+			}
+			if len(p.Price) > 1 {
+				dropped++
+			}
+			if l.DelistedAt != 0 {
+				delisted++
+				if p.Status != "Delisted" || l.DelistedAt <= p.ListTimestamp || l.DelistedAt >= config.End() {
+					t.Errorf("listing %s is delisted at %d with status %s", p.MlsNumber, l.DelistedAt, p.Status)
+				}
+			}
+		}
+		if delisted == 0 || delisted == len(listings) || dropped == 0 {
+			t.Errorf("expected some listings delisted and some dropping, got %d delisted and %d dropped", delisted, dropped)
+		}
+	})
+
+	t.Run("reject an invalid config", func(t *testing.T) {
+		configs := map[string]func(c *Config){
+			"no region":     func(c *Config) { c.Regions = nil },
+			"no day":        func(c *Config) { c.Days = 0 },
+			"zero weights":  func(c *Config) { c.Regions = []Region{{City: "Windsor", MedianPrice: 1}} },
+			"no median day": func(c *Config) { c.MedianDaysOnMarket = 0 },
+		}
+		for name, change := range configs {
+			config := testConfig()
+			change(&config)
+			if _, err := Generate(config); err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+		}
+	})
+}
+
+func TestSave(t *testing.T) {
+	t.Run("can save the listings with their timeline", func(t *testing.T) {
+		config := testConfig()
+		listings, _ := Generate(config)
+		mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+		if err := Save(context.Background(), mDB, listings, config.End()); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+
+		saved, _ := mDB.ReadListings(context.Background())
+		byNumber := map[string]*mlspb.Property{}
+		for _, p := range saved.Property {
+			byNumber[p.MlsNumber] = p
+		}
+		for _, l := range listings {
+			p := byNumber[l.Property.MlsNumber]
+			if p == nil || p.Status != l.Property.Status || len(p.Price) != len(l.Property.Price) {
+				t.Errorf("listing %s saved as %v, want %v", l.Property.MlsNumber, p, l.Property)
+			}
+		}
+	})
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "generator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("can write the listings as json and protobuf", func(t *testing.T) {
+		listings, _ := Generate(testConfig())
+		path := filepath.Join(dir, "listings.json")
+		if err := WriteFile(path, listings); err != nil {
+			t.Fatal(err)
+		}
+		f, _ := os.Open(path)
+		defer f.Close()
+		var read mlspb.Listings
+		if err := jsonpb.Unmarshal(f, &read); err != nil {
+			t.Fatalf("failed to read the json listings: %v", err)
+		}
+		if len(read.Property) != len(listings) {
+			t.Errorf("got %d listings, want %d", len(read.Property), len(listings))
+		}
+
+		if err := WriteFile(filepath.Join(dir, "listings.pb"), listings); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
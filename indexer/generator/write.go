@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+// Save writes the listings to db as the collectors would: the first price
+// saves the listing, every later price updates it, and the listings delisted
+// before end are delisted.
+func Save(ctx context.Context, db storage.DBInterface, listings []*Listing, end int64) error {
+	for _, l := range listings {
+		p := proto.Clone(l.Property).(*mlspb.Property)
+		// The listing opens when it is saved, DelistUnseen delists it below.
+		p.Status = ""
+		prices := p.Price
+		p.Price = prices[:1]
+		if err := db.SaveNewListing(ctx, p); err != nil {
+			return fmt.Errorf("failed to save listing %s: %v", p.MlsNumber, err)
+		}
+		for _, price := range prices[1:] {
+			p.Price = []*mlspb.PriceHistory{price}
+			if err := db.UpdateListing(ctx, p); err != nil {
+				return fmt.Errorf("failed to update listing %s: %v", p.MlsNumber, err)
+			}
+		}
+
+		seen := end
+		if l.DelistedAt != 0 {
+			seen = l.DelistedAt
+		}
+		if err := db.MarkListingSeen(ctx, p.MlsNumber, seen); err != nil {
+			return fmt.Errorf("failed to mark listing %s seen: %v", p.MlsNumber, err)
+		}
+	}
+	if _, err := db.DelistUnseen(ctx, Source, end); err != nil {
+		return fmt.Errorf("failed to delist the listings: %v", err)
+	}
+	return nil
+}
+
+// WriteFile writes the listings as an mlspb.Listings message, in JSON when
+// path ends with .json and in the protobuf wire format otherwise.
+func WriteFile(path string, listings []*Listing) error {
+	message := &mlspb.Listings{}
+	for _, l := range listings {
+		message.Property = append(message.Property, l.Property)
+	}
+
+	var content []byte
+	if filepath.Ext(path) == ".json" {
+		var b bytes.Buffer
+		m := jsonpb.Marshaler{Indent: "  "}
+		if err := m.Marshal(&b, message); err != nil {
+			return err
+		}
+		content = b.Bytes()
+	} else {
+		var err error
+		if content, err = proto.Marshal(message); err != nil {
+			return err
+		}
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
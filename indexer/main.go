@@ -3,29 +3,199 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/tony-yang/realtor-tracker/indexer/cassette"
 	"github.com/tony-yang/realtor-tracker/indexer/collector"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
 )
 
-func runCollectors(wg *sync.WaitGroup) {
+var (
+	dbPath = flag.String("db_path", "/tmp/realtor_prod.db", "The sqlite DB the collectors save to")
+	// dbBackend picks the DB the collectors save to, the sqlite DB of db_path
+	// or the postgres DB of db_dsn shared by a deployment.
+	dbBackend = flag.String("db_backend", storage.SqliteBackend, "The storage backend, sqlite or postgres")
+	dbDSN     = flag.String("db_dsn", "", "The DSN of the postgres DB the collectors save to")
+	// config lists the collectors to run, only the MLS Canada collector runs
+	// without it.
+	config  = flag.String("config", "", "The JSON config of the collectors")
+	specDir = flag.String("spec_dir", "", "The directory of the JSON mapping specs for the declarative collectors")
+	// incremental only reads the newest MLS Canada listings, it is meant to
+	// run often between the full sweeps.
+	incremental = flag.Bool("incremental", false, "Run the MLS Canada collectors in incremental mode")
+	// importDir, importMapping and plugins add a file import and the plugin
+	// collectors, they are kept for the existing invocations of -config.
+	importDir     = flag.String("import_dir", "", "Deprecated: use a file-import collector of -config. The directory of the listing files to import")
+	importMapping = flag.String("import_mapping", "", "Deprecated: use a file-import collector of -config. The JSON mapping of the imported files")
+	plugins       = flag.String("plugins", "", "Deprecated: use the plugin collectors of -config. The JSON list of the plugin collectors")
+	// dryRun reads db_path but writes nothing, it prints what the collectors
+	// would save instead.
+	dryRun     = flag.Bool("dry-run", false, "Print the listing changes of the collectors without saving them")
+	diffFormat = flag.String("diff-format", "text", "The format of the dry run diff, text or json")
+	// cassette replays the recorded responses of the sources instead of
+	// requesting them, or records them with -record.
+	cassettePath = flag.String("cassette", "", "The file of the recorded HTTP responses of the sources")
+	record       = flag.Bool("record", false, "Record the HTTP responses of the sources to the cassette")
+)
+
+func printDiff(d *storage.DryRunDB) error {
+	report := d.Report()
+	if *diffFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return report.WriteText(os.Stdout)
+}
+
+// shutdownContext is cancelled when the indexer is interrupted or terminated,
+// so the collectors stop and record their runs.
+func shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case s := <-signals:
+			logrus.Infof("Received %v, stopping the collectors", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func runCollectors(ctx context.Context, wg *sync.WaitGroup, collectors map[string]collector.Collector) {
 	defer wg.Done()
-	for name, c := range collector.Collectors {
+	for name, c := range collectors {
+		if ctx.Err() != nil {
+			return
+		}
 		logrus.Infof("Running the %q collector...", name)
-		c.FetchListing()
+		c.FetchListing(ctx)
 		time.Sleep(5 * time.Second)
 		logrus.Infof("%q finished collection.", name)
 	}
 }
 
 func main() {
+	flag.Parse()
+	ctx, cancel := shutdownContext()
+	defer cancel()
+	if flag.Arg(0) == "migrate" {
+		migrateMain(ctx, flag.Args()[1:])
+		return
+	}
 	var wg sync.WaitGroup
 
 	logrus.Info("Indexer Main")
+	configs := []collector.Config{{Type: "mls-canada"}}
+	if *config != "" {
+		var err error
+		if configs, err = collector.LoadConfigs(*config); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+	if *specDir != "" {
+		specs, err := collector.SpecConfigs(*specDir)
+		if err != nil {
+			logrus.Fatalf("Failed to load the declarative collectors: %v", err)
+		}
+		configs = append(configs, specs...)
+	}
+	if *importDir != "" || *importMapping != "" {
+		logrus.Warn("-import_dir and -import_mapping are deprecated, add a file-import collector to -config instead")
+		imports, err := collector.ImportConfigs(*importDir, *importMapping)
+		if err != nil {
+			logrus.Fatalf("Failed to load the file import collector: %v", err)
+		}
+		configs = append(configs, imports...)
+	}
+	if *plugins != "" {
+		logrus.Warn("-plugins is deprecated, add the plugin collectors to -config instead")
+		pluginConfigs, err := collector.PluginConfigs(*plugins)
+		if err != nil {
+			logrus.Fatalf("Failed to load the plugin collectors: %v", err)
+		}
+		configs = append(configs, pluginConfigs...)
+	}
+	if *incremental {
+		var err error
+		if configs, err = collector.IncrementalConfigs(configs); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	if *diffFormat != "text" && *diffFormat != "json" {
+		logrus.Fatalf("Unsupported diff format %q", *diffFormat)
+	}
+
+	sqlDB, err := storage.OpenBackend(*dbBackend, *dbPath, *dbDSN)
+	if err != nil {
+		logrus.Fatalf("Failed to open the %s DB: %v", *dbBackend, err)
+	}
+	if *dryRun {
+		// A dry run writes nothing, the schema must be migrated already.
+		pending, err := sqlDB.Migrate(ctx, true)
+		if err != nil {
+			logrus.Fatalf("Failed to read the migrations of the %s DB: %v", *dbBackend, err)
+		}
+		if len(pending) > 0 {
+			logrus.Fatalf("The %s DB has %d pending migrations, run the migrate command before a dry run", *dbBackend, len(pending))
+		}
+	} else if err := sqlDB.CreateStorage(ctx); err != nil {
+		// The schema is migrated on startup, before the collectors save to it.
+		logrus.Fatalf("Failed to migrate the %s DB: %v", *dbBackend, err)
+	}
+	var db storage.DBInterface = sqlDB
+	var dryRunDB *storage.DryRunDB
+	if *dryRun {
+		dryRunDB = storage.NewDryRunDB(sqlDB)
+		db = dryRunDB
+	}
+	client := &http.Client{}
+	var c *cassette.Cassette
+	if *cassettePath != "" {
+		mode := cassette.Replay
+		if *record {
+			mode = cassette.Record
+		}
+		if c, err = cassette.New(*cassettePath, mode, nil); err != nil {
+			logrus.Fatal(err)
+		}
+		client = c.Client()
+	}
+	collectors, err := collector.NewCollectors(configs, collector.Deps{
+		DB:     db,
+		Client: client,
+		Logger: logrus.StandardLogger(),
+	})
+	if err != nil {
+		logrus.Fatalf("Failed to build the collectors: %v", err)
+	}
+
 	wg.Add(1)
-	go runCollectors(&wg)
+	go runCollectors(ctx, &wg, collectors)
 	wg.Wait()
+	if c != nil {
+		if err := c.Save(); err != nil {
+			logrus.Errorf("Failed to save the cassette %q: %v", *cassettePath, err)
+		}
+	}
+	if dryRunDB != nil {
+		if err := printDiff(dryRunDB); err != nil {
+			logrus.Fatalf("Failed to print the dry run diff: %v", err)
+		}
+	}
 	logrus.Info("Indexer collection cycle finished successfully.")
 }
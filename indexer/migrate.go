@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+const migrateUsage = `usage: indexer [-db_backend sqlite|postgres] [-db_path path] [-db_dsn dsn] migrate [-dry-run] [-steps n] [status|up|down]
+
+status lists the migrations and when they were applied, up applies the
+pending ones and down reverts the last steps applied ones. up is the default.
+`
+
+// runMigrate runs the migrate subcommand on the DB.
+func runMigrate(ctx context.Context, db storage.SQLDB, args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	flags.SetOutput(w)
+	flags.Usage = func() { fmt.Fprint(w, migrateUsage) }
+	dryRun := flags.Bool("dry-run", false, "Print the migrations without applying them")
+	steps := flags.Int("steps", 1, "The number of migrations down reverts")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	action := "up"
+	if flags.NArg() > 0 {
+		action = flags.Arg(0)
+	}
+	switch action {
+	case "status":
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt > 0 {
+				applied = "applied " + time.Unix(s.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%3d  %-50s %s\n", s.Version, s.Name, applied)
+		}
+		return nil
+	case "up":
+		migrated, err := db.Migrate(ctx, *dryRun)
+		printMigrations(w, "apply", "Applied", migrated, *dryRun, func(m storage.Migration) string { return m.Up })
+		return err
+	case "down":
+		reverted, err := db.MigrateDown(ctx, *steps, *dryRun)
+		printMigrations(w, "revert", "Reverted", reverted, *dryRun, func(m storage.Migration) string { return m.Down })
+		return err
+	}
+	flags.Usage()
+	return fmt.Errorf("unknown migrate action %q", action)
+}
+
+// printMigrations lists the migrations run, with their SQL on a dry run.
+func printMigrations(w io.Writer, verb, done string, migrations []storage.Migration, dryRun bool, sql func(storage.Migration) string) {
+	if len(migrations) == 0 {
+		fmt.Fprintf(w, "No migration to %s\n", verb)
+	}
+	for _, m := range migrations {
+		if dryRun {
+			fmt.Fprintf(w, "Would %s migration %d %q:\n%s\n", verb, m.Version, m.Name, sql(m))
+			continue
+		}
+		fmt.Fprintf(w, "%s migration %d %q\n", done, m.Version, m.Name)
+	}
+}
+
+func migrateMain(ctx context.Context, args []string) {
+	db, err := storage.OpenBackend(*dbBackend, *dbPath, *dbDSN)
+	if err != nil {
+		logrus.Fatalf("Failed to open the %s DB: %v", *dbBackend, err)
+	}
+	if err := runMigrate(ctx, db, args, os.Stdout); err != nil {
+		logrus.Fatalf("Failed to migrate the %s DB: %v", *dbBackend, err)
+	}
+}
@@ -70,39 +70,90 @@ func (m *PriceHistory) GetTimestamp() int64 {
 	return 0
 }
 
+// StatusHistory records when a listing changed to a status.
+type StatusHistory struct {
+	Status               string   `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Timestamp            int64    `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *StatusHistory) Reset()         { *m = StatusHistory{} }
+func (m *StatusHistory) String() string { return proto.CompactTextString(m) }
+func (*StatusHistory) ProtoMessage()    {}
+func (*StatusHistory) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb9af576948d604f, []int{1}
+}
+
+func (m *StatusHistory) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_StatusHistory.Unmarshal(m, b)
+}
+func (m *StatusHistory) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_StatusHistory.Marshal(b, m, deterministic)
+}
+func (m *StatusHistory) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_StatusHistory.Merge(m, src)
+}
+func (m *StatusHistory) XXX_Size() int {
+	return xxx_messageInfo_StatusHistory.Size(m)
+}
+func (m *StatusHistory) XXX_DiscardUnknown() {
+	xxx_messageInfo_StatusHistory.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_StatusHistory proto.InternalMessageInfo
+
+func (m *StatusHistory) GetStatus() string {
+	if m != nil {
+		return m.Status
+	}
+	return ""
+}
+
+func (m *StatusHistory) GetTimestamp() int64 {
+	if m != nil {
+		return m.Timestamp
+	}
+	return 0
+}
+
 // Property contains the detail information of a MLS listing.
 type Property struct {
-	Address              string          `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
-	Bathrooms            string          `protobuf:"bytes,2,opt,name=bathrooms,proto3" json:"bathrooms,omitempty"`
-	Bedrooms             string          `protobuf:"bytes,3,opt,name=bedrooms,proto3" json:"bedrooms,omitempty"`
-	LandSize             string          `protobuf:"bytes,4,opt,name=land_size,json=landSize,proto3" json:"land_size,omitempty"`
-	MlsId                string          `protobuf:"bytes,5,opt,name=mls_id,json=mlsId,proto3" json:"mls_id,omitempty"`
-	MlsNumber            string          `protobuf:"bytes,6,opt,name=mls_number,json=mlsNumber,proto3" json:"mls_number,omitempty"`
-	MlsUrl               string          `protobuf:"bytes,7,opt,name=mls_url,json=mlsUrl,proto3" json:"mls_url,omitempty"`
-	Parking              []string        `protobuf:"bytes,8,rep,name=parking,proto3" json:"parking,omitempty"`
-	PhotoUrl             []string        `protobuf:"bytes,9,rep,name=photo_url,json=photoUrl,proto3" json:"photo_url,omitempty"`
-	Price                []*PriceHistory `protobuf:"bytes,10,rep,name=price,proto3" json:"price,omitempty"`
-	PublicRemarks        string          `protobuf:"bytes,11,opt,name=public_remarks,json=publicRemarks,proto3" json:"public_remarks,omitempty"`
-	Stories              string          `protobuf:"bytes,12,opt,name=stories,proto3" json:"stories,omitempty"`
-	PropertyType         string          `protobuf:"bytes,13,opt,name=property_type,json=propertyType,proto3" json:"property_type,omitempty"`
-	ListTimestamp        int64           `protobuf:"varint,14,opt,name=list_timestamp,json=listTimestamp,proto3" json:"list_timestamp,omitempty"`
-	Source               string          `protobuf:"bytes,15,opt,name=source,proto3" json:"source,omitempty"`
-	Latitude             float64         `protobuf:"fixed64,16,opt,name=latitude,proto3" json:"latitude,omitempty"`
-	Longitude            float64         `protobuf:"fixed64,17,opt,name=longitude,proto3" json:"longitude,omitempty"`
-	City                 string          `protobuf:"bytes,18,opt,name=city,proto3" json:"city,omitempty"`
-	State                string          `protobuf:"bytes,19,opt,name=state,proto3" json:"state,omitempty"`
-	Zipcode              string          `protobuf:"bytes,20,opt,name=zipcode,proto3" json:"zipcode,omitempty"`
-	Status               string          `protobuf:"bytes,21,opt,name=status,proto3" json:"status,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	Address              string                    `protobuf:"bytes,1,opt,name=address,proto3" json:"address,omitempty"`
+	Bathrooms            string                    `protobuf:"bytes,2,opt,name=bathrooms,proto3" json:"bathrooms,omitempty"`
+	Bedrooms             string                    `protobuf:"bytes,3,opt,name=bedrooms,proto3" json:"bedrooms,omitempty"`
+	LandSize             string                    `protobuf:"bytes,4,opt,name=land_size,json=landSize,proto3" json:"land_size,omitempty"`
+	MlsId                string                    `protobuf:"bytes,5,opt,name=mls_id,json=mlsId,proto3" json:"mls_id,omitempty"`
+	MlsNumber            string                    `protobuf:"bytes,6,opt,name=mls_number,json=mlsNumber,proto3" json:"mls_number,omitempty"`
+	MlsUrl               string                    `protobuf:"bytes,7,opt,name=mls_url,json=mlsUrl,proto3" json:"mls_url,omitempty"`
+	Parking              []string                  `protobuf:"bytes,8,rep,name=parking,proto3" json:"parking,omitempty"`
+	PhotoUrl             []string                  `protobuf:"bytes,9,rep,name=photo_url,json=photoUrl,proto3" json:"photo_url,omitempty"`
+	Price                []*PriceHistory           `protobuf:"bytes,10,rep,name=price,proto3" json:"price,omitempty"`
+	PublicRemarks        string                    `protobuf:"bytes,11,opt,name=public_remarks,json=publicRemarks,proto3" json:"public_remarks,omitempty"`
+	Stories              string                    `protobuf:"bytes,12,opt,name=stories,proto3" json:"stories,omitempty"`
+	PropertyType         string                    `protobuf:"bytes,13,opt,name=property_type,json=propertyType,proto3" json:"property_type,omitempty"`
+	ListTimestamp        int64                     `protobuf:"varint,14,opt,name=list_timestamp,json=listTimestamp,proto3" json:"list_timestamp,omitempty"`
+	Source               string                    `protobuf:"bytes,15,opt,name=source,proto3" json:"source,omitempty"`
+	Latitude             float64                   `protobuf:"fixed64,16,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude            float64                   `protobuf:"fixed64,17,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	City                 string                    `protobuf:"bytes,18,opt,name=city,proto3" json:"city,omitempty"`
+	State                string                    `protobuf:"bytes,19,opt,name=state,proto3" json:"state,omitempty"`
+	Zipcode              string                    `protobuf:"bytes,20,opt,name=zipcode,proto3" json:"zipcode,omitempty"`
+	Status               string                    `protobuf:"bytes,21,opt,name=status,proto3" json:"status,omitempty"`
+	Tags                 []string                  `protobuf:"bytes,22,rep,name=tags,proto3" json:"tags,omitempty"`
+	LocalizedText        map[string]*LocalizedText `protobuf:"bytes,23,rep,name=localized_text,json=localizedText,proto3" json:"localized_text,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	StatusHistory        []*StatusHistory          `protobuf:"bytes,24,rep,name=status_history,json=statusHistory,proto3" json:"status_history,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
+	XXX_unrecognized     []byte                    `json:"-"`
+	XXX_sizecache        int32                     `json:"-"`
 }
 
 func (m *Property) Reset()         { *m = Property{} }
 func (m *Property) String() string { return proto.CompactTextString(m) }
 func (*Property) ProtoMessage()    {}
 func (*Property) Descriptor() ([]byte, []int) {
-	return fileDescriptor_fb9af576948d604f, []int{1}
+	return fileDescriptor_fb9af576948d604f, []int{2}
 }
 
 func (m *Property) XXX_Unmarshal(b []byte) error {
@@ -270,6 +321,75 @@ func (m *Property) GetStatus() string {
 	return ""
 }
 
+func (m *Property) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
+func (m *Property) GetLocalizedText() map[string]*LocalizedText {
+	if m != nil {
+		return m.LocalizedText
+	}
+	return nil
+}
+
+func (m *Property) GetStatusHistory() []*StatusHistory {
+	if m != nil {
+		return m.StatusHistory
+	}
+	return nil
+}
+
+// LocalizedText holds the text fields of a listing in one language.
+type LocalizedText struct {
+	PublicRemarks        string   `protobuf:"bytes,1,opt,name=public_remarks,json=publicRemarks,proto3" json:"public_remarks,omitempty"`
+	PropertyType         string   `protobuf:"bytes,2,opt,name=property_type,json=propertyType,proto3" json:"property_type,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *LocalizedText) Reset()         { *m = LocalizedText{} }
+func (m *LocalizedText) String() string { return proto.CompactTextString(m) }
+func (*LocalizedText) ProtoMessage()    {}
+func (*LocalizedText) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb9af576948d604f, []int{3}
+}
+
+func (m *LocalizedText) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_LocalizedText.Unmarshal(m, b)
+}
+func (m *LocalizedText) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_LocalizedText.Marshal(b, m, deterministic)
+}
+func (m *LocalizedText) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LocalizedText.Merge(m, src)
+}
+func (m *LocalizedText) XXX_Size() int {
+	return xxx_messageInfo_LocalizedText.Size(m)
+}
+func (m *LocalizedText) XXX_DiscardUnknown() {
+	xxx_messageInfo_LocalizedText.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LocalizedText proto.InternalMessageInfo
+
+func (m *LocalizedText) GetPublicRemarks() string {
+	if m != nil {
+		return m.PublicRemarks
+	}
+	return ""
+}
+
+func (m *LocalizedText) GetPropertyType() string {
+	if m != nil {
+		return m.PropertyType
+	}
+	return ""
+}
+
 // Listings holds all the properties collected from the MLS collectors.
 type Listings struct {
 	Property             []*Property `protobuf:"bytes,1,rep,name=property,proto3" json:"property,omitempty"`
@@ -282,7 +402,7 @@ func (m *Listings) Reset()         { *m = Listings{} }
 func (m *Listings) String() string { return proto.CompactTextString(m) }
 func (*Listings) ProtoMessage()    {}
 func (*Listings) Descriptor() ([]byte, []int) {
-	return fileDescriptor_fb9af576948d604f, []int{2}
+	return fileDescriptor_fb9af576948d604f, []int{4}
 }
 
 func (m *Listings) XXX_Unmarshal(b []byte) error {
@@ -321,7 +441,7 @@ func (m *Request) Reset()         { *m = Request{} }
 func (m *Request) String() string { return proto.CompactTextString(m) }
 func (*Request) ProtoMessage()    {}
 func (*Request) Descriptor() ([]byte, []int) {
-	return fileDescriptor_fb9af576948d604f, []int{3}
+	return fileDescriptor_fb9af576948d604f, []int{5}
 }
 
 func (m *Request) XXX_Unmarshal(b []byte) error {
@@ -342,48 +462,320 @@ func (m *Request) XXX_DiscardUnknown() {
 
 var xxx_messageInfo_Request proto.InternalMessageInfo
 
+// ListingRequest defines the parameter for the gRPC service GetListingByMlsNumber.
+type ListingRequest struct {
+	MlsNumber            string   `protobuf:"bytes,1,opt,name=mls_number,json=mlsNumber,proto3" json:"mls_number,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListingRequest) Reset()         { *m = ListingRequest{} }
+func (m *ListingRequest) String() string { return proto.CompactTextString(m) }
+func (*ListingRequest) ProtoMessage()    {}
+func (*ListingRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb9af576948d604f, []int{6}
+}
+
+func (m *ListingRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListingRequest.Unmarshal(m, b)
+}
+func (m *ListingRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListingRequest.Marshal(b, m, deterministic)
+}
+func (m *ListingRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListingRequest.Merge(m, src)
+}
+func (m *ListingRequest) XXX_Size() int {
+	return xxx_messageInfo_ListingRequest.Size(m)
+}
+func (m *ListingRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListingRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListingRequest proto.InternalMessageInfo
+
+func (m *ListingRequest) GetMlsNumber() string {
+	if m != nil {
+		return m.MlsNumber
+	}
+	return ""
+}
+
+// CollectionRun records what one collector run did.
+type CollectionRun struct {
+	RunId            string   `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Source           string   `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	Region           []string `protobuf:"bytes,3,rep,name=region,proto3" json:"region,omitempty"`
+	StartedAt        int64    `protobuf:"varint,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	EndedAt          int64    `protobuf:"varint,5,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`
+	Pages            int32    `protobuf:"varint,6,opt,name=pages,proto3" json:"pages,omitempty"`
+	HttpErrors       int32    `protobuf:"varint,7,opt,name=http_errors,json=httpErrors,proto3" json:"http_errors,omitempty"`
+	NewListings      int32    `protobuf:"varint,8,opt,name=new_listings,json=newListings,proto3" json:"new_listings,omitempty"`
+	UpdatedListings  int32    `protobuf:"varint,9,opt,name=updated_listings,json=updatedListings,proto3" json:"updated_listings,omitempty"`
+	DelistedListings int32    `protobuf:"varint,10,opt,name=delisted_listings,json=delistedListings,proto3" json:"delisted_listings,omitempty"`
+	// error is the reason a run stopped before completing.
+	Error                string   `protobuf:"bytes,11,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CollectionRun) Reset()         { *m = CollectionRun{} }
+func (m *CollectionRun) String() string { return proto.CompactTextString(m) }
+func (*CollectionRun) ProtoMessage()    {}
+func (*CollectionRun) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb9af576948d604f, []int{7}
+}
+
+func (m *CollectionRun) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CollectionRun.Unmarshal(m, b)
+}
+func (m *CollectionRun) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CollectionRun.Marshal(b, m, deterministic)
+}
+func (m *CollectionRun) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CollectionRun.Merge(m, src)
+}
+func (m *CollectionRun) XXX_Size() int {
+	return xxx_messageInfo_CollectionRun.Size(m)
+}
+func (m *CollectionRun) XXX_DiscardUnknown() {
+	xxx_messageInfo_CollectionRun.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CollectionRun proto.InternalMessageInfo
+
+func (m *CollectionRun) GetRunId() string {
+	if m != nil {
+		return m.RunId
+	}
+	return ""
+}
+
+func (m *CollectionRun) GetSource() string {
+	if m != nil {
+		return m.Source
+	}
+	return ""
+}
+
+func (m *CollectionRun) GetRegion() []string {
+	if m != nil {
+		return m.Region
+	}
+	return nil
+}
+
+func (m *CollectionRun) GetStartedAt() int64 {
+	if m != nil {
+		return m.StartedAt
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetEndedAt() int64 {
+	if m != nil {
+		return m.EndedAt
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetPages() int32 {
+	if m != nil {
+		return m.Pages
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetHttpErrors() int32 {
+	if m != nil {
+		return m.HttpErrors
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetNewListings() int32 {
+	if m != nil {
+		return m.NewListings
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetUpdatedListings() int32 {
+	if m != nil {
+		return m.UpdatedListings
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetDelistedListings() int32 {
+	if m != nil {
+		return m.DelistedListings
+	}
+	return 0
+}
+
+func (m *CollectionRun) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+// CollectionRuns holds the most recent collector runs first.
+type CollectionRuns struct {
+	Run                  []*CollectionRun `protobuf:"bytes,1,rep,name=run,proto3" json:"run,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *CollectionRuns) Reset()         { *m = CollectionRuns{} }
+func (m *CollectionRuns) String() string { return proto.CompactTextString(m) }
+func (*CollectionRuns) ProtoMessage()    {}
+func (*CollectionRuns) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb9af576948d604f, []int{8}
+}
+
+func (m *CollectionRuns) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CollectionRuns.Unmarshal(m, b)
+}
+func (m *CollectionRuns) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CollectionRuns.Marshal(b, m, deterministic)
+}
+func (m *CollectionRuns) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CollectionRuns.Merge(m, src)
+}
+func (m *CollectionRuns) XXX_Size() int {
+	return xxx_messageInfo_CollectionRuns.Size(m)
+}
+func (m *CollectionRuns) XXX_DiscardUnknown() {
+	xxx_messageInfo_CollectionRuns.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CollectionRuns proto.InternalMessageInfo
+
+func (m *CollectionRuns) GetRun() []*CollectionRun {
+	if m != nil {
+		return m.Run
+	}
+	return nil
+}
+
+// CollectionRunsRequest defines the parameter for the gRPC service GetCollectionRuns.
+type CollectionRunsRequest struct {
+	Limit                int32    `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CollectionRunsRequest) Reset()         { *m = CollectionRunsRequest{} }
+func (m *CollectionRunsRequest) String() string { return proto.CompactTextString(m) }
+func (*CollectionRunsRequest) ProtoMessage()    {}
+func (*CollectionRunsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_fb9af576948d604f, []int{9}
+}
+
+func (m *CollectionRunsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CollectionRunsRequest.Unmarshal(m, b)
+}
+func (m *CollectionRunsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CollectionRunsRequest.Marshal(b, m, deterministic)
+}
+func (m *CollectionRunsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CollectionRunsRequest.Merge(m, src)
+}
+func (m *CollectionRunsRequest) XXX_Size() int {
+	return xxx_messageInfo_CollectionRunsRequest.Size(m)
+}
+func (m *CollectionRunsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CollectionRunsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CollectionRunsRequest proto.InternalMessageInfo
+
+func (m *CollectionRunsRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterType((*PriceHistory)(nil), "mls.PriceHistory")
+	proto.RegisterType((*StatusHistory)(nil), "mls.StatusHistory")
 	proto.RegisterType((*Property)(nil), "mls.Property")
+	proto.RegisterMapType((map[string]*LocalizedText)(nil), "mls.Property.LocalizedTextEntry")
+	proto.RegisterType((*LocalizedText)(nil), "mls.LocalizedText")
 	proto.RegisterType((*Listings)(nil), "mls.Listings")
 	proto.RegisterType((*Request)(nil), "mls.Request")
+	proto.RegisterType((*ListingRequest)(nil), "mls.ListingRequest")
+	proto.RegisterType((*CollectionRun)(nil), "mls.CollectionRun")
+	proto.RegisterType((*CollectionRuns)(nil), "mls.CollectionRuns")
+	proto.RegisterType((*CollectionRunsRequest)(nil), "mls.CollectionRunsRequest")
 }
 
 func init() { proto.RegisterFile("mls.proto", fileDescriptor_fb9af576948d604f) }
 
 var fileDescriptor_fb9af576948d604f = []byte{
-	// 486 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x4c, 0x53, 0x4f, 0x6f, 0xd4, 0x3e,
-	0x10, 0xfd, 0xe5, 0xb7, 0xff, 0x92, 0xe9, 0x6e, 0xa1, 0xa6, 0x05, 0xab, 0x80, 0xb4, 0x5a, 0x84,
-	0x58, 0x84, 0xd4, 0x43, 0x11, 0x07, 0xae, 0x5c, 0x00, 0x09, 0x50, 0x95, 0x96, 0x73, 0x94, 0x3f,
-	0xd6, 0xd6, 0xaa, 0x1d, 0x1b, 0x8f, 0x83, 0x94, 0xfd, 0xbe, 0x7c, 0x0f, 0xe4, 0x71, 0xb2, 0xcb,
-	0x2d, 0xef, 0xbd, 0xcc, 0x9b, 0x99, 0x37, 0x09, 0x64, 0x5a, 0xe1, 0x95, 0x75, 0xc6, 0x1b, 0x36,
-	0xd1, 0x0a, 0x37, 0x9f, 0x60, 0x79, 0xe3, 0x64, 0x2d, 0xbe, 0x48, 0xf4, 0xc6, 0xf5, 0xec, 0x1c,
-	0x66, 0x36, 0x60, 0x9e, 0xac, 0x93, 0xed, 0x2c, 0x8f, 0x80, 0xbd, 0x80, 0xcc, 0x4b, 0x2d, 0xd0,
-	0x97, 0xda, 0xf2, 0xff, 0xd7, 0xc9, 0x76, 0x92, 0x1f, 0x89, 0xcd, 0x9f, 0x29, 0xa4, 0x37, 0xce,
-	0x58, 0xe1, 0x7c, 0xcf, 0x38, 0x2c, 0xca, 0xa6, 0x71, 0x02, 0x91, 0x2c, 0xb2, 0x7c, 0x84, 0xc1,
-	0xa4, 0x2a, 0xfd, 0xbd, 0x33, 0x46, 0x23, 0x99, 0x64, 0xf9, 0x91, 0x60, 0x97, 0x90, 0x56, 0xa2,
-	0x89, 0xe2, 0x84, 0xc4, 0x03, 0x66, 0xcf, 0x21, 0x53, 0x65, 0xdb, 0x14, 0x28, 0xf7, 0x82, 0x4f,
-	0xa3, 0x18, 0x88, 0x5b, 0xb9, 0x17, 0xec, 0x02, 0xe6, 0x5a, 0x61, 0x21, 0x1b, 0x3e, 0x23, 0x65,
-	0xa6, 0x15, 0x7e, 0x6d, 0xd8, 0x4b, 0x80, 0x40, 0xb7, 0x9d, 0xae, 0x84, 0xe3, 0xf3, 0xd8, 0x4e,
-	0x2b, 0xfc, 0x41, 0x04, 0x7b, 0x06, 0x8b, 0x20, 0x77, 0x4e, 0xf1, 0x05, 0x69, 0xc1, 0xe4, 0xa7,
-	0x53, 0x61, 0x7e, 0x5b, 0xba, 0x07, 0xd9, 0xee, 0x78, 0xba, 0x9e, 0x84, 0xf9, 0x07, 0x18, 0xa6,
-	0xb0, 0xf7, 0xc6, 0x1b, 0x2a, 0xca, 0x48, 0x4b, 0x89, 0x08, 0x65, 0x6f, 0xc6, 0xdc, 0x60, 0x3d,
-	0xd9, 0x9e, 0x5c, 0x9f, 0x5d, 0x85, 0x9c, 0xff, 0x4d, 0x76, 0x8c, 0xf2, 0x35, 0x9c, 0xda, 0xae,
-	0x52, 0xb2, 0x2e, 0x9c, 0xd0, 0xa5, 0x7b, 0x40, 0x7e, 0x42, 0xfd, 0x57, 0x91, 0xcd, 0x23, 0x19,
-	0xc6, 0x08, 0x65, 0x52, 0x20, 0x5f, 0xc6, 0x18, 0x07, 0xc8, 0x5e, 0xc1, 0xca, 0x0e, 0x61, 0x17,
-	0xbe, 0xb7, 0x82, 0xaf, 0x48, 0x5f, 0x8e, 0xe4, 0x5d, 0x6f, 0xa9, 0x8b, 0x92, 0xe8, 0x8b, 0xe3,
-	0xd5, 0x4e, 0xe9, 0x6a, 0xab, 0xc0, 0xde, 0x8d, 0x24, 0x7b, 0x0a, 0x73, 0x34, 0x9d, 0xab, 0x05,
-	0x7f, 0x14, 0x43, 0x88, 0x28, 0x1c, 0x43, 0x95, 0x5e, 0xfa, 0xae, 0x11, 0xfc, 0xf1, 0x3a, 0xd9,
-	0x26, 0xf9, 0x01, 0x87, 0x33, 0x2a, 0xd3, 0xee, 0xa2, 0x78, 0x46, 0xe2, 0x91, 0x60, 0x0c, 0xa6,
-	0xb5, 0xf4, 0x3d, 0x67, 0xe4, 0x47, 0xcf, 0xe1, 0x9b, 0x42, 0x5f, 0x7a, 0xc1, 0x9f, 0xc4, 0x03,
-	0x11, 0x08, 0x1b, 0xee, 0xa5, 0xad, 0x4d, 0x23, 0xf8, 0x79, 0xdc, 0x70, 0x80, 0x34, 0x95, 0x2f,
-	0x7d, 0x87, 0xfc, 0x62, 0x98, 0x8a, 0xd0, 0xe6, 0x03, 0xa4, 0xdf, 0x24, 0x7a, 0xd9, 0xee, 0x90,
-	0xbd, 0x85, 0x74, 0x5c, 0x98, 0x27, 0x14, 0xf9, 0x6a, 0x88, 0x3c, 0x92, 0xf9, 0x41, 0xde, 0x64,
-	0xb0, 0xc8, 0xc5, 0xaf, 0x4e, 0xa0, 0xbf, 0xfe, 0x08, 0xf0, 0x5d, 0xe1, 0xad, 0x70, 0xbf, 0xc3,
-	0x29, 0xde, 0x01, 0x7c, 0x16, 0x7e, 0xb0, 0x64, 0x4b, 0xaa, 0x1f, 0xde, 0xbc, 0x8c, 0x6e, 0x63,
-	0xbb, 0xcd, 0x7f, 0xd5, 0x9c, 0x7e, 0x9a, 0xf7, 0x7f, 0x03, 0x00, 0x00, 0xff, 0xff, 0xd4, 0xa2,
-	0x43, 0x02, 0x41, 0x03, 0x00, 0x00,
+	// 878 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x84, 0x55, 0x5d, 0x6f, 0xe3, 0x44,
+	0x14, 0xad, 0xe3, 0x3a, 0x89, 0x6f, 0xe3, 0x6c, 0x3b, 0xfd, 0xd8, 0x21, 0x80, 0x08, 0x06, 0x44,
+	0xd0, 0x8a, 0x22, 0x2d, 0x02, 0x01, 0x12, 0x0f, 0xbb, 0xa8, 0x94, 0x95, 0x76, 0xd1, 0xca, 0x2d,
+	0x4f, 0x3c, 0x58, 0x4e, 0x3c, 0x4a, 0x47, 0x1d, 0x7f, 0x30, 0x33, 0xde, 0x6d, 0xfa, 0xeb, 0xf8,
+	0x0f, 0xbc, 0xf0, 0x73, 0xd0, 0xdc, 0x19, 0x27, 0x71, 0x53, 0x89, 0x37, 0x9f, 0x73, 0xee, 0xdc,
+	0xb9, 0x73, 0xef, 0x99, 0x31, 0x84, 0x85, 0x50, 0xe7, 0xb5, 0xac, 0x74, 0x45, 0xfc, 0x42, 0xa8,
+	0xf8, 0x25, 0x8c, 0xde, 0x4a, 0xbe, 0x60, 0xbf, 0x71, 0xa5, 0x2b, 0xb9, 0x22, 0x27, 0x10, 0xd4,
+	0x06, 0x53, 0x6f, 0xea, 0xcd, 0x82, 0xc4, 0x02, 0xf2, 0x11, 0x84, 0x9a, 0x17, 0x4c, 0xe9, 0xac,
+	0xa8, 0x69, 0x6f, 0xea, 0xcd, 0xfc, 0x64, 0x43, 0xc4, 0x17, 0x10, 0x5d, 0xe9, 0x4c, 0x37, 0xaa,
+	0x4d, 0x72, 0x06, 0x7d, 0x85, 0x04, 0x66, 0x09, 0x13, 0x87, 0xfe, 0x27, 0xcd, 0x3f, 0x7d, 0x18,
+	0xbe, 0x95, 0x55, 0xcd, 0xa4, 0x5e, 0x11, 0x0a, 0x83, 0x2c, 0xcf, 0x25, 0x53, 0x6d, 0x8e, 0x16,
+	0x9a, 0x24, 0xf3, 0x4c, 0xdf, 0xc8, 0xaa, 0x2a, 0x14, 0x26, 0x09, 0x93, 0x0d, 0x41, 0x26, 0x30,
+	0x9c, 0xb3, 0xdc, 0x8a, 0x3e, 0x8a, 0x6b, 0x4c, 0x3e, 0x84, 0x50, 0x64, 0x65, 0x9e, 0x2a, 0x7e,
+	0xcf, 0xe8, 0xbe, 0x15, 0x0d, 0x71, 0xc5, 0xef, 0x19, 0x39, 0x85, 0x7e, 0x21, 0x54, 0xca, 0x73,
+	0x1a, 0xa0, 0x12, 0x14, 0x42, 0xbd, 0xca, 0xc9, 0xc7, 0x00, 0x86, 0x2e, 0x9b, 0x62, 0xce, 0x24,
+	0xed, 0xdb, 0xed, 0x0a, 0xa1, 0x7e, 0x47, 0x82, 0x3c, 0x85, 0x81, 0x91, 0x1b, 0x29, 0xe8, 0xc0,
+	0x1e, 0xb5, 0x10, 0xea, 0x0f, 0x29, 0x4c, 0xfd, 0x75, 0x26, 0x6f, 0x79, 0xb9, 0xa4, 0xc3, 0xa9,
+	0x6f, 0xea, 0x77, 0xd0, 0x54, 0x51, 0xdf, 0x54, 0xba, 0xc2, 0x45, 0x21, 0x6a, 0x43, 0x24, 0xcc,
+	0xb2, 0x2f, 0xdb, 0xf6, 0xc3, 0xd4, 0x9f, 0x1d, 0x3c, 0x3f, 0x3a, 0x37, 0xe3, 0xda, 0x1e, 0x50,
+	0x3b, 0x91, 0x2f, 0x60, 0x5c, 0x37, 0x73, 0xc1, 0x17, 0xa9, 0x64, 0x45, 0x26, 0x6f, 0x15, 0x3d,
+	0xc0, 0xfd, 0x23, 0xcb, 0x26, 0x96, 0x34, 0x65, 0x98, 0x65, 0x9c, 0x29, 0x3a, 0xb2, 0x6d, 0x74,
+	0x90, 0x7c, 0x06, 0x51, 0xed, 0x9a, 0x9d, 0xea, 0x55, 0xcd, 0x68, 0x84, 0xfa, 0xa8, 0x25, 0xaf,
+	0x57, 0x35, 0xee, 0x22, 0xb8, 0xd2, 0xe9, 0x66, 0x6a, 0x63, 0x9c, 0x5a, 0x64, 0xd8, 0xeb, 0x96,
+	0xc4, 0x79, 0x57, 0x8d, 0x5c, 0x30, 0xfa, 0xc4, 0xcd, 0x1b, 0x91, 0x19, 0x86, 0xc8, 0x34, 0xd7,
+	0x4d, 0xce, 0xe8, 0xe1, 0xd4, 0x9b, 0x79, 0xc9, 0x1a, 0x9b, 0x31, 0x8a, 0xaa, 0x5c, 0x5a, 0xf1,
+	0x08, 0xc5, 0x0d, 0x41, 0x08, 0xec, 0x2f, 0xb8, 0x5e, 0x51, 0x82, 0xf9, 0xf0, 0xdb, 0x58, 0xd3,
+	0xf8, 0x88, 0xd1, 0x63, 0x3b, 0x20, 0x04, 0xe6, 0x84, 0xf7, 0xbc, 0x5e, 0x54, 0x39, 0xa3, 0x27,
+	0xf6, 0x84, 0x0e, 0x6e, 0xb9, 0xf0, 0xb4, 0xe3, 0x42, 0x02, 0xfb, 0x3a, 0x5b, 0x2a, 0x7a, 0x86,
+	0xbd, 0xc7, 0x6f, 0x72, 0x09, 0x63, 0x51, 0x2d, 0x32, 0xc1, 0xef, 0x59, 0x9e, 0x6a, 0x76, 0xa7,
+	0xe9, 0x53, 0x1c, 0xc0, 0xd4, 0x0d, 0xc0, 0xf6, 0xe4, 0xfc, 0x75, 0x1b, 0x73, 0xcd, 0xee, 0xf4,
+	0x45, 0xa9, 0xe5, 0x2a, 0x89, 0xc4, 0x36, 0x47, 0x7e, 0x84, 0xb1, 0xdd, 0x26, 0xbd, 0xb1, 0x03,
+	0xa3, 0x14, 0x13, 0x11, 0x4c, 0xd4, 0xb9, 0x26, 0x49, 0xa4, 0xb6, 0xe1, 0xe4, 0x1a, 0xc8, 0x6e,
+	0x7e, 0x72, 0x08, 0xfe, 0x2d, 0x5b, 0xb9, 0x4b, 0x60, 0x3e, 0xc9, 0x0c, 0x82, 0x77, 0x99, 0x68,
+	0x18, 0x9a, 0xbf, 0xcd, 0xdc, 0x59, 0x99, 0xd8, 0x80, 0x9f, 0x7a, 0x3f, 0x78, 0xf1, 0x9f, 0x10,
+	0x75, 0xb4, 0x47, 0x9c, 0xe3, 0x3d, 0xe6, 0x9c, 0x1d, 0x7f, 0xf4, 0x76, 0xfd, 0x11, 0x7f, 0x07,
+	0xc3, 0xd7, 0x5c, 0x69, 0x5e, 0x2e, 0x15, 0xf9, 0x0a, 0x86, 0xad, 0x46, 0x3d, 0x3c, 0x73, 0xd4,
+	0x69, 0x5e, 0xb2, 0x96, 0xe3, 0x10, 0x06, 0x09, 0xfb, 0xab, 0x61, 0x4a, 0xc7, 0xdf, 0xc0, 0xd8,
+	0x65, 0x70, 0xcc, 0x83, 0x1b, 0xe7, 0x3d, 0xb8, 0x71, 0xf1, 0xbf, 0x3d, 0x88, 0x7e, 0xa9, 0x84,
+	0x60, 0x0b, 0xcd, 0xab, 0x32, 0x69, 0x4a, 0x73, 0x73, 0x65, 0x53, 0x9a, 0x9b, 0x6b, 0x83, 0x03,
+	0xd9, 0x94, 0xaf, 0xf2, 0x2d, 0x53, 0xf6, 0x3a, 0xa6, 0x3c, 0x83, 0xbe, 0x64, 0x4b, 0x5e, 0x95,
+	0xd4, 0x47, 0x03, 0x38, 0x64, 0xf6, 0x55, 0x3a, 0x93, 0x9a, 0xe5, 0x69, 0xa6, 0xf1, 0x79, 0xf0,
+	0x93, 0xd0, 0x31, 0x2f, 0x34, 0xf9, 0x00, 0x86, 0xac, 0xcc, 0xad, 0x18, 0xa0, 0x38, 0x40, 0xfc,
+	0x42, 0xe3, 0x9b, 0x99, 0x2d, 0x99, 0xa2, 0x7d, 0xf7, 0x66, 0x1a, 0x40, 0x3e, 0x81, 0x83, 0x1b,
+	0xad, 0xeb, 0x94, 0x49, 0x59, 0x49, 0x85, 0xcf, 0x43, 0x90, 0x80, 0xa1, 0x2e, 0x90, 0x21, 0x9f,
+	0xc2, 0xa8, 0x64, 0xef, 0x53, 0xe1, 0x1a, 0x48, 0x87, 0x18, 0x71, 0x50, 0xb2, 0xf7, 0x5b, 0x3d,
+	0x3d, 0x6c, 0xea, 0x3c, 0x33, 0x35, 0xad, 0xc3, 0x42, 0x0c, 0x7b, 0xe2, 0xf8, 0x75, 0xe8, 0x33,
+	0x38, 0xca, 0x99, 0x09, 0xda, 0x8e, 0x05, 0x8c, 0x3d, 0x6c, 0x85, 0x75, 0xf0, 0x09, 0x04, 0x58,
+	0x96, 0x7b, 0x34, 0x2c, 0x88, 0xbf, 0x87, 0x71, 0xa7, 0xb3, 0x8a, 0x7c, 0x0e, 0xbe, 0x6c, 0x4a,
+	0x37, 0x4e, 0x6b, 0xb4, 0x4e, 0x44, 0x62, 0xe4, 0xf8, 0x6b, 0x38, 0xed, 0xae, 0x6b, 0x47, 0x79,
+	0x02, 0x81, 0xe0, 0x05, 0xd7, 0xed, 0xcf, 0x04, 0xc1, 0xf3, 0xbf, 0x3d, 0x80, 0x37, 0x42, 0x5d,
+	0x31, 0xf9, 0xce, 0xbc, 0x64, 0xcf, 0x00, 0x2e, 0x99, 0x76, 0xa5, 0x91, 0x11, 0x6e, 0xe2, 0x12,
+	0x4c, 0xac, 0x83, 0xda, 0xb2, 0xe3, 0x3d, 0xf2, 0x33, 0x9c, 0x6e, 0x82, 0x5f, 0xae, 0xde, 0xac,
+	0x1f, 0xe2, 0xe3, 0xed, 0xc8, 0xee, 0xf2, 0xd6, 0x80, 0xf1, 0x1e, 0xf9, 0x15, 0x8e, 0x2e, 0x99,
+	0x7e, 0x70, 0xc8, 0xc9, 0xee, 0xb9, 0xda, 0x13, 0x4c, 0x8e, 0x1f, 0xd1, 0xe2, 0xbd, 0x79, 0x1f,
+	0xff, 0xa0, 0xdf, 0xfe, 0x37, 0x00, 0x3c, 0xfc, 0x37, 0x74, 0x4e, 0x07, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -399,6 +791,8 @@ const _ = grpc.SupportPackageIsVersion4
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type MlsServiceClient interface {
 	GetListing(ctx context.Context, in *Request, opts ...grpc.CallOption) (*Listings, error)
+	GetListingByMlsNumber(ctx context.Context, in *ListingRequest, opts ...grpc.CallOption) (*Property, error)
+	GetCollectionRuns(ctx context.Context, in *CollectionRunsRequest, opts ...grpc.CallOption) (*CollectionRuns, error)
 }
 
 type mlsServiceClient struct {
@@ -418,9 +812,29 @@ func (c *mlsServiceClient) GetListing(ctx context.Context, in *Request, opts ...
 	return out, nil
 }
 
+func (c *mlsServiceClient) GetListingByMlsNumber(ctx context.Context, in *ListingRequest, opts ...grpc.CallOption) (*Property, error) {
+	out := new(Property)
+	err := c.cc.Invoke(ctx, "/mls.MlsService/GetListingByMlsNumber", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mlsServiceClient) GetCollectionRuns(ctx context.Context, in *CollectionRunsRequest, opts ...grpc.CallOption) (*CollectionRuns, error) {
+	out := new(CollectionRuns)
+	err := c.cc.Invoke(ctx, "/mls.MlsService/GetCollectionRuns", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MlsServiceServer is the server API for MlsService service.
 type MlsServiceServer interface {
 	GetListing(context.Context, *Request) (*Listings, error)
+	GetListingByMlsNumber(context.Context, *ListingRequest) (*Property, error)
+	GetCollectionRuns(context.Context, *CollectionRunsRequest) (*CollectionRuns, error)
 }
 
 func RegisterMlsServiceServer(s *grpc.Server, srv MlsServiceServer) {
@@ -445,6 +859,42 @@ func _MlsService_GetListing_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MlsService_GetListingByMlsNumber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MlsServiceServer).GetListingByMlsNumber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mls.MlsService/GetListingByMlsNumber",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MlsServiceServer).GetListingByMlsNumber(ctx, req.(*ListingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MlsService_GetCollectionRuns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CollectionRunsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MlsServiceServer).GetCollectionRuns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/mls.MlsService/GetCollectionRuns",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MlsServiceServer).GetCollectionRuns(ctx, req.(*CollectionRunsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _MlsService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "mls.MlsService",
 	HandlerType: (*MlsServiceServer)(nil),
@@ -453,6 +903,14 @@ var _MlsService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "GetListing",
 			Handler:    _MlsService_GetListing_Handler,
 		},
+		{
+			MethodName: "GetListingByMlsNumber",
+			Handler:    _MlsService_GetListingByMlsNumber_Handler,
+		},
+		{
+			MethodName: "GetCollectionRuns",
+			Handler:    _MlsService_GetCollectionRuns_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "mls.proto",
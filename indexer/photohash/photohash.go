@@ -0,0 +1,110 @@
+// package photohash computes perceptual hashes of listing photos so the same
+// picture can be recognized even when it is served from a different URL.
+package photohash
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math/bits"
+	"net/http"
+)
+
+const (
+	// hashWidth is one column wider than the hash so every row produces 8
+	// left/right comparisons.
+	hashWidth  = 9
+	hashHeight = 8
+
+	// MaxDuplicateDistance is the most bits the hashes of the same picture
+	// differ by once it is re-encoded, resized or its colours shifted.
+	MaxDuplicateDistance = 2
+)
+
+// DHash computes the 64 bit difference hash of an image. The image is reduced
+// to a 9x8 grayscale grid and each bit records whether a pixel is brighter than
+// its right neighbour, so re-encoding, resizing and small colour shifts change
+// at most MaxDuplicateDistance bits of the hash.
+func DHash(img image.Image) uint64 {
+	gray := shrink(img, hashWidth, hashHeight)
+
+	var hash uint64
+	for y := 0; y < hashHeight; y++ {
+		for x := 0; x < hashWidth-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// Distance returns the number of bits that differ between two hashes. Photos
+// within MaxDuplicateDistance are the same picture.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Fetch downloads the photo at url and returns its difference hash, the
+// download stops when ctx is done.
+func Fetch(ctx context.Context, c *http.Client, url string) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download photo %q: %v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("failed to download photo %q: status %d", url, resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode photo %q: %v", url, err)
+	}
+	return DHash(img), nil
+}
+
+// shrink scales the image down to a w x h grid of luminance values by
+// averaging every source pixel that falls into each cell.
+func shrink(img image.Image, w, h int) [][]float64 {
+	bounds := img.Bounds()
+	sum := make([][]float64, h)
+	count := make([][]int, h)
+	for y := range sum {
+		sum[y] = make([]float64, w)
+		count[y] = make([]int, w)
+	}
+
+	dx, dy := bounds.Dx(), bounds.Dy()
+	if dx == 0 || dy == 0 {
+		return sum
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		cy := (y - bounds.Min.Y) * h / dy
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cx := (x - bounds.Min.X) * w / dx
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+			count[cy][cx]++
+		}
+	}
+
+	for y := range sum {
+		for x := range sum[y] {
+			if count[y][x] > 0 {
+				sum[y][x] /= float64(count[y][x])
+			}
+		}
+	}
+	return sum
+}
@@ -0,0 +1,120 @@
+package photohash
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+type roundTripFunc func(r *http.Request) *http.Response
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r), nil
+}
+
+func gradient(w, h int, reverse bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 255 / w)
+			if reverse {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{R: v, G: uint8(y * 255 / h), B: v, A: 255})
+		}
+	}
+	return img
+}
+
+func TestDHash(t *testing.T) {
+	t.Run("same picture at different sizes hashes the same", func(t *testing.T) {
+		small := DHash(gradient(90, 80, false))
+		large := DHash(gradient(900, 800, false))
+		if d := Distance(small, large); d != 0 {
+			t.Errorf("expected identical hashes, got distance %d (%x vs %x)", d, small, large)
+		}
+	})
+
+	t.Run("different pictures hash differently", func(t *testing.T) {
+		a := DHash(gradient(90, 80, false))
+		b := DHash(gradient(90, 80, true))
+		if d := Distance(a, b); d < 32 {
+			t.Errorf("expected distant hashes, got distance %d (%x vs %x)", d, a, b)
+		}
+	})
+
+	t.Run("re-encoding as jpeg keeps the hash", func(t *testing.T) {
+		img := gradient(180, 160, false)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 60}); err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := jpeg.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := Distance(DHash(img), DHash(decoded)); d > MaxDuplicateDistance {
+			t.Errorf("expected near identical hashes, got distance %d", d)
+		}
+	})
+}
+
+func TestFetch(t *testing.T) {
+	t.Run("download and hash a photo", func(t *testing.T) {
+		img := gradient(90, 80, false)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		c := &http.Client{Transport: roundTripFunc(func(r *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewReader(buf.Bytes())),
+				Header:     make(http.Header),
+			}
+		})}
+
+		hash, err := Fetch(context.Background(), c, "https://picture/listings/high/456.png")
+		if err != nil {
+			t.Fatalf("failed to fetch photo: %v", err)
+		}
+		if hash != DHash(img) {
+			t.Errorf("got hash %x, want %x", hash, DHash(img))
+		}
+	})
+
+	t.Run("reject a response that is not an image", func(t *testing.T) {
+		c := &http.Client{Transport: roundTripFunc(func(r *http.Request) *http.Response {
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString("not an image")),
+				Header:     make(http.Header),
+			}
+		})}
+
+		if _, err := Fetch(context.Background(), c, "https://picture/listings/high/456.jpg"); err == nil {
+			t.Error("expected an error decoding a non image response")
+		}
+	})
+
+	t.Run("download with the context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		c := &http.Client{Transport: roundTripFunc(func(r *http.Request) *http.Response {
+			if r.Context().Err() == nil {
+				t.Error("expected the request of the cancelled context")
+			}
+			return &http.Response{StatusCode: 503, Body: ioutil.NopCloser(bytes.NewBufferString("")), Header: make(http.Header)}
+		})}
+
+		if _, err := Fetch(ctx, c, "https://picture/listings/high/456.jpg"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
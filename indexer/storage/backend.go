@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+)
+
+// The backends OpenBackend opens.
+const (
+	SqliteBackend   = "sqlite"
+	PostgresBackend = "postgres"
+)
+
+// SQLDB is a DB whose SQL schema is versioned by migrations.
+type SQLDB interface {
+	DBInterface
+	// MigrationStatus lists the migrations in order with when they were
+	// applied.
+	MigrationStatus(ctx context.Context) ([]MigrationState, error)
+	// Migrate applies the pending migrations in order and returns them, a dry
+	// run only returns them.
+	Migrate(ctx context.Context, dryRun bool) ([]Migration, error)
+	// MigrateDown reverts the last steps applied migrations, latest first,
+	// and returns them, a dry run only returns them.
+	MigrateDown(ctx context.Context, steps int, dryRun bool) ([]Migration, error)
+}
+
+// OpenBackend opens the DB of the backend, the sqlite file at path or the postgres
+// DB of the DSN.
+func OpenBackend(backend, path, dsn string) (SQLDB, error) {
+	switch backend {
+	case SqliteBackend:
+		return NewSqliteDB(path)
+	case PostgresBackend:
+		if dsn == "" {
+			return nil, fmt.Errorf("the %s backend needs a DSN", backend)
+		}
+		return NewPostgresDB(dsn)
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", backend)
+}
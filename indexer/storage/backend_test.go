@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestOpenBackend(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		cleanSqliteDB("/tmp/realtor25.db")
+		defer cleanSqliteDB("/tmp/realtor25.db")
+		db, err := OpenBackend(SqliteBackend, "/tmp/realtor25.db", "")
+		if err != nil {
+			t.Fatalf("failed to open the sqlite backend: %v", err)
+		}
+		if err := db.CreateStorage(context.Background()); err != nil {
+			t.Errorf("failed to create the sqlite storage: %v", err)
+		}
+	})
+
+	t.Run("postgres without a DSN", func(t *testing.T) {
+		if _, err := OpenBackend(PostgresBackend, "/tmp/realtor25.db", ""); err == nil {
+			t.Error("want an error opening the postgres backend without a DSN")
+		}
+	})
+
+	t.Run("unknown backend", func(t *testing.T) {
+		if _, err := OpenBackend("mysql", "/tmp/realtor25.db", ""); err == nil {
+			t.Error("want an error opening an unknown backend")
+		}
+	})
+}
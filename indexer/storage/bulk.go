@@ -0,0 +1,50 @@
+package storage
+
+import (
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+)
+
+// SaveOutcome is what SaveListings did with a listing.
+type SaveOutcome int
+
+const (
+	// Created is a new listing saved.
+	Created SaveOutcome = iota
+	// Updated is a saved listing with a new price.
+	Updated
+	// Unchanged is a saved listing without a new price.
+	Unchanged
+	// Failed is a listing not saved, the result has the error.
+	Failed
+)
+
+var saveOutcomeName = map[SaveOutcome]string{
+	Created:   "created",
+	Updated:   "updated",
+	Unchanged: "unchanged",
+	Failed:    "failed",
+}
+
+func (o SaveOutcome) String() string {
+	return saveOutcomeName[o]
+}
+
+// SaveResult is the outcome of saving one listing of SaveListings.
+type SaveResult struct {
+	MlsNumber string
+	Outcome   SaveOutcome
+	Err       error
+}
+
+// newPrices returns the prices that change the latest saved price, in order.
+func newPrices(latest int32, priced bool, prices []*mlspb.PriceHistory) []*mlspb.PriceHistory {
+	changed := []*mlspb.PriceHistory{}
+	for _, p := range prices {
+		if priced && p.Price == latest {
+			continue
+		}
+		changed = append(changed, p)
+		latest, priced = p.Price, true
+	}
+	return changed
+}
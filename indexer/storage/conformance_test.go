@@ -0,0 +1,66 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+	"github.com/tony-yang/realtor-tracker/indexer/storage/storagetest"
+)
+
+func TestMemoryConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.DBInterface {
+		db, err := storage.NewMemoryDB(map[string]*storage.City{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+func TestSqliteConformance(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storagetest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	n := 0
+	storagetest.Run(t, func(t *testing.T) storage.DBInterface {
+		n++
+		db, err := storage.NewSqliteDB(filepath.Join(dir, fmt.Sprintf("%d.db", n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
+
+// TestPostgresConformance runs against the postgres DB of REALTOR_POSTGRES_DSN,
+// its tables are dropped before every test.
+func TestPostgresConformance(t *testing.T) {
+	dsn := os.Getenv("REALTOR_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("REALTOR_POSTGRES_DSN is not set")
+	}
+	db, err := storage.NewPostgresDB(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.DBInterface {
+		ctx := context.Background()
+		states, err := db.MigrationStatus(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.MigrateDown(ctx, len(states), false); err != nil {
+			t.Fatal(err)
+		}
+		return db
+	})
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -112,7 +113,7 @@ func (d *DB) SaveNewListing(mlsNumber string, listing *mlspb.Property) error {
 	}
 	statement.Exec(
 		listing.MlsNumber, listing.MlsId, listing.MlsUrl, listing.Bathrooms,
-		listing.Bedrooms, listing.LandSize, listing.Parking,
+		listing.Bedrooms, listing.LandSize, strings.Join(listing.Parking, ";"),
 		listing.PublicRemarks, listing.Stories, listing.PropertyType,
 		time.Now().Unix(), 1)
 
@@ -143,8 +144,58 @@ func (d *DB) SaveNewListing(mlsNumber string, listing *mlspb.Property) error {
 	return nil
 }
 
-func (d *DB) ReadListing(id string) (string, error) {
-	return "", nil
+// ReadListing reads a listing with its photos and price history. The legacy
+// schema keeps no status history, the listing is read without one.
+func (d *DB) ReadListing(mlsNumber string) (*mlspb.Property, error) {
+	if err := d.CreateStorage(); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+
+	p := &mlspb.Property{}
+	var parking string
+	err := d.db.QueryRow(`SELECT mlsNumber, mlsId, mlsUrl, bathrooms, bedrooms, landSize, parking, publicRemark, stories, propertyType, availableTimestamp
+			FROM mls WHERE mlsNumber = $1`, mlsNumber).Scan(
+		&p.MlsNumber, &p.MlsId, &p.MlsUrl, &p.Bathrooms, &p.Bedrooms, &p.LandSize, &parking,
+		&p.PublicRemarks, &p.Stories, &p.PropertyType, &p.ListTimestamp)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read listing %s: %v", mlsNumber, err)
+	}
+	if parking != "" {
+		p.Parking = strings.Split(parking, ";")
+	}
+	if err := d.db.QueryRow(`SELECT address FROM property WHERE mlsNumber = $1`, mlsNumber).Scan(&p.Address); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to read the address of listing %s: %v", mlsNumber, err)
+	}
+
+	photoRows, err := d.db.Query(`SELECT photoUrl FROM photo WHERE mlsNumber = $1`, mlsNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the photos of listing %s: %v", mlsNumber, err)
+	}
+	defer photoRows.Close()
+	for photoRows.Next() {
+		var photoURL string
+		if err := photoRows.Scan(&photoURL); err != nil {
+			return nil, err
+		}
+		p.PhotoUrl = append(p.PhotoUrl, photoURL)
+	}
+
+	priceRows, err := d.db.Query(`SELECT price, priceTimestamp FROM priceHistory WHERE mlsNumber = $1 ORDER BY priceTimestamp`, mlsNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the price history of listing %s: %v", mlsNumber, err)
+	}
+	defer priceRows.Close()
+	for priceRows.Next() {
+		pr := &mlspb.PriceHistory{}
+		if err := priceRows.Scan(&pr.Price, &pr.Timestamp); err != nil {
+			return nil, err
+		}
+		p.Price = append(p.Price, pr)
+	}
+	return p, nil
 }
 
 func (d *DB) ReadListings() (*mlspb.Listings, error) {
@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+)
+
+// FieldChange is a listing field whose collected value differs from the saved
+// one. Informational is true when saving the listing does not write the field,
+// eg. the address of a saved listing.
+type FieldChange struct {
+	Field         string `json:"field"`
+	Old           string `json:"old"`
+	New           string `json:"new"`
+	Informational bool   `json:"informational,omitempty"`
+}
+
+// PriceChange is a new price collected for a saved listing.
+type PriceChange struct {
+	Old       int32 `json:"old"`
+	New       int32 `json:"new"`
+	Timestamp int64 `json:"timestamp"`
+}
+
+// ListingChange is what saving a collected listing would do.
+type ListingChange struct {
+	MlsNumber string         `json:"mls_number"`
+	Source    string         `json:"source"`
+	New       bool           `json:"new"`
+	Fields    []*FieldChange `json:"fields,omitempty"`
+	Prices    []*PriceChange `json:"prices,omitempty"`
+}
+
+// updated is true when saving the listing writes one of its changes.
+func (c *ListingChange) updated() bool {
+	if len(c.Prices) > 0 {
+		return true
+	}
+	for _, f := range c.Fields {
+		if !f.Informational {
+			return true
+		}
+	}
+	return false
+}
+
+// DryRunReport lists the changes of a dry run by mls number.
+type DryRunReport struct {
+	New     int              `json:"new"`
+	Changed int              `json:"changed"`
+	Changes []*ListingChange `json:"changes"`
+}
+
+// WriteText writes the report as a human readable diff.
+func (r *DryRunReport) WriteText(w io.Writer) error {
+	for _, c := range r.Changes {
+		var err error
+		if c.New {
+			_, err = fmt.Fprintf(w, "+ %s (%s) new listing\n", c.MlsNumber, c.Source)
+		} else {
+			_, err = fmt.Fprintf(w, "~ %s (%s)\n", c.MlsNumber, c.Source)
+		}
+		if err != nil {
+			return err
+		}
+		for _, f := range c.Fields {
+			note := ""
+			if f.Informational {
+				note = " (not saved)"
+			}
+			if _, err := fmt.Fprintf(w, "    %s: %q -> %q%s\n", f.Field, f.Old, f.New, note); err != nil {
+				return err
+			}
+		}
+		for _, p := range c.Prices {
+			if _, err := fmt.Fprintf(w, "    price: %d -> %d\n", p.Old, p.New); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d new, %d changed listings\n", r.New, r.Changed)
+	return err
+}
+
+// listingFields are the fields compared between a saved and a collected
+// listing, the repeated fields are joined as the sqlite DB saves them. Saving
+// a saved listing writes none of them.
+var listingFields = []struct {
+	name  string
+	value func(p *mlspb.Property) string
+}{
+	{"address", func(p *mlspb.Property) string { return p.Address }},
+	{"bathrooms", func(p *mlspb.Property) string { return p.Bathrooms }},
+	{"bedrooms", func(p *mlspb.Property) string { return p.Bedrooms }},
+	{"land_size", func(p *mlspb.Property) string { return p.LandSize }},
+	{"mls_id", func(p *mlspb.Property) string { return p.MlsId }},
+	{"mls_url", func(p *mlspb.Property) string { return p.MlsUrl }},
+	{"parking", func(p *mlspb.Property) string { return strings.Join(p.Parking, ";") }},
+	{"photo_url", func(p *mlspb.Property) string { return strings.Join(p.PhotoUrl, ";") }},
+	{"public_remarks", func(p *mlspb.Property) string { return p.PublicRemarks }},
+	{"stories", func(p *mlspb.Property) string { return p.Stories }},
+	{"property_type", func(p *mlspb.Property) string { return p.PropertyType }},
+	{"list_timestamp", func(p *mlspb.Property) string { return strconv.FormatInt(p.ListTimestamp, 10) }},
+	{"latitude", func(p *mlspb.Property) string { return strconv.FormatFloat(p.Latitude, 'f', -1, 64) }},
+	{"longitude", func(p *mlspb.Property) string { return strconv.FormatFloat(p.Longitude, 'f', -1, 64) }},
+	{"city", func(p *mlspb.Property) string { return p.City }},
+	{"state", func(p *mlspb.Property) string { return p.State }},
+	{"zipcode", func(p *mlspb.Property) string { return p.Zipcode }},
+}
+
+// DryRunDB reads from a DB but never writes to it, it records what saving the
+// collected listings would change instead.
+type DryRunDB struct {
+	DB DBInterface
+
+	lock sync.Mutex
+	// saved holds the listings of DB and the ones saved during the dry run,
+	// it is loaded on the first save.
+	saved   map[string]*mlspb.Property
+	changes map[string]*ListingChange
+}
+
+// NewDryRunDB creates a dry run over the listings of s.
+func NewDryRunDB(s DBInterface) *DryRunDB {
+	return &DryRunDB{
+		DB:      s,
+		changes: make(map[string]*ListingChange),
+	}
+}
+
+// loadSaved reads every saved listing once, the caller must hold the lock.
+func (d *DryRunDB) loadSaved(ctx context.Context) error {
+	if d.saved != nil {
+		return nil
+	}
+	it, err := d.DB.IterateListings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read the saved listings: %v", err)
+	}
+	defer it.Close()
+	saved := make(map[string]*mlspb.Property)
+	for it.Next() {
+		saved[it.Listing().MlsNumber] = it.Listing()
+	}
+	if err := it.Err(); err != nil {
+		return fmt.Errorf("failed to read the saved listings: %v", err)
+	}
+	d.saved = saved
+	return nil
+}
+
+// Report returns the changes recorded so far.
+func (d *DryRunDB) Report() *DryRunReport {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	report := &DryRunReport{Changes: []*ListingChange{}}
+	for _, c := range d.changes {
+		if c.New {
+			report.New++
+		} else if c.updated() {
+			report.Changed++
+		}
+		report.Changes = append(report.Changes, c)
+	}
+	sort.Slice(report.Changes, func(i, j int) bool { return report.Changes[i].MlsNumber < report.Changes[j].MlsNumber })
+	return report
+}
+
+// CreateStorage creates the storage of DB, so it can be read.
+func (d *DryRunDB) CreateStorage(ctx context.Context) error {
+	return d.DB.CreateStorage(ctx)
+}
+
+// SaveNewListing records the listing as new, it fails like DB when the
+// listing is already saved.
+func (d *DryRunDB) SaveNewListing(ctx context.Context, p *mlspb.Property) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.loadSaved(ctx); err != nil {
+		return err
+	}
+	return d.saveNew(p)
+}
+
+// saveNew records a new listing, the caller must hold the lock.
+func (d *DryRunDB) saveNew(p *mlspb.Property) error {
+	if p.MlsNumber == "" {
+		return fmt.Errorf("%w: missing MLS number", ErrInvalidListing)
+	}
+	if _, ok := d.saved[p.MlsNumber]; ok {
+		return fmt.Errorf("%w: %s", ErrListingExists, p.MlsNumber)
+	}
+	status, err := savedStatus(p)
+	if err != nil {
+		return err
+	}
+	d.saved[p.MlsNumber] = proto.Clone(p).(*mlspb.Property)
+	d.saved[p.MlsNumber].Status = status
+	d.changes[p.MlsNumber] = &ListingChange{
+		MlsNumber: p.MlsNumber,
+		Source:    p.Source,
+		New:       true,
+	}
+	return nil
+}
+
+// UpdateListing records the fields and prices of the listing that differ from
+// the saved listing.
+func (d *DryRunDB) UpdateListing(ctx context.Context, p *mlspb.Property) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.loadSaved(ctx); err != nil {
+		return err
+	}
+	return d.update(p)
+}
+
+// update records the changes of a saved listing, the caller must hold the
+// lock.
+func (d *DryRunDB) update(p *mlspb.Property) error {
+	old, ok := d.saved[p.MlsNumber]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, p.MlsNumber)
+	}
+	if _, err := savedStatus(p); err != nil {
+		return err
+	}
+	if c, ok := d.changes[p.MlsNumber]; ok && c.New {
+		// The listing is already reported as new.
+		return nil
+	}
+
+	c := &ListingChange{MlsNumber: p.MlsNumber, Source: old.Source}
+	for _, f := range listingFields {
+		if oldValue, newValue := f.value(old), f.value(p); oldValue != newValue {
+			c.Fields = append(c.Fields, &FieldChange{Field: f.name, Old: oldValue, New: newValue, Informational: true})
+		}
+	}
+	// A listing collected without tags keeps the saved ones.
+	if oldTags, newTags := strings.Join(old.Tags, ";"), strings.Join(uniqueTags(p.Tags), ";"); oldTags != newTags {
+		c.Fields = append(c.Fields, &FieldChange{Field: "tags", Old: oldTags, New: newTags, Informational: len(p.Tags) == 0})
+	}
+	// A listing collected without a status keeps the saved one.
+	if p.Status != "" && p.Status != old.Status {
+		c.Fields = append(c.Fields, &FieldChange{Field: "status", Old: old.Status, New: p.Status})
+	}
+	for _, lang := range languages(p) {
+		oldText, newText := old.LocalizedText[lang], p.LocalizedText[lang]
+		if newText == nil {
+			continue
+		}
+		if oldText == nil {
+			oldText = &mlspb.LocalizedText{}
+		}
+		if oldText.PublicRemarks != newText.PublicRemarks {
+			c.Fields = append(c.Fields, &FieldChange{Field: "public_remarks." + lang, Old: oldText.PublicRemarks, New: newText.PublicRemarks})
+		}
+		if oldText.PropertyType != newText.PropertyType {
+			c.Fields = append(c.Fields, &FieldChange{Field: "property_type." + lang, Old: oldText.PropertyType, New: newText.PropertyType})
+		}
+	}
+
+	var oldPrice int32
+	if len(old.Price) > 0 {
+		oldPrice = old.Price[len(old.Price)-1].Price
+	}
+	for _, pr := range p.Price {
+		if pr.Price != oldPrice {
+			c.Prices = append(c.Prices, &PriceChange{Old: oldPrice, New: pr.Price, Timestamp: pr.Timestamp})
+			oldPrice = pr.Price
+		}
+	}
+
+	if len(c.Fields) > 0 || len(c.Prices) > 0 {
+		d.changes[p.MlsNumber] = c
+	} else {
+		delete(d.changes, p.MlsNumber)
+	}
+	return nil
+}
+
+// languages returns the languages of the listing text in order.
+func languages(p *mlspb.Property) []string {
+	langs := []string{}
+	for lang := range p.LocalizedText {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// SaveListings records the listing changes of the listings one at a time.
+func (d *DryRunDB) SaveListings(ctx context.Context, listings []*mlspb.Property) ([]*SaveResult, error) {
+	results := make([]*SaveResult, len(listings))
+	for i, p := range listings {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r := &SaveResult{MlsNumber: p.MlsNumber}
+		r.Outcome, r.Err = d.SaveOrUpdateListing(ctx, p)
+		results[i] = r
+	}
+	return results, nil
+}
+
+// SaveOrUpdateListing records the listing as new, or the changes of the saved
+// listing.
+func (d *DryRunDB) SaveOrUpdateListing(ctx context.Context, p *mlspb.Property) (SaveOutcome, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if err := d.loadSaved(ctx); err != nil {
+		return Failed, err
+	}
+	err := d.saveNew(p)
+	if err == nil {
+		return Created, nil
+	}
+	if !errors.Is(err, ErrListingExists) {
+		return Failed, err
+	}
+	if err := d.update(p); err != nil {
+		return Failed, err
+	}
+	if c, ok := d.changes[p.MlsNumber]; ok && c.updated() {
+		return Updated, nil
+	}
+	return Unchanged, nil
+}
+
+// IterateListings reads the listings of DB one at a time.
+func (d *DryRunDB) IterateListings(ctx context.Context) (ListingIterator, error) {
+	return d.DB.IterateListings(ctx)
+}
+
+// QueryListings queries the listings of DB.
+func (d *DryRunDB) QueryListings(ctx context.Context, q ListingQuery) (*ListingPage, error) {
+	return d.DB.QueryListings(ctx, q)
+}
+
+// ReadListing reads a listing of DB.
+func (d *DryRunDB) ReadListing(ctx context.Context, mlsNumber string) (*mlspb.Property, error) {
+	return d.DB.ReadListing(ctx, mlsNumber)
+}
+
+// ReadListings reads the listings of DB.
+func (d *DryRunDB) ReadListings(ctx context.Context) (*mlspb.Listings, error) {
+	return d.DB.ReadListings(ctx)
+}
+
+// SavePhotoHash does not save the hash.
+func (d *DryRunDB) SavePhotoHash(ctx context.Context, photoURL string, hash uint64) error {
+	return nil
+}
+
+// FindDuplicatePhotos finds the duplicate photos of DB.
+func (d *DryRunDB) FindDuplicatePhotos(ctx context.Context) ([]*PhotoDuplicate, error) {
+	return d.DB.FindDuplicatePhotos(ctx)
+}
+
+// SaveListingTags does not save the tags, they are compared by UpdateListing.
+func (d *DryRunDB) SaveListingTags(ctx context.Context, mlsNumber string, tags []string) error {
+	return nil
+}
+
+// ReadListingsByTags reads the listings of DB.
+func (d *DryRunDB) ReadListingsByTags(ctx context.Context, tags []string) (*mlspb.Listings, error) {
+	return d.DB.ReadListingsByTags(ctx, tags)
+}
+
+// SaveCheckpoint does not save the checkpoint.
+func (d *DryRunDB) SaveCheckpoint(ctx context.Context, c *Checkpoint) error {
+	return nil
+}
+
+// ReadCheckpoint returns no checkpoint, a dry run always starts from the
+// first page.
+func (d *DryRunDB) ReadCheckpoint(ctx context.Context, source string) (*Checkpoint, error) {
+	return nil, nil
+}
+
+// DeleteCheckpoint does not delete the checkpoint of DB.
+func (d *DryRunDB) DeleteCheckpoint(ctx context.Context, source string) error {
+	return nil
+}
+
+// MarkListingSeen does not mark the listing.
+func (d *DryRunDB) MarkListingSeen(ctx context.Context, mlsNumber string, timestamp int64) error {
+	return nil
+}
+
+// DelistUnseen does not delist any listing.
+func (d *DryRunDB) DelistUnseen(ctx context.Context, source string, since int64) (int64, error) {
+	return 0, nil
+}
+
+// ReadLatestPrice reads the last price recorded by the dry run, or the last
+// price of DB before the first save.
+func (d *DryRunDB) ReadLatestPrice(ctx context.Context, mlsNumber string) (int32, bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.saved == nil {
+		return d.DB.ReadLatestPrice(ctx, mlsNumber)
+	}
+	p, ok := d.saved[mlsNumber]
+	if !ok {
+		return 0, false, nil
+	}
+	if c, ok := d.changes[mlsNumber]; ok && len(c.Prices) > 0 {
+		return c.Prices[len(c.Prices)-1].New, true, nil
+	}
+	if len(p.Price) == 0 {
+		return 0, true, nil
+	}
+	return p.Price[len(p.Price)-1].Price, true, nil
+}
+
+// SaveCollectionRun does not save the run.
+func (d *DryRunDB) SaveCollectionRun(ctx context.Context, r *mlspb.CollectionRun) error {
+	return nil
+}
+
+// ReadCollectionRuns reads the runs of DB.
+func (d *DryRunDB) ReadCollectionRuns(ctx context.Context, limit int) (*mlspb.CollectionRuns, error) {
+	return d.DB.ReadCollectionRuns(ctx, limit)
+}
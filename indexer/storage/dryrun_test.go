@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+)
+
+func TestDryRunDB(t *testing.T) {
+	saved := &mlspb.Property{
+		Address:   "1234 street|city, province A0B1C2",
+		MlsNumber: "19016318",
+		Bedrooms:  "3 + 0",
+		Price:     []*mlspb.PriceHistory{{Price: 10000, Timestamp: 1}},
+		Source:    "mls-canada",
+	}
+
+	t.Run("record the changes without writing", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), saved)
+		d := NewDryRunDB(mDB)
+
+		if err := d.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "new", Source: "mls-canada"}); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		if err := d.SaveNewListing(context.Background(), saved); !errors.Is(err, ErrListingExists) {
+			t.Errorf("expected the listing to exist, got %v", err)
+		}
+		changed := &mlspb.Property{
+			Address:   saved.Address,
+			MlsNumber: saved.MlsNumber,
+			Bedrooms:  "4 + 0",
+			Price:     []*mlspb.PriceHistory{{Price: 9000, Timestamp: 2}},
+		}
+		if err := d.UpdateListing(context.Background(), changed); err != nil {
+			t.Errorf("Failed to update the listing: %v", err)
+		}
+
+		listings, _ := mDB.ReadListings(context.Background())
+		if len(listings.Property) != 1 || listings.Property[0].Bedrooms != "3 + 0" || len(listings.Property[0].Price) != 1 {
+			t.Errorf("expected the DB not to change, got %v", listings.Property)
+		}
+
+		report := d.Report()
+		if report.New != 1 || report.Changed != 1 || len(report.Changes) != 2 {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		c := report.Changes[0]
+		if c.MlsNumber != "19016318" || c.New || len(c.Fields) != 1 || len(c.Prices) != 1 {
+			t.Fatalf("unexpected change %+v", c)
+		}
+		if *c.Fields[0] != (FieldChange{Field: "bedrooms", Old: "3 + 0", New: "4 + 0", Informational: true}) {
+			t.Errorf("unexpected field change %+v", c.Fields[0])
+		}
+		if *c.Prices[0] != (PriceChange{Old: 10000, New: 9000, Timestamp: 2}) {
+			t.Errorf("unexpected price change %+v", c.Prices[0])
+		}
+	})
+
+	t.Run("skip an unchanged listing", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), saved)
+		d := NewDryRunDB(mDB)
+
+		if err := d.UpdateListing(context.Background(), saved); err != nil {
+			t.Errorf("Failed to update the listing: %v", err)
+		}
+		if report := d.Report(); len(report.Changes) != 0 {
+			t.Errorf("expected no change, got %+v", report.Changes)
+		}
+	})
+
+	t.Run("report a status change", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), saved)
+		d := NewDryRunDB(mDB)
+
+		sold := &mlspb.Property{
+			Address:   saved.Address,
+			MlsNumber: saved.MlsNumber,
+			Bedrooms:  saved.Bedrooms,
+			Price:     saved.Price,
+			Status:    "Sold",
+		}
+		if outcome, err := d.SaveOrUpdateListing(context.Background(), sold); outcome != Updated || err != nil {
+			t.Errorf("expected the status change to update the listing, got %s, %v", outcome, err)
+		}
+		c := d.Report().Changes[0]
+		if len(c.Fields) != 1 || *c.Fields[0] != (FieldChange{Field: "status", Old: "Open", New: "Sold"}) {
+			t.Errorf("unexpected change %+v", c)
+		}
+	})
+
+	t.Run("report the fields not saved as informational", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), saved)
+		d := NewDryRunDB(mDB)
+
+		moved := &mlspb.Property{
+			Address:   "4321 street|city, province A0B1C2",
+			MlsNumber: saved.MlsNumber,
+			Bedrooms:  saved.Bedrooms,
+			Price:     saved.Price,
+			Source:    saved.Source,
+		}
+		if outcome, err := d.SaveOrUpdateListing(context.Background(), moved); outcome != Unchanged || err != nil {
+			t.Errorf("expected the address change not to update the listing, got %s, %v", outcome, err)
+		}
+		report := d.Report()
+		if report.Changed != 0 || len(report.Changes) != 1 {
+			t.Fatalf("unexpected report %+v", report)
+		}
+		if c := report.Changes[0]; len(c.Fields) != 1 || !c.Fields[0].Informational || c.Fields[0].Field != "address" {
+			t.Errorf("unexpected change %+v", c)
+		}
+
+		tagged := proto.Clone(moved).(*mlspb.Property)
+		tagged.Tags = []string{"pool", "garage", "pool"}
+		if outcome, err := d.SaveOrUpdateListing(context.Background(), tagged); outcome != Updated || err != nil {
+			t.Errorf("expected the tags to update the listing, got %s, %v", outcome, err)
+		}
+		c := d.Report().Changes[0]
+		if len(c.Fields) != 2 || *c.Fields[1] != (FieldChange{Field: "tags", Old: "", New: "garage;pool"}) {
+			t.Errorf("unexpected change %+v", c)
+		}
+	})
+
+	t.Run("read the latest price saved by the dry run", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), saved)
+		d := NewDryRunDB(mDB)
+		ctx := context.Background()
+
+		if price, found, err := d.ReadLatestPrice(ctx, saved.MlsNumber); price != 10000 || !found || err != nil {
+			t.Errorf("got %d, %t, %v, want the saved price", price, found, err)
+		}
+		d.SaveOrUpdateListing(ctx, &mlspb.Property{MlsNumber: "new", Price: []*mlspb.PriceHistory{{Price: 5000, Timestamp: 1}}})
+		d.SaveOrUpdateListing(ctx, &mlspb.Property{MlsNumber: saved.MlsNumber, Price: []*mlspb.PriceHistory{{Price: 9000, Timestamp: 2}}})
+		for mlsNumber, want := range map[string]int32{"new": 5000, saved.MlsNumber: 9000} {
+			if price, found, err := d.ReadLatestPrice(ctx, mlsNumber); price != want || !found || err != nil {
+				t.Errorf("%s: got %d, %t, %v, want %d", mlsNumber, price, found, err, want)
+			}
+		}
+		if _, found, err := d.ReadLatestPrice(ctx, "missing"); found || err != nil {
+			t.Errorf("expected the listing not to be found, got %t, %v", found, err)
+		}
+	})
+
+	t.Run("report the outcome of saving or updating", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), saved)
+		d := NewDryRunDB(mDB)
+		ctx := context.Background()
+
+		if outcome, err := d.SaveOrUpdateListing(ctx, &mlspb.Property{MlsNumber: "new"}); err != nil || outcome != Created {
+			t.Errorf("got %s, %v, want created", outcome, err)
+		}
+		if outcome, err := d.SaveOrUpdateListing(ctx, saved); err != nil || outcome != Unchanged {
+			t.Errorf("got %s, %v, want unchanged", outcome, err)
+		}
+		changed := &mlspb.Property{MlsNumber: saved.MlsNumber, Bedrooms: saved.Bedrooms, Source: saved.Source, Address: saved.Address,
+			Price: []*mlspb.PriceHistory{{Price: 9000, Timestamp: 2}}}
+		if outcome, err := d.SaveOrUpdateListing(ctx, changed); err != nil || outcome != Updated {
+			t.Errorf("got %s, %v, want updated", outcome, err)
+		}
+		if _, err := d.SaveOrUpdateListing(ctx, &mlspb.Property{}); !errors.Is(err, ErrInvalidListing) {
+			t.Errorf("expected an invalid listing, got %v", err)
+		}
+		if err := d.UpdateListing(context.Background(), &mlspb.Property{MlsNumber: "missing"}); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the listing not to be found, got %v", err)
+		}
+	})
+
+	t.Run("write the report as text", func(t *testing.T) {
+		report := &DryRunReport{
+			New:     1,
+			Changed: 1,
+			Changes: []*ListingChange{
+				{MlsNumber: "1", Source: "mls-canada", New: true},
+				{
+					MlsNumber: "2",
+					Source:    "mls-canada",
+					Fields: []*FieldChange{
+						{Field: "bedrooms", Old: "3", New: "4", Informational: true},
+						{Field: "status", Old: "Open", New: "Sold"},
+					},
+					Prices: []*PriceChange{{Old: 10000, New: 9000}},
+				},
+			},
+		}
+		var out bytes.Buffer
+		if err := report.WriteText(&out); err != nil {
+			t.Fatal(err)
+		}
+		want := `+ 1 (mls-canada) new listing
+~ 2 (mls-canada)
+    bedrooms: "3" -> "4" (not saved)
+    status: "Open" -> "Sold"
+    price: 10000 -> 9000
+1 new, 1 changed listings
+`
+		if out.String() != want {
+			t.Errorf("got %q, want %q", out.String(), want)
+		}
+	})
+}
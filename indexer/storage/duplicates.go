@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/tony-yang/realtor-tracker/indexer/photohash"
+)
+
+// duplicatePhotos groups the listings whose photo hashes are within
+// photohash.MaxDuplicateDistance of each other, so a photo re-encoded for a
+// relisting is still found. Near hashes are grouped transitively and a group
+// is reported under its lowest hash.
+func duplicatePhotos(listingsByHash map[uint64]map[string]bool) []*PhotoDuplicate {
+	hashes := make([]uint64, 0, len(listingsByHash))
+	for hash := range listingsByHash {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool { return hashes[i] < hashes[j] })
+
+	group := make(map[uint64]uint64, len(hashes))
+	for _, hash := range hashes {
+		group[hash] = hash
+	}
+	var find func(hash uint64) uint64
+	find = func(hash uint64) uint64 {
+		if group[hash] != hash {
+			group[hash] = find(group[hash])
+		}
+		return group[hash]
+	}
+
+	// Two hashes within the distance share at least one of distance+1 blocks
+	// of bits, only the hashes of a same block are compared.
+	blocks := photohash.MaxDuplicateDistance + 1
+	for b := 0; b < blocks; b++ {
+		start, end := uint(b*64/blocks), uint((b+1)*64/blocks)
+		mask := uint64(1)<<(end-start) - 1
+		buckets := make(map[uint64][]uint64)
+		for _, hash := range hashes {
+			key := hash >> start & mask
+			buckets[key] = append(buckets[key], hash)
+		}
+		for _, bucket := range buckets {
+			for i := range bucket {
+				for _, other := range bucket[i+1:] {
+					if photohash.Distance(bucket[i], other) > photohash.MaxDuplicateDistance {
+						continue
+					}
+					a, b := find(bucket[i]), find(other)
+					if a > b {
+						a, b = b, a
+					}
+					group[b] = a
+				}
+			}
+		}
+	}
+
+	listingsByGroup := make(map[uint64]map[string]bool)
+	for _, hash := range hashes {
+		root := find(hash)
+		if _, ok := listingsByGroup[root]; !ok {
+			listingsByGroup[root] = make(map[string]bool)
+		}
+		for mlsNumber := range listingsByHash[hash] {
+			listingsByGroup[root][mlsNumber] = true
+		}
+	}
+
+	duplicates := []*PhotoDuplicate{}
+	for hash, mlsNumbers := range listingsByGroup {
+		if len(mlsNumbers) < 2 {
+			continue
+		}
+		d := &PhotoDuplicate{Hash: hash}
+		for mlsNumber := range mlsNumbers {
+			d.MlsNumbers = append(d.MlsNumbers, mlsNumber)
+		}
+		sort.Strings(d.MlsNumbers)
+		duplicates = append(duplicates, d)
+	}
+	sort.Slice(duplicates, func(i, j int) bool { return duplicates[i].Hash < duplicates[j].Hash })
+	return duplicates
+}
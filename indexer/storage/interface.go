@@ -2,14 +2,125 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
 	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
 )
 
+var (
+	// ErrNotFound is returned when a listing read or changed by MLS number is
+	// not saved.
+	ErrNotFound = errors.New("listing not found")
+	// ErrListingExists is returned when a new listing is already saved.
+	ErrListingExists = errors.New("listing exists")
+	// ErrInvalidListing is returned when a listing cannot be saved as is, eg.
+	// without an MLS number.
+	ErrInvalidListing = errors.New("invalid listing")
+)
+
+// PhotoDuplicate groups the listings whose photos have near perceptual hashes,
+// ie. the same picture published under different listings.
+type PhotoDuplicate struct {
+	// Hash is the lowest hash of the group.
+	Hash       uint64
+	MlsNumbers []string
+}
+
+// Checkpoint records how far a collector run got, so a restarted run resumes
+// from it instead of the first page.
+type Checkpoint struct {
+	RunID  string
+	Source string
+	Region string
+	Tile   int
+	// Page is the next page of the tile to fetch.
+	Page      int
+	StartedAt int64
+	// Covered is false once a tile of the run had more pages than the
+	// collector fetches, the run then delists nothing.
+	Covered bool
+}
+
+// ListingIterator reads listings one at a time, Listing is the listing read
+// by the last Next that returned true. Err is the error that stopped Next.
+type ListingIterator interface {
+	Next() bool
+	Listing() *mlspb.Property
+	Err() error
+	Close() error
+}
+
 // DBInterface defines the common interface for all types of storage implemented.
+// A listing is saved in its Status, Open when it has none, and saving a saved
+// listing with another Status changes it. A listing is saved with its Tags, and
+// saving a saved listing with Tags replaces them.
 type DBInterface interface {
-	CreateStorage() error
-	SaveNewListing(p *mlspb.Property) error
-	UpdateListing(p *mlspb.Property) error
-	ReadListing(id string) (string, error)
-	ReadListings() (*mlspb.Listings, error)
+	CreateStorage(ctx context.Context) error
+	// SaveNewListing returns ErrListingExists when the listing is saved.
+	SaveNewListing(ctx context.Context, p *mlspb.Property) error
+	// UpdateListing returns ErrNotFound when the listing is not saved.
+	UpdateListing(ctx context.Context, p *mlspb.Property) error
+	// SaveOrUpdateListing saves a new listing or appends the new prices and
+	// status of the saved one in one step, the outcome is Created, Updated or
+	// Unchanged.
+	SaveOrUpdateListing(ctx context.Context, p *mlspb.Property) (SaveOutcome, error)
+	// SaveListings saves the new listings and appends the new prices of the
+	// saved ones, with the outcome of each listing in order.
+	SaveListings(ctx context.Context, listings []*mlspb.Property) ([]*SaveResult, error)
+	// ReadListing reads a listing with its photos, price history and status
+	// history, it returns ErrNotFound when the listing is not saved.
+	ReadListing(ctx context.Context, mlsNumber string) (*mlspb.Property, error)
+	// ReadListings reads every listing in MLS number order, whatever its
+	// status.
+	ReadListings(ctx context.Context) (*mlspb.Listings, error)
+	// IterateListings reads every listing one at a time in MLS number order.
+	IterateListings(ctx context.Context) (ListingIterator, error)
+	// QueryListings reads a page of the listings matching the query, the
+	// next page is read with the NextCursor of the page.
+	QueryListings(ctx context.Context, q ListingQuery) (*ListingPage, error)
+	SavePhotoHash(ctx context.Context, photoURL string, hash uint64) error
+	FindDuplicatePhotos(ctx context.Context) ([]*PhotoDuplicate, error)
+	SaveListingTags(ctx context.Context, mlsNumber string, tags []string) error
+	ReadListingsByTags(ctx context.Context, tags []string) (*mlspb.Listings, error)
+	SaveCheckpoint(ctx context.Context, c *Checkpoint) error
+	// ReadCheckpoint returns nil when the source has no run in progress.
+	ReadCheckpoint(ctx context.Context, source string) (*Checkpoint, error)
+	DeleteCheckpoint(ctx context.Context, source string) error
+	MarkListingSeen(ctx context.Context, mlsNumber string, timestamp int64) error
+	// DelistUnseen delists the open listings of source not seen since the
+	// timestamp and returns how many were delisted.
+	DelistUnseen(ctx context.Context, source string, since int64) (int64, error)
+	// ReadLatestPrice returns the price of the latest timestamp saved for a
+	// listing, found is false when the listing is not saved.
+	ReadLatestPrice(ctx context.Context, mlsNumber string) (price int32, found bool, err error)
+	// SaveCollectionRun adds or replaces the record of a collector run.
+	SaveCollectionRun(ctx context.Context, r *mlspb.CollectionRun) error
+	// ReadCollectionRuns reads the limit most recent collector runs.
+	ReadCollectionRuns(ctx context.Context, limit int) (*mlspb.CollectionRuns, error)
+}
+
+// listedTimestamp is when a new listing opened, the time it is saved when the
+// collector did not find its list date.
+func listedTimestamp(p *mlspb.Property) int64 {
+	if p.ListTimestamp > 0 {
+		return p.ListTimestamp
+	}
+	return time.Now().Unix()
+}
+
+// savedStatus is the status a listing is saved in, Open when the collector did
+// not find one.
+func savedStatus(p *mlspb.Property) (string, error) {
+	if p.Status == "" {
+		return listingStatusName[Open], nil
+	}
+	for _, status := range listingStatusName {
+		if status == p.Status {
+			return status, nil
+		}
+	}
+	return "", fmt.Errorf("%w: unknown status %q of listing %s", ErrInvalidListing, p.Status, p.MlsNumber)
 }
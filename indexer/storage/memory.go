@@ -1,10 +1,14 @@
 package storage
 
 import (
+	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/sirupsen/logrus"
 	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
 )
@@ -15,12 +19,14 @@ const (
 	Open listingStatus = iota
 	Pending
 	Sold
+	Delisted
 )
 
 var listingStatusName = map[listingStatus]string{
-	Open:    "Open",
-	Pending: "Pending",
-	Sold:    "Sold",
+	Open:     "Open",
+	Pending:  "Pending",
+	Sold:     "Sold",
+	Delisted: "Delisted",
 }
 
 type City struct {
@@ -57,19 +63,36 @@ type photo struct {
 	photoURL []string
 }
 
+type localizedText struct {
+	publicRemark string
+	propertyType string
+}
+
 type priceHistory struct {
 	price     int32
 	timestamp int64
 }
 
+type statusHistory struct {
+	status    string
+	timestamp int64
+}
+
 // MemoryDB creates the in-memory data structure to hold the collected data.
 type MemoryDB struct {
-	Lock         sync.Mutex
-	Mls          map[string]*mls
-	Property     map[string]*property
-	Photo        map[string]*photo
-	PriceHistory map[string][]*priceHistory
-	CityIndex    map[string]*City
+	Lock          sync.Mutex
+	Mls           map[string]*mls
+	Property      map[string]*property
+	Photo         map[string]*photo
+	PriceHistory  map[string][]*priceHistory
+	StatusHistory map[string][]*statusHistory
+	LocalizedText map[string]map[string]*localizedText
+	PhotoHash     map[string]uint64
+	Tags          map[string][]string
+	Checkpoints   map[string]*Checkpoint
+	LastSeen      map[string]int64
+	Runs          map[string]*mlspb.CollectionRun
+	CityIndex     map[string]*City
 }
 
 // NewMemoryDB creates an instance of all the in-memory data structure used to
@@ -84,48 +107,176 @@ type MemoryDB struct {
 // }
 func NewMemoryDB(cityIndex map[string]*City) (*MemoryDB, error) {
 	m := &MemoryDB{
-		Mls:          make(map[string]*mls),
-		Property:     make(map[string]*property),
-		Photo:        make(map[string]*photo),
-		PriceHistory: make(map[string][]*priceHistory),
-		CityIndex:    cityIndex,
+		Mls:           make(map[string]*mls),
+		Property:      make(map[string]*property),
+		Photo:         make(map[string]*photo),
+		PriceHistory:  make(map[string][]*priceHistory),
+		StatusHistory: make(map[string][]*statusHistory),
+		LocalizedText: make(map[string]map[string]*localizedText),
+		PhotoHash:     make(map[string]uint64),
+		Tags:          make(map[string][]string),
+		Checkpoints:   make(map[string]*Checkpoint),
+		LastSeen:      make(map[string]int64),
+		Runs:          make(map[string]*mlspb.CollectionRun),
+		CityIndex:     cityIndex,
 	}
 	return m, nil
 }
 
 // CreateStorage for in-memory DB is a placeholder to comply with the DBInterface.
-func (m *MemoryDB) CreateStorage() error {
+func (m *MemoryDB) CreateStorage(ctx context.Context) error {
 	return nil
 }
 
 // UpdateListing appends new pricing information for an existing listing record.
-func (m *MemoryDB) UpdateListing(p *mlspb.Property) error {
+func (m *MemoryDB) UpdateListing(ctx context.Context, p *mlspb.Property) error {
 	m.Lock.Lock()
 	defer m.Lock.Unlock()
 
 	logrus.Debugf("update listing: mlsNumber = %s listing %v\n", p.MlsNumber, p)
 	if _, ok := m.PriceHistory[p.MlsNumber]; !ok {
-		return fmt.Errorf("listing %s does not exist", p.MlsNumber)
+		return fmt.Errorf("%w: %s", ErrNotFound, p.MlsNumber)
+	}
+	if _, err := savedStatus(p); err != nil {
+		return err
 	}
 
-	for _, pr := range p.Price {
-		price := &priceHistory{
+	m.appendPrices(p.MlsNumber, p.Price)
+	m.saveLocalizedText(p)
+	m.saveTags(p)
+	m.updateStatus(p)
+	return nil
+}
+
+// updateStatus changes a saved listing to the status collected, if any, the
+// caller must hold the lock. changed is false when the listing has it.
+func (m *MemoryDB) updateStatus(p *mlspb.Property) (changed bool) {
+	if p.Status == "" || p.Status == m.Mls[p.MlsNumber].status {
+		return false
+	}
+	m.Mls[p.MlsNumber].status = p.Status
+	m.saveStatus(p.MlsNumber, p.Status, time.Now().Unix())
+	return true
+}
+
+// appendPrices adds to the price history of a listing, kept in timestamp
+// order with the later saved price last on a tie. The caller must hold the
+// lock.
+func (m *MemoryDB) appendPrices(mlsNumber string, prices []*mlspb.PriceHistory) {
+	for _, pr := range prices {
+		history := m.PriceHistory[mlsNumber]
+		i := sort.Search(len(history), func(i int) bool { return history[i].timestamp > pr.Timestamp })
+		history = append(history, nil)
+		copy(history[i+1:], history[i:])
+		history[i] = &priceHistory{
 			price:     pr.Price,
 			timestamp: pr.Timestamp,
 		}
-		m.PriceHistory[p.MlsNumber] = append(m.PriceHistory[p.MlsNumber], price)
+		m.PriceHistory[mlsNumber] = history
+	}
+}
+
+// SaveListings saves the new listings and appends the new prices of the saved
+// ones. A cancelled context saves none of them, the batch is otherwise saved
+// as a whole.
+func (m *MemoryDB) SaveListings(ctx context.Context, listings []*mlspb.Property) ([]*SaveResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	results := make([]*SaveResult, len(listings))
+	for i, p := range listings {
+		r := &SaveResult{MlsNumber: p.MlsNumber}
+		r.Outcome, r.Err = m.saveOrUpdate(p)
+		results[i] = r
+	}
+	return results, nil
+}
+
+// SaveOrUpdateListing saves a new listing or appends the new prices of the
+// saved one.
+func (m *MemoryDB) SaveOrUpdateListing(ctx context.Context, p *mlspb.Property) (SaveOutcome, error) {
+	if err := ctx.Err(); err != nil {
+		return Failed, err
+	}
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	return m.saveOrUpdate(p)
+}
+
+// saveOrUpdate saves a new listing or appends the new prices of the saved one,
+// the caller must hold the lock.
+func (m *MemoryDB) saveOrUpdate(p *mlspb.Property) (SaveOutcome, error) {
+	if _, ok := m.Mls[p.MlsNumber]; !ok {
+		if err := m.saveNewListing(p); err != nil {
+			return Failed, err
+		}
+		return Created, nil
+	}
+	if _, err := savedStatus(p); err != nil {
+		return Failed, err
+	}
+
+	var prices []*mlspb.PriceHistory
+	if history := m.PriceHistory[p.MlsNumber]; len(history) == 0 {
+		prices = newPrices(0, false, p.Price)
+	} else {
+		prices = newPrices(history[len(history)-1].price, true, p.Price)
+	}
+	m.appendPrices(p.MlsNumber, prices)
+	m.saveLocalizedText(p)
+	m.saveTags(p)
+	if changed := m.updateStatus(p); len(prices) == 0 && !changed {
+		return Unchanged, nil
+	}
+	return Updated, nil
+}
+
+// saveLocalizedText adds or replaces the text of the listing in each language,
+// the caller must hold the lock.
+func (m *MemoryDB) saveLocalizedText(p *mlspb.Property) {
+	if _, ok := m.LocalizedText[p.MlsNumber]; !ok {
+		m.LocalizedText[p.MlsNumber] = make(map[string]*localizedText)
+	}
+	for lang, text := range p.LocalizedText {
+		m.LocalizedText[p.MlsNumber][lang] = &localizedText{
+			publicRemark: text.PublicRemarks,
+			propertyType: text.PropertyType,
+		}
+	}
+}
+
+// saveTags replaces the tags of a listing collected with tags, the caller must
+// hold the lock.
+func (m *MemoryDB) saveTags(p *mlspb.Property) {
+	if len(p.Tags) > 0 {
+		m.Tags[p.MlsNumber] = uniqueTags(p.Tags)
 	}
-	return nil
 }
 
 // SaveNewListing saves the data collected into the in-memory data structure.
-func (m *MemoryDB) SaveNewListing(p *mlspb.Property) error {
+func (m *MemoryDB) SaveNewListing(ctx context.Context, p *mlspb.Property) error {
 	m.Lock.Lock()
 	defer m.Lock.Unlock()
 
+	return m.saveNewListing(p)
+}
+
+// saveNewListing saves a new listing, the caller must hold the lock.
+func (m *MemoryDB) saveNewListing(p *mlspb.Property) error {
 	logrus.Debugf("Save Listing: mlsNumber = %s listing %v\n", p.MlsNumber, p)
+	if p.MlsNumber == "" {
+		return fmt.Errorf("%w: missing MLS number", ErrInvalidListing)
+	}
 	if _, ok := m.Mls[p.MlsNumber]; ok {
-		return fmt.Errorf("listing %s exists", p.MlsNumber)
+		return fmt.Errorf("%w: %s", ErrListingExists, p.MlsNumber)
+	}
+	status, err := savedStatus(p)
+	if err != nil {
+		return err
 	}
 	cityKey := fmt.Sprintf("%s,%s", strings.ToLower(p.City), strings.ToLower(p.State))
 	// logrus.Infof("### city key = %s", cityKey)
@@ -151,7 +302,7 @@ func (m *MemoryDB) SaveNewListing(p *mlspb.Property) error {
 		stories:            p.Stories,
 		propertyType:       p.PropertyType,
 		availableTimestamp: p.ListTimestamp,
-		status:             listingStatusName[Open],
+		status:             status,
 		source:             p.Source,
 	}
 	m.Property[p.MlsNumber] = &property{
@@ -164,59 +315,428 @@ func (m *MemoryDB) SaveNewListing(p *mlspb.Property) error {
 	}
 	m.Photo[p.MlsNumber] = &photo{photoURL: p.PhotoUrl}
 	m.PriceHistory[p.MlsNumber] = []*priceHistory{}
-	for _, pr := range p.Price {
-		price := &priceHistory{
-			price:     pr.Price,
-			timestamp: pr.Timestamp,
+	m.appendPrices(p.MlsNumber, p.Price)
+	m.saveLocalizedText(p)
+	m.saveTags(p)
+	m.saveStatus(p.MlsNumber, status, listedTimestamp(p))
+	return nil
+}
+
+// saveStatus appends a status change to the status history of a listing, the
+// caller must hold the lock.
+func (m *MemoryDB) saveStatus(mlsNumber, status string, timestamp int64) {
+	m.StatusHistory[mlsNumber] = append(m.StatusHistory[mlsNumber], &statusHistory{
+		status:    status,
+		timestamp: timestamp,
+	})
+}
+
+// ReadListing reads a listing by MLS number from the in-memory data structure.
+func (m *MemoryDB) ReadListing(ctx context.Context, mlsNumber string) (*mlspb.Property, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	if _, ok := m.Mls[mlsNumber]; !ok {
+		return nil, ErrNotFound
+	}
+	return m.readListing(mlsNumber), nil
+}
+
+// ReadListings reads all MLS listings collected from the in-memory data
+// structure in MLS number order, whatever their status.
+func (m *MemoryDB) ReadListings(ctx context.Context) (*mlspb.Listings, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	mlsNumbers := []string{}
+	for mlsNumber := range m.Mls {
+		mlsNumbers = append(mlsNumbers, mlsNumber)
+	}
+	sort.Strings(mlsNumbers)
+	listings := &mlspb.Listings{}
+	for _, mlsNumber := range mlsNumbers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		listings.Property = append(listings.Property, m.readListing(mlsNumber))
+	}
+	return listings, nil
+}
+
+// memoryIterator reads the listings saved when it started, the listings are
+// built one at a time.
+type memoryIterator struct {
+	ctx        context.Context
+	m          *MemoryDB
+	mlsNumbers []string
+	current    *mlspb.Property
+	err        error
+}
+
+// IterateListings reads every listing one at a time in MLS number order.
+func (m *MemoryDB) IterateListings(ctx context.Context) (ListingIterator, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	it := &memoryIterator{ctx: ctx, m: m}
+	for mlsNumber := range m.Mls {
+		it.mlsNumbers = append(it.mlsNumbers, mlsNumber)
+	}
+	sort.Strings(it.mlsNumbers)
+	return it, nil
+}
+
+func (it *memoryIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if it.err = it.ctx.Err(); it.err != nil {
+		return false
+	}
+	it.m.Lock.Lock()
+	defer it.m.Lock.Unlock()
+	for len(it.mlsNumbers) > 0 {
+		mlsNumber := it.mlsNumbers[0]
+		it.mlsNumbers = it.mlsNumbers[1:]
+		if _, ok := it.m.Mls[mlsNumber]; ok {
+			it.current = it.m.readListing(mlsNumber)
+			return true
 		}
-		m.PriceHistory[p.MlsNumber] = append(m.PriceHistory[p.MlsNumber], price)
 	}
+	return false
+}
+
+func (it *memoryIterator) Listing() *mlspb.Property {
+	return it.current
+}
+
+func (it *memoryIterator) Err() error {
+	return it.err
+}
+
+func (it *memoryIterator) Close() error {
+	it.mlsNumbers = nil
 	return nil
 }
 
-// ReadListing reads a listing by listing ID from the in-memory data structure.
-func (m *MemoryDB) ReadListing(id string) (string, error) {
-	return "", nil
+// QueryListings reads a page of the listings matching the query.
+func (m *MemoryDB) QueryListings(ctx context.Context, q ListingQuery) (*ListingPage, error) {
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	c, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	type found struct {
+		listing *mlspb.Property
+		value   int64
+	}
+	results := []found{}
+	for mlsNumber := range m.Mls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		p := m.readListing(mlsNumber)
+		v := priceValues(p.Price)
+		if !q.matches(p, v) || !q.after(c, q.sortValue(v), mlsNumber) {
+			continue
+		}
+		results = append(results, found{listing: p, value: q.sortValue(v)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.value != b.value {
+			return (a.value < b.value) != q.Descending
+		}
+		return (a.listing.MlsNumber < b.listing.MlsNumber) != q.Descending
+	})
+
+	page := &ListingPage{Listings: &mlspb.Listings{}}
+	for i, r := range results {
+		if i == q.limit() {
+			last := results[i-1]
+			page.NextCursor = encodeCursor(cursor{Value: last.value, MlsNumber: last.listing.MlsNumber})
+			break
+		}
+		page.Listings.Property = append(page.Listings.Property, r.listing)
+	}
+	return page, nil
 }
 
-// ReadListings reads all MLS listings collected from the in-memory data structure.
-func (m *MemoryDB) ReadListings() (*mlspb.Listings, error) {
+// readListing builds the listing from the in-memory data structure, the
+// caller must hold the lock.
+func (m *MemoryDB) readListing(mlsNumber string) *mlspb.Property {
+	mls := m.Mls[mlsNumber]
+	price := []*mlspb.PriceHistory{}
+	for _, p := range m.PriceHistory[mlsNumber] {
+		price = append(price, &mlspb.PriceHistory{
+			Price:     p.price,
+			Timestamp: p.timestamp,
+		})
+	}
+	var statuses []*mlspb.StatusHistory
+	for _, s := range m.StatusHistory[mlsNumber] {
+		statuses = append(statuses, &mlspb.StatusHistory{
+			Status:    s.status,
+			Timestamp: s.timestamp,
+		})
+	}
+	var localized map[string]*mlspb.LocalizedText
+	for lang, text := range m.LocalizedText[mlsNumber] {
+		if localized == nil {
+			localized = make(map[string]*mlspb.LocalizedText)
+		}
+		localized[lang] = &mlspb.LocalizedText{
+			PublicRemarks: text.publicRemark,
+			PropertyType:  text.propertyType,
+		}
+	}
+	return &mlspb.Property{
+		Address:       m.Property[mlsNumber].address,
+		Bathrooms:     mls.bathrooms,
+		Bedrooms:      mls.bedrooms,
+		LandSize:      mls.landSize,
+		MlsId:         mls.mlsID,
+		MlsNumber:     mlsNumber,
+		MlsUrl:        mls.mlsURL,
+		Parking:       mls.parking,
+		PhotoUrl:      m.Photo[mlsNumber].photoURL,
+		Price:         price,
+		PublicRemarks: mls.publicRemark,
+		Stories:       mls.stories,
+		PropertyType:  mls.propertyType,
+		ListTimestamp: mls.availableTimestamp,
+		Source:        mls.source,
+		Latitude:      m.Property[mlsNumber].latitude,
+		Longitude:     m.Property[mlsNumber].longitude,
+		City:          m.Property[mlsNumber].city,
+		State:         m.Property[mlsNumber].state,
+		Zipcode:       m.Property[mlsNumber].zipcode,
+		Status:        mls.status,
+		Tags:          m.Tags[mlsNumber],
+		LocalizedText: localized,
+		StatusHistory: statuses,
+	}
+}
+
+// SavePhotoHash records the perceptual hash of a saved listing photo.
+func (m *MemoryDB) SavePhotoHash(ctx context.Context, photoURL string, hash uint64) error {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	for _, ph := range m.Photo {
+		for _, url := range ph.photoURL {
+			if url == photoURL {
+				m.PhotoHash[photoURL] = hash
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("photo %s does not exist", photoURL)
+}
+
+// FindDuplicatePhotos finds the listings that share the same pictures.
+func (m *MemoryDB) FindDuplicatePhotos(ctx context.Context) ([]*PhotoDuplicate, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	listingsByHash := make(map[uint64]map[string]bool)
+	for mlsNumber, ph := range m.Photo {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, url := range ph.photoURL {
+			hash, ok := m.PhotoHash[url]
+			if !ok {
+				continue
+			}
+			if _, ok := listingsByHash[hash]; !ok {
+				listingsByHash[hash] = make(map[string]bool)
+			}
+			listingsByHash[hash][mlsNumber] = true
+		}
+	}
+	return duplicatePhotos(listingsByHash), nil
+}
+
+// SaveListingTags replaces the feature tags of an existing listing.
+func (m *MemoryDB) SaveListingTags(ctx context.Context, mlsNumber string, tags []string) error {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	if _, ok := m.Mls[mlsNumber]; !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, mlsNumber)
+	}
+	m.Tags[mlsNumber] = uniqueTags(tags)
+	return nil
+}
+
+// ReadListingsByTags reads the listings tagged with every one of the tags.
+func (m *MemoryDB) ReadListingsByTags(ctx context.Context, tags []string) (*mlspb.Listings, error) {
 	m.Lock.Lock()
 	defer m.Lock.Unlock()
 
 	listings := &mlspb.Listings{}
-	for mlsNumber, mls := range m.Mls {
-		price := []*mlspb.PriceHistory{}
-		for _, p := range m.PriceHistory[mlsNumber] {
-			price = append(price, &mlspb.PriceHistory{
-				Price:     p.price,
-				Timestamp: p.timestamp,
-			})
-		}
-		p := &mlspb.Property{
-			Address:       m.Property[mlsNumber].address,
-			Bathrooms:     mls.bathrooms,
-			Bedrooms:      mls.bedrooms,
-			LandSize:      mls.landSize,
-			MlsId:         mls.mlsID,
-			MlsNumber:     mlsNumber,
-			MlsUrl:        mls.mlsURL,
-			Parking:       mls.parking,
-			PhotoUrl:      m.Photo[mlsNumber].photoURL,
-			Price:         price,
-			PublicRemarks: mls.publicRemark,
-			Stories:       mls.stories,
-			PropertyType:  mls.propertyType,
-			ListTimestamp: mls.availableTimestamp,
-			Source:        mls.source,
-			Latitude:      m.Property[mlsNumber].latitude,
-			Longitude:     m.Property[mlsNumber].longitude,
-			City:          m.Property[mlsNumber].city,
-			State:         m.Property[mlsNumber].state,
-			Zipcode:       m.Property[mlsNumber].zipcode,
-			Status:        mls.status,
-		}
-		listings.Property = append(listings.Property, p)
+	for mlsNumber := range m.Mls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if hasTags(m.Tags[mlsNumber], tags) {
+			listings.Property = append(listings.Property, m.readListing(mlsNumber))
+		}
 	}
 	return listings, nil
 }
+
+// uniqueTags returns the sorted tags without duplicates.
+func uniqueTags(tags []string) []string {
+	unique := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+func hasTags(listingTags, tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range listingTags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// SaveCheckpoint saves the progress of the current run of a source.
+func (m *MemoryDB) SaveCheckpoint(ctx context.Context, c *Checkpoint) error {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	checkpoint := *c
+	m.Checkpoints[c.Source] = &checkpoint
+	return nil
+}
+
+// ReadCheckpoint reads the progress of the current run of a source.
+func (m *MemoryDB) ReadCheckpoint(ctx context.Context, source string) (*Checkpoint, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	c, ok := m.Checkpoints[source]
+	if !ok {
+		return nil, nil
+	}
+	checkpoint := *c
+	return &checkpoint, nil
+}
+
+// DeleteCheckpoint deletes the progress of a source once its run completes.
+func (m *MemoryDB) DeleteCheckpoint(ctx context.Context, source string) error {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	delete(m.Checkpoints, source)
+	return nil
+}
+
+// MarkListingSeen records when a listing was last seen on its source. A
+// delisted listing seen again is open again.
+func (m *MemoryDB) MarkListingSeen(ctx context.Context, mlsNumber string, timestamp int64) error {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	mls, ok := m.Mls[mlsNumber]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotFound, mlsNumber)
+	}
+	if mls.status == listingStatusName[Delisted] {
+		mls.status = listingStatusName[Open]
+		m.saveStatus(mlsNumber, mls.status, timestamp)
+	}
+	m.LastSeen[mlsNumber] = timestamp
+	return nil
+}
+
+// DelistUnseen delists the open listings of source not seen since the
+// timestamp.
+func (m *MemoryDB) DelistUnseen(ctx context.Context, source string, since int64) (int64, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	// The unseen listings are found first, so a cancelled scan delists none.
+	unseen := []string{}
+	for mlsNumber, mls := range m.Mls {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if mls.source == source && mls.status == listingStatusName[Open] && m.LastSeen[mlsNumber] < since {
+			unseen = append(unseen, mlsNumber)
+		}
+	}
+	now := time.Now().Unix()
+	for _, mlsNumber := range unseen {
+		m.Mls[mlsNumber].status = listingStatusName[Delisted]
+		m.saveStatus(mlsNumber, listingStatusName[Delisted], now)
+	}
+	return int64(len(unseen)), nil
+}
+
+// ReadLatestPrice returns the price of the latest timestamp saved for a
+// listing.
+func (m *MemoryDB) ReadLatestPrice(ctx context.Context, mlsNumber string) (int32, bool, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	if _, ok := m.Mls[mlsNumber]; !ok {
+		return 0, false, nil
+	}
+	history := m.PriceHistory[mlsNumber]
+	if len(history) == 0 {
+		return 0, true, nil
+	}
+	return history[len(history)-1].price, true, nil
+}
+
+// SaveCollectionRun adds or replaces the record of a collector run.
+func (m *MemoryDB) SaveCollectionRun(ctx context.Context, r *mlspb.CollectionRun) error {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	m.Runs[r.RunId] = proto.Clone(r).(*mlspb.CollectionRun)
+	return nil
+}
+
+// ReadCollectionRuns reads the limit most recent collector runs.
+func (m *MemoryDB) ReadCollectionRuns(ctx context.Context, limit int) (*mlspb.CollectionRuns, error) {
+	m.Lock.Lock()
+	defer m.Lock.Unlock()
+
+	runs := &mlspb.CollectionRuns{}
+	for _, r := range m.Runs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		runs.Run = append(runs.Run, proto.Clone(r).(*mlspb.CollectionRun))
+	}
+	sort.Slice(runs.Run, func(i, j int) bool { return runs.Run[i].StartedAt > runs.Run[j].StartedAt })
+	if limit > 0 && len(runs.Run) > limit {
+		runs.Run = runs.Run[:limit]
+	}
+	return runs, nil
+}
@@ -1,6 +1,10 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -48,12 +52,12 @@ func TestSaveNewListing(t *testing.T) {
 				Zipcode:       "A0B1C2",
 			},
 		}
-		if err := mDB.SaveNewListing(listings[mlsNumber]); err != nil {
+		if err := mDB.SaveNewListing(context.Background(), listings[mlsNumber]); err != nil {
 			t.Errorf("Failed to save the new listing: %v", err)
 		}
 
 		if mDB.Mls[mlsNumber].mlsID != listings[mlsNumber].MlsId {
-			t.Errorf("mlsID incorrectly saved, expected %d, got %s", listings[mlsNumber].MlsId, mDB.Mls[mlsNumber].mlsID)
+			t.Errorf("mlsID incorrectly saved, expected %s, got %s", listings[mlsNumber].MlsId, mDB.Mls[mlsNumber].mlsID)
 		}
 
 		if mDB.Mls[mlsNumber].mlsURL != listings[mlsNumber].MlsUrl {
@@ -121,11 +125,11 @@ func TestSaveNewListing(t *testing.T) {
 				Zipcode:       "A0B1C2",
 			},
 		}
-		if err := mDB.SaveNewListing(listings[mlsNumber]); err != nil {
+		if err := mDB.SaveNewListing(context.Background(), listings[mlsNumber]); err != nil {
 			t.Errorf("Failed to save the new listing: %v", err)
 		}
 
-		if err := mDB.SaveNewListing(listings[mlsNumber]); err == nil {
+		if err := mDB.SaveNewListing(context.Background(), listings[mlsNumber]); err == nil {
 			t.Errorf("Save the same listing should fail: %v", err)
 		}
 	})
@@ -172,16 +176,16 @@ func TestReadListings(t *testing.T) {
 				Zipcode:       "A0B1C2",
 			},
 		}
-		if err := mDB.SaveNewListing(listings[mlsNumber]); err != nil {
+		if err := mDB.SaveNewListing(context.Background(), listings[mlsNumber]); err != nil {
 			t.Errorf("Failed to save the new listing: %v", err)
 		}
-		results, err := mDB.ReadListings()
+		results, err := mDB.ReadListings(context.Background())
 		if err != nil {
 			t.Errorf("Failed to read the saved listing: %v", err)
 		}
 
 		if results.Property[0].MlsId != listings[mlsNumber].MlsId {
-			t.Errorf("mlsID incorrectly saved, expected %d, got %s", listings[mlsNumber].MlsId, results.Property[0].MlsId)
+			t.Errorf("mlsID incorrectly saved, expected %s, got %s", listings[mlsNumber].MlsId, results.Property[0].MlsId)
 		}
 
 		if results.Property[0].MlsUrl != listings[mlsNumber].MlsUrl {
@@ -201,3 +205,625 @@ func TestReadListings(t *testing.T) {
 		}
 	})
 }
+
+func TestFindDuplicatePhotos(t *testing.T) {
+	t.Run("find listings sharing the same photo", func(t *testing.T) {
+		cityIndex := map[string]*City{}
+		mDB, _ := NewMemoryDB(cityIndex)
+
+		listings := []*mlspb.Property{
+			{
+				Address:   "1234 street|city, province A0B1C2",
+				MlsNumber: "19016321",
+				PhotoUrl:  []string{"https://picture/listings/high/1.jpg", "https://picture/listings/high/2.jpg"},
+				City:      "city",
+				State:     "province",
+			},
+			{
+				Address:   "1234 Street|City, Province A0B1C2",
+				MlsNumber: "19016322",
+				PhotoUrl:  []string{"https://picture/listings/high/3.jpg"},
+				City:      "city",
+				State:     "province",
+			},
+			{
+				Address:   "5678 street|city, province A0B1C2",
+				MlsNumber: "19016323",
+				PhotoUrl:  []string{"https://picture/listings/high/4.jpg"},
+				City:      "city",
+				State:     "province",
+			},
+		}
+		for _, l := range listings {
+			if err := mDB.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+
+		hashes := map[string]uint64{
+			"https://picture/listings/high/1.jpg": 0xf0f0,
+			"https://picture/listings/high/2.jpg": 0x0f0f,
+			"https://picture/listings/high/3.jpg": 0xf0f0,
+			"https://picture/listings/high/4.jpg": 0xffff,
+		}
+		for url, hash := range hashes {
+			if err := mDB.SavePhotoHash(context.Background(), url, hash); err != nil {
+				t.Errorf("Failed to save the photo hash: %v", err)
+			}
+		}
+
+		duplicates, err := mDB.FindDuplicatePhotos(context.Background())
+		if err != nil {
+			t.Errorf("Failed to find duplicate photos: %v", err)
+		}
+		if len(duplicates) != 1 {
+			t.Fatalf("expected 1 duplicate photo, got %d", len(duplicates))
+		}
+		if duplicates[0].Hash != 0xf0f0 {
+			t.Errorf("duplicate hash incorrect, expected %x, got %x", 0xf0f0, duplicates[0].Hash)
+		}
+		if len(duplicates[0].MlsNumbers) != 2 || duplicates[0].MlsNumbers[0] != "19016321" || duplicates[0].MlsNumbers[1] != "19016322" {
+			t.Errorf("duplicate listings incorrect, expected [19016321 19016322], got %v", duplicates[0].MlsNumbers)
+		}
+	})
+
+	t.Run("reject hash of an unknown photo", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		if err := mDB.SavePhotoHash(context.Background(), "https://picture/listings/high/unknown.jpg", 1); err == nil {
+			t.Error("Save the hash of an unknown photo should fail")
+		}
+	})
+}
+
+func TestReadListingsByTags(t *testing.T) {
+	t.Run("read listings having all the tags", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+
+		listings := []*mlspb.Property{
+			{Address: "1 street|city, province A0B1C2", MlsNumber: "19016324", City: "city", State: "province"},
+			{Address: "2 street|city, province A0B1C2", MlsNumber: "19016325", City: "city", State: "province"},
+		}
+		for _, l := range listings {
+			if err := mDB.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		if err := mDB.SaveListingTags(context.Background(), "19016324", []string{"pool", "garage"}); err != nil {
+			t.Errorf("Failed to save the listing tags: %v", err)
+		}
+		if err := mDB.SaveListingTags(context.Background(), "19016325", []string{"pool"}); err != nil {
+			t.Errorf("Failed to save the listing tags: %v", err)
+		}
+
+		results, err := mDB.ReadListingsByTags(context.Background(), []string{"garage", "pool"})
+		if err != nil {
+			t.Errorf("Failed to read the listings by tags: %v", err)
+		}
+		if len(results.Property) != 1 || results.Property[0].MlsNumber != "19016324" {
+			t.Fatalf("expected only listing 19016324, got %v", results.Property)
+		}
+		if len(results.Property[0].Tags) != 2 || results.Property[0].Tags[0] != "garage" || results.Property[0].Tags[1] != "pool" {
+			t.Errorf("tags incorrectly saved, expected [garage pool], got %v", results.Property[0].Tags)
+		}
+
+		results, err = mDB.ReadListingsByTags(context.Background(), []string{"pool"})
+		if err != nil {
+			t.Errorf("Failed to read the listings by tags: %v", err)
+		}
+		if len(results.Property) != 2 {
+			t.Errorf("expected 2 listings tagged pool, got %d", len(results.Property))
+		}
+	})
+
+	t.Run("reject tags of an unknown listing", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		if err := mDB.SaveListingTags(context.Background(), "unknown", []string{"pool"}); err == nil {
+			t.Error("Save the tags of an unknown listing should fail")
+		}
+	})
+}
+
+func TestLocalizedText(t *testing.T) {
+	t.Run("save and read the listing text in every language", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+
+		listing := &mlspb.Property{
+			Address:   "1234 street|city, province A0B1C2",
+			MlsNumber: "19016326",
+			City:      "city",
+			State:     "province",
+			LocalizedText: map[string]*mlspb.LocalizedText{
+				"en": {PublicRemarks: "HOUSE DESCRIPTION", PropertyType: "House"},
+			},
+		}
+		if err := mDB.SaveNewListing(context.Background(), listing); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		listing.LocalizedText = map[string]*mlspb.LocalizedText{
+			"fr": {PublicRemarks: "DESCRIPTION DE LA MAISON", PropertyType: "Maison"},
+		}
+		if err := mDB.UpdateListing(context.Background(), listing); err != nil {
+			t.Errorf("Failed to update the listing: %v", err)
+		}
+
+		results, err := mDB.ReadListings(context.Background())
+		if err != nil {
+			t.Errorf("Failed to read the saved listing: %v", err)
+		}
+		localized := results.Property[0].LocalizedText
+		if len(localized) != 2 {
+			t.Fatalf("expected text in 2 languages, got %v", localized)
+		}
+		if localized["en"].PublicRemarks != "HOUSE DESCRIPTION" {
+			t.Errorf("english remarks incorrectly saved, expected %s, got %s", "HOUSE DESCRIPTION", localized["en"].PublicRemarks)
+		}
+		if localized["fr"].PropertyType != "Maison" {
+			t.Errorf("french property type incorrectly saved, expected %s, got %s", "Maison", localized["fr"].PropertyType)
+		}
+	})
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Run("save, read and delete the checkpoint of a source", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+
+		if c, err := mDB.ReadCheckpoint(context.Background(), "mls-canada"); c != nil || err != nil {
+			t.Errorf("expected no checkpoint, got %v, %v", c, err)
+		}
+		want := &Checkpoint{RunID: "1", Source: "mls-canada", Region: "windsor", Tile: 2, Page: 3, StartedAt: 100}
+		if err := mDB.SaveCheckpoint(context.Background(), want); err != nil {
+			t.Errorf("Failed to save the checkpoint: %v", err)
+		}
+		got, err := mDB.ReadCheckpoint(context.Background(), "mls-canada")
+		if err != nil || *got != *want {
+			t.Errorf("got checkpoint %v, want %v", got, want)
+		}
+		if err := mDB.DeleteCheckpoint(context.Background(), "mls-canada"); err != nil {
+			t.Errorf("Failed to delete the checkpoint: %v", err)
+		}
+		if c, _ := mDB.ReadCheckpoint(context.Background(), "mls-canada"); c != nil {
+			t.Errorf("expected the checkpoint to be deleted, got %v", c)
+		}
+	})
+}
+
+func TestDelistUnseen(t *testing.T) {
+	t.Run("delist the open listings of the source not seen since the run started", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		for _, l := range []*mlspb.Property{
+			{MlsNumber: "seen", Source: "mls-canada"},
+			{MlsNumber: "unseen", Source: "mls-canada"},
+			{MlsNumber: "other", Source: "reso"},
+		} {
+			if err := mDB.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		mDB.MarkListingSeen(context.Background(), "seen", 200)
+		mDB.MarkListingSeen(context.Background(), "unseen", 50)
+
+		delisted, err := mDB.DelistUnseen(context.Background(), "mls-canada", 100)
+		if err != nil || delisted != 1 {
+			t.Errorf("expected 1 delisted listing, got %d, %v", delisted, err)
+		}
+		status := map[string]string{}
+		results, _ := mDB.ReadListings(context.Background())
+		for _, p := range results.Property {
+			status[p.MlsNumber] = p.Status
+		}
+		if status["seen"] != "Open" || status["unseen"] != "Delisted" || status["other"] != "Open" {
+			t.Errorf("unexpected status %v", status)
+		}
+
+		mDB.MarkListingSeen(context.Background(), "unseen", 300)
+		results, _ = mDB.ReadListings(context.Background())
+		for _, p := range results.Property {
+			if p.Status != "Open" {
+				t.Errorf("expected listing %s to be open again, got %s", p.MlsNumber, p.Status)
+			}
+		}
+
+		if err := mDB.MarkListingSeen(context.Background(), "missing", 300); err == nil {
+			t.Error("expected an error marking a missing listing seen")
+		}
+	})
+}
+
+func TestReadLatestPrice(t *testing.T) {
+	t.Run("read the last price of a listing", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		listing := &mlspb.Property{
+			MlsNumber: "19016318",
+			Price:     []*mlspb.PriceHistory{{Price: 10000, Timestamp: 1}},
+		}
+		mDB.SaveNewListing(context.Background(), listing)
+		listing.Price = []*mlspb.PriceHistory{{Price: 9000, Timestamp: 2}}
+		mDB.UpdateListing(context.Background(), listing)
+
+		price, found, err := mDB.ReadLatestPrice(context.Background(), "19016318")
+		if err != nil || !found || price != 9000 {
+			t.Errorf("got price %d, found %v, err %v, want 9000", price, found, err)
+		}
+		if _, found, _ := mDB.ReadLatestPrice(context.Background(), "missing"); found {
+			t.Error("expected a missing listing not to be found")
+		}
+	})
+}
+
+func TestCollectionRuns(t *testing.T) {
+	t.Run("read the most recent runs first", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		for i := int64(1); i <= 3; i++ {
+			mDB.SaveCollectionRun(context.Background(), &mlspb.CollectionRun{
+				RunId:     fmt.Sprintf("run-%d", i),
+				Source:    "mls-canada",
+				Region:    []string{"windsor"},
+				StartedAt: i,
+				EndedAt:   i + 1,
+			})
+		}
+		mDB.SaveCollectionRun(context.Background(), &mlspb.CollectionRun{RunId: "run-3", StartedAt: 3, Pages: 5})
+
+		runs, err := mDB.ReadCollectionRuns(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("Failed to read the runs: %v", err)
+		}
+		if len(runs.Run) != 2 || runs.Run[0].RunId != "run-3" || runs.Run[1].RunId != "run-2" {
+			t.Fatalf("unexpected runs %v", runs.Run)
+		}
+		if runs.Run[0].Pages != 5 {
+			t.Errorf("expected the run to be replaced, got %v", runs.Run[0])
+		}
+		if runs, _ := mDB.ReadCollectionRuns(context.Background(), 0); len(runs.Run) != 3 {
+			t.Errorf("expected every run without a limit, got %d", len(runs.Run))
+		}
+	})
+}
+
+func TestReadListing(t *testing.T) {
+	t.Run("read a listing with its price and status history", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		if err := mDB.SaveNewListing(context.Background(), &mlspb.Property{
+			MlsNumber:     "19016318",
+			Source:        "mls-canada",
+			PhotoUrl:      []string{"https://picture/listings/high/456.jpg"},
+			ListTimestamp: 100,
+			Price:         []*mlspb.PriceHistory{{Price: 10000, Timestamp: 100}},
+		}); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		mDB.UpdateListing(context.Background(), &mlspb.Property{MlsNumber: "19016318", Price: []*mlspb.PriceHistory{{Price: 9000, Timestamp: 200}}})
+		mDB.DelistUnseen(context.Background(), "mls-canada", 300)
+		mDB.MarkListingSeen(context.Background(), "19016318", 400)
+
+		p, err := mDB.ReadListing(context.Background(), "19016318")
+		if err != nil {
+			t.Fatalf("Failed to read the listing: %v", err)
+		}
+		if len(p.PhotoUrl) != 1 || len(p.Price) != 2 || p.Price[1].Price != 9000 {
+			t.Errorf("unexpected listing %v", p)
+		}
+		statuses := []string{}
+		for _, s := range p.StatusHistory {
+			statuses = append(statuses, s.Status)
+		}
+		if strings.Join(statuses, ",") != "Open,Delisted,Open" || p.StatusHistory[0].Timestamp != 100 || p.StatusHistory[2].Timestamp != 400 {
+			t.Errorf("unexpected status history %v", p.StatusHistory)
+		}
+	})
+
+	t.Run("return ErrNotFound for a missing listing", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		if _, err := mDB.ReadListing(context.Background(), "missing"); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+// queryFixture is the listings the query tests of the backends read.
+func queryFixture() []*mlspb.Property {
+	listing := func(mlsNumber, city, zipcode, bedrooms, bathrooms, source string, prices ...int32) *mlspb.Property {
+		p := &mlspb.Property{
+			Address:      mlsNumber + " street|" + city,
+			MlsNumber:    mlsNumber,
+			City:         city,
+			State:        "Ontario",
+			Zipcode:      zipcode,
+			Bedrooms:     bedrooms,
+			Bathrooms:    bathrooms,
+			PropertyType: "House",
+			Source:       source,
+		}
+		for i, price := range prices {
+			p.Price = append(p.Price, &mlspb.PriceHistory{Price: price, Timestamp: int64(len(mlsNumber)*100 + i)})
+		}
+		return p
+	}
+	return []*mlspb.Property{
+		listing("1", "Windsor", "N9A1B2", "3 + 1", "2", "mls-canada", 300000, 280000),
+		listing("22", "Windsor", "N9B3C4", "2 + 0", "1", "mls-canada", 250000),
+		listing("333", "windsor", "N9A5D6", "4", "3.5", "reso", 500000, 510000),
+		listing("4444", "Tecumseh", "N8N7E8", "3", "2", "mls-canada", 400000, 350000),
+		listing("55555", "Windsor", "N9A9F0", "1", "1", "mls-canada", 250000),
+	}
+}
+
+// queryCases are the queries the query tests of the backends run, with the
+// MLS numbers they read in order.
+var queryCases = []struct {
+	name  string
+	query ListingQuery
+	want  string
+}{
+	{"sort by date", ListingQuery{}, "1,22,333,4444,55555"},
+	{"filter by city regardless of case", ListingQuery{City: "WINDSOR", Descending: true}, "55555,333,22,1"},
+	{"filter by postal prefix", ListingQuery{PostalPrefix: "n9a", Sort: SortByPrice}, "55555,1,333"},
+	{"filter by price range and sort ties by MLS number", ListingQuery{MaxPrice: 300000, Sort: SortByPrice}, "22,55555,1"},
+	{"filter by bedrooms above and below grade", ListingQuery{MinBedrooms: 4}, "1,333"},
+	{"filter by bathrooms", ListingQuery{MinBathrooms: 2, MaxBathrooms: 3}, "1,333,4444"},
+	{"filter by source and first seen", ListingQuery{Source: "mls-canada", FirstSeenFrom: 200, FirstSeenTo: 400}, "22,4444"},
+	{"sort by price change", ListingQuery{Sort: SortByPriceChange}, "4444,1,22,55555,333"},
+	{"filter by status", ListingQuery{Status: "Delisted"}, ""},
+}
+
+// checkQueries runs the query cases and reads the listings by page.
+func checkQueries(t *testing.T, db DBInterface) {
+	t.Helper()
+	for _, c := range queryCases {
+		page, err := db.QueryListings(context.Background(), c.query)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		mlsNumbers := []string{}
+		for _, p := range page.Listings.Property {
+			mlsNumbers = append(mlsNumbers, p.MlsNumber)
+		}
+		if got := strings.Join(mlsNumbers, ","); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	q := ListingQuery{Sort: SortByPrice, Descending: true, Limit: 2}
+	mlsNumbers := []string{}
+	for pages := 0; pages == 0 || q.Cursor != ""; pages++ {
+		if pages > 3 {
+			t.Fatal("expected 3 pages")
+		}
+		page, err := db.QueryListings(context.Background(), q)
+		if err != nil {
+			t.Fatalf("Failed to read page %d: %v", pages, err)
+		}
+		for _, p := range page.Listings.Property {
+			mlsNumbers = append(mlsNumbers, p.MlsNumber)
+		}
+		q.Cursor = page.NextCursor
+	}
+	if got := strings.Join(mlsNumbers, ","); got != "333,4444,1,55555,22" {
+		t.Errorf("got pages %q", got)
+	}
+
+	if _, err := db.QueryListings(context.Background(), ListingQuery{Cursor: "not a cursor"}); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
+
+func TestQueryListings(t *testing.T) {
+	t.Run("filter, sort and page the listings", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		for _, p := range queryFixture() {
+			if err := mDB.SaveNewListing(context.Background(), p); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		checkQueries(t, mDB)
+	})
+}
+
+// checkSaveListings saves a batch twice and checks the outcome of each
+// listing.
+func checkSaveListings(t *testing.T, db DBInterface) {
+	t.Helper()
+	listing := func(mlsNumber string, prices ...int32) *mlspb.Property {
+		p := &mlspb.Property{MlsNumber: mlsNumber, Address: mlsNumber + " street|city", City: "Windsor", State: "Ontario"}
+		for i, price := range prices {
+			p.Price = append(p.Price, &mlspb.PriceHistory{Price: price, Timestamp: int64(100 + i)})
+		}
+		return p
+	}
+	outcomes := func(results []*SaveResult) string {
+		o := []string{}
+		for _, r := range results {
+			o = append(o, r.Outcome.String())
+		}
+		return strings.Join(o, ",")
+	}
+
+	results, err := db.SaveListings(context.Background(), []*mlspb.Property{
+		listing("1", 300000), listing("2", 400000), listing(""), listing("1", 290000),
+	})
+	if err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+	if got := outcomes(results); got != "created,created,failed,updated" || results[2].Err == nil {
+		t.Errorf("got outcomes %q", got)
+	}
+
+	results, err = db.SaveListings(context.Background(), []*mlspb.Property{
+		listing("1", 290000), listing("2", 400000, 380000), listing("3"),
+	})
+	if err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+	if got := outcomes(results); got != "unchanged,updated,created" {
+		t.Errorf("got outcomes %q", got)
+	}
+	for mlsNumber, want := range map[string]int32{"1": 290000, "2": 380000} {
+		if price, found, _ := db.ReadLatestPrice(context.Background(), mlsNumber); !found || price != want {
+			t.Errorf("listing %s has price %d, want %d", mlsNumber, price, want)
+		}
+	}
+	if p, err := db.ReadListing(context.Background(), "1"); err != nil || len(p.Price) != 2 {
+		t.Errorf("expected listing 1 to have 2 prices, got %v, %v", p, err)
+	}
+}
+
+func TestSaveListings(t *testing.T) {
+	t.Run("save a batch of new and saved listings", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		checkSaveListings(t, mDB)
+	})
+
+	t.Run("save none of the listings with a cancelled context", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := mDB.SaveListings(ctx, []*mlspb.Property{{MlsNumber: "1"}, {MlsNumber: "2"}}); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the context error, got %v", err)
+		}
+		if listings, _ := mDB.ReadListings(context.Background()); len(listings.Property) != 0 {
+			t.Errorf("expected no saved listing, got %v", listings.Property)
+		}
+	})
+}
+
+// checkSaveOrUpdateListing saves a listing twice and checks the outcomes and
+// the typed errors of db.
+func checkSaveOrUpdateListing(t *testing.T, db DBInterface) {
+	t.Helper()
+	ctx := context.Background()
+	p := &mlspb.Property{
+		MlsNumber: "1",
+		Address:   "1 street|city",
+		City:      "Windsor",
+		State:     "Ontario",
+		Price:     []*mlspb.PriceHistory{{Price: 300000, Timestamp: 100}},
+	}
+
+	for _, want := range []SaveOutcome{Created, Unchanged} {
+		if outcome, err := db.SaveOrUpdateListing(ctx, p); err != nil || outcome != want {
+			t.Errorf("got %s, %v, want %s", outcome, err, want)
+		}
+	}
+	p.Price = []*mlspb.PriceHistory{{Price: 290000, Timestamp: 200}}
+	if outcome, err := db.SaveOrUpdateListing(ctx, p); err != nil || outcome != Updated {
+		t.Errorf("got %s, %v, want %s", outcome, err, Updated)
+	}
+	if price, found, _ := db.ReadLatestPrice(context.Background(), "1"); !found || price != 290000 {
+		t.Errorf("got latest price %d, want 290000", price)
+	}
+
+	if outcome, err := db.SaveOrUpdateListing(ctx, &mlspb.Property{}); outcome != Failed || !errors.Is(err, ErrInvalidListing) {
+		t.Errorf("expected an invalid listing, got %s, %v", outcome, err)
+	}
+	if err := db.SaveNewListing(context.Background(), p); !errors.Is(err, ErrListingExists) {
+		t.Errorf("expected the listing to exist, got %v", err)
+	}
+	if err := db.UpdateListing(context.Background(), &mlspb.Property{MlsNumber: "2"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the listing not to be found, got %v", err)
+	}
+	if err := db.MarkListingSeen(context.Background(), "2", 100); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the listing not to be found, got %v", err)
+	}
+}
+
+func TestSaveOrUpdateListing(t *testing.T) {
+	t.Run("save then update a listing with typed errors", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		checkSaveOrUpdateListing(t, mDB)
+	})
+}
+
+// checkCancelledContext checks db stops the scans of a cancelled context
+// without changing any listing.
+func checkCancelledContext(t *testing.T, db DBInterface) {
+	t.Helper()
+	p := &mlspb.Property{MlsNumber: "1", Address: "1 street|city", Source: "mls-canada"}
+	if err := db.SaveNewListing(context.Background(), p); err != nil {
+		t.Fatalf("Failed to save the listing: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.ReadListings(ctx); err == nil {
+		t.Error("expected ReadListings to fail")
+	}
+	if _, err := db.ReadListingsByTags(ctx, []string{"garage"}); err == nil {
+		t.Error("expected ReadListingsByTags to fail")
+	}
+	if _, err := db.FindDuplicatePhotos(ctx); err == nil {
+		t.Error("expected FindDuplicatePhotos to fail")
+	}
+	if _, err := db.DelistUnseen(ctx, "mls-canada", time.Now().Unix()); err == nil {
+		t.Error("expected DelistUnseen to fail")
+	}
+	if listing, err := db.ReadListing(context.Background(), "1"); err != nil || listing.Status != "Open" {
+		t.Errorf("expected the listing to stay open, got %v, %v", listing, err)
+	}
+}
+
+func TestCancelledContext(t *testing.T) {
+	t.Run("stop the scans of a cancelled context", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		checkCancelledContext(t, mDB)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := mDB.ReadListings(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the context error, got %v", err)
+		}
+	})
+}
+
+// checkIterateListings saves n listings and reads them back one at a time.
+func checkIterateListings(t *testing.T, db DBInterface, n int) {
+	t.Helper()
+	listings := []*mlspb.Property{}
+	for i := 0; i < n; i++ {
+		mlsNumber := fmt.Sprintf("%05d", i)
+		listings = append(listings, &mlspb.Property{
+			MlsNumber: mlsNumber,
+			Address:   mlsNumber + " street|city",
+			PhotoUrl:  []string{"https://photo/" + mlsNumber + ".jpg"},
+			Price:     []*mlspb.PriceHistory{{Price: int32(i), Timestamp: 100}},
+		})
+	}
+	if _, err := db.SaveListings(context.Background(), listings); err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+
+	it, err := db.IterateListings(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to iterate the listings: %v", err)
+	}
+	defer it.Close()
+	read := 0
+	for ; it.Next(); read++ {
+		p := it.Listing()
+		want := fmt.Sprintf("%05d", read)
+		if p.MlsNumber != want || len(p.PhotoUrl) != 1 || p.PhotoUrl[0] != "https://photo/"+want+".jpg" || len(p.Price) != 1 || p.Price[0].Price != int32(read) {
+			t.Fatalf("listing %d: unexpected listing %v", read, p)
+		}
+	}
+	if err := it.Err(); err != nil || read != n {
+		t.Errorf("expected to read %d listings, got %d, %v", n, read, err)
+	}
+}
+
+func TestIterateListings(t *testing.T) {
+	t.Run("read every listing in MLS number order", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		checkIterateListings(t, mDB, 30)
+	})
+
+	t.Run("stop when the context is done", func(t *testing.T) {
+		mDB, _ := NewMemoryDB(map[string]*City{})
+		mDB.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "1"})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		it, _ := mDB.IterateListings(ctx)
+		if it.Next() || it.Err() != context.Canceled {
+			t.Errorf("expected the iteration to stop, got %v", it.Err())
+		}
+	})
+}
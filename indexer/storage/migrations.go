@@ -0,0 +1,344 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Migration is a versioned change of the SQL schema, Down reverts Up.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+	// Applied counts the rows showing the change is already in the schema,
+	// for the DBs created before the migrations. The migration is recorded
+	// without running when it counts any.
+	Applied string
+}
+
+// MigrationState is a migration and when it was applied, AppliedAt is 0 when
+// it is pending.
+type MigrationState struct {
+	Migration
+	AppliedAt int64
+}
+
+// sqliteMigrations are applied in order, a released migration must not
+// change, the schema changes with a new one.
+var sqliteMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create the listing tables",
+		Up: `CREATE TABLE IF NOT EXISTS listingStatus (
+				statusId INTEGER PRIMARY KEY,
+				status TEXT UNIQUE);
+			INSERT OR IGNORE INTO listingStatus (status) VALUES ("Open"), ("Pending"), ("Sold"), ("Delisted"), ("Closed");
+			CREATE TABLE IF NOT EXISTS city (
+				name TEXT NOT NULL,
+				state TEXT NOT NULL,
+				PRIMARY KEY (name, state));
+			CREATE TABLE IF NOT EXISTS property (
+				address TEXT PRIMARY KEY,
+				zipcode TEXT NOT NULL,
+				latitude REAL,
+				longitude REAL,
+				city TEXT,
+				state TEXT,
+				FOREIGN KEY(city, state) REFERENCES city(name, state));
+			CREATE TABLE IF NOT EXISTS mls (
+				mlsNumber TEXT PRIMARY KEY,
+				mlsId TEXT,
+				mlsUrl TEXT,
+				bathrooms TEXT,
+				bedrooms TEXT,
+				landSize TEXT,
+				parking TEXT,
+				publicRemark TEXT,
+				stories TEXT,
+				propertyType TEXT,
+				availableTimestamp INTEGER,
+				statusId INTEGER,
+				source TEXT,
+				address TEXT,
+				FOREIGN KEY(statusId) REFERENCES listingStatus(statusId),
+				FOREIGN KEY(address) REFERENCES property(address));
+			CREATE TABLE IF NOT EXISTS photo (
+				photoUrl TEXT PRIMARY KEY,
+				mlsNumber TEXT,
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber));
+			CREATE TABLE IF NOT EXISTS priceHistory (
+				mlsNumber TEXT,
+				price INTEGER,
+				priceTimestamp INTEGER,
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`,
+		Down: `DROP TABLE priceHistory;
+			DROP TABLE photo;
+			DROP TABLE mls;
+			DROP TABLE property;
+			DROP TABLE city;
+			DROP TABLE listingStatus`,
+	},
+	{
+		Version: 2,
+		Name:    "add the photo hash",
+		Up:      `ALTER TABLE photo ADD COLUMN hash INTEGER`,
+		// sqlite drops a column by copying the table.
+		Down: `CREATE TABLE photo_without_hash (
+				photoUrl TEXT PRIMARY KEY,
+				mlsNumber TEXT,
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber));
+			INSERT INTO photo_without_hash (photoUrl, mlsNumber) SELECT photoUrl, mlsNumber FROM photo;
+			DROP TABLE photo;
+			ALTER TABLE photo_without_hash RENAME TO photo`,
+		Applied: `SELECT COUNT(*) FROM pragma_table_info('photo') WHERE name = 'hash'`,
+	},
+	{
+		Version: 3,
+		Name:    "create the listing tag table",
+		Up: `CREATE TABLE IF NOT EXISTS listingTag (
+				mlsNumber TEXT,
+				tag TEXT,
+				PRIMARY KEY (mlsNumber, tag),
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`,
+		Down: `DROP TABLE listingTag`,
+	},
+	{
+		Version: 4,
+		Name:    "create the localized text table",
+		Up: `CREATE TABLE IF NOT EXISTS localizedText (
+				mlsNumber TEXT,
+				language TEXT,
+				publicRemark TEXT,
+				propertyType TEXT,
+				PRIMARY KEY (mlsNumber, language),
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`,
+		Down: `DROP TABLE localizedText`,
+	},
+	{
+		Version: 5,
+		Name:    "create the checkpoint and listing seen tables",
+		Up: `CREATE TABLE IF NOT EXISTS checkpoint (
+				source TEXT PRIMARY KEY,
+				runId TEXT,
+				region TEXT,
+				tile INTEGER,
+				page INTEGER,
+				startedAt INTEGER);
+			CREATE TABLE IF NOT EXISTS listingSeen (
+				mlsNumber TEXT PRIMARY KEY,
+				lastSeen INTEGER,
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`,
+		Down: `DROP TABLE listingSeen;
+			DROP TABLE checkpoint`,
+	},
+	{
+		Version: 6,
+		Name:    "create the collection runs table",
+		Up: `CREATE TABLE IF NOT EXISTS collection_runs (
+				runId TEXT PRIMARY KEY,
+				source TEXT,
+				region TEXT,
+				startedAt INTEGER,
+				endedAt INTEGER,
+				pages INTEGER,
+				httpErrors INTEGER,
+				newListings INTEGER,
+				updatedListings INTEGER,
+				delistedListings INTEGER,
+				error TEXT)`,
+		Down: `DROP TABLE collection_runs`,
+	},
+	{
+		Version: 7,
+		Name:    "create the status history table",
+		Up: `CREATE TABLE IF NOT EXISTS statusHistory (
+				mlsNumber TEXT,
+				status TEXT,
+				statusTimestamp INTEGER,
+				FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`,
+		Down: `DROP TABLE statusHistory`,
+	},
+	{
+		Version: 8,
+		Name:    "add the checkpoint coverage",
+		// The runs checkpointed before are resumed without delisting.
+		Up: `ALTER TABLE checkpoint ADD COLUMN covered INTEGER NOT NULL DEFAULT 0`,
+		Down: `CREATE TABLE checkpoint_without_covered (
+				source TEXT PRIMARY KEY,
+				runId TEXT,
+				region TEXT,
+				tile INTEGER,
+				page INTEGER,
+				startedAt INTEGER);
+			INSERT INTO checkpoint_without_covered (source, runId, region, tile, page, startedAt)
+				SELECT source, runId, region, tile, page, startedAt FROM checkpoint;
+			DROP TABLE checkpoint;
+			ALTER TABLE checkpoint_without_covered RENAME TO checkpoint`,
+	},
+}
+
+func (d *SqliteDB) createSchemaMigrationsTable(ctx context.Context) error {
+	sqlStatement := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		appliedAt INTEGER)`
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := d.db.ExecContext(ctx, sqlStatement); err != nil {
+		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+	}
+	return nil
+}
+
+// MigrationStatus lists the migrations in order with when they were applied.
+func (d *SqliteDB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if err := d.createSchemaMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT version, appliedAt FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %v", err)
+	}
+	defer rows.Close()
+	applied := map[int]int64{}
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read the applied migrations: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %v", err)
+	}
+
+	states := []MigrationState{}
+	for _, m := range sqliteMigrations {
+		states = append(states, MigrationState{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return states, nil
+}
+
+// Migrate applies the pending migrations in order and returns them, a dry run
+// only returns them.
+func (d *SqliteDB) Migrate(ctx context.Context, dryRun bool) ([]Migration, error) {
+	states, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrated := []Migration{}
+	for _, s := range states {
+		if s.AppliedAt > 0 {
+			continue
+		}
+		if !dryRun {
+			if err := d.migrate(ctx, s.Migration); err != nil {
+				return migrated, err
+			}
+		}
+		migrated = append(migrated, s.Migration)
+	}
+	return migrated, nil
+}
+
+func (d *SqliteDB) migrate(ctx context.Context, m Migration) error {
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// The transaction takes the write lock of the DB file, so another process
+	// applying the migration first is seen here.
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	recorded := 0
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, m.Version).Scan(&recorded); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if recorded > 0 {
+		return tx.Rollback()
+	}
+	applied := 0
+	if m.Applied != "" {
+		if err := tx.QueryRowContext(ctx, m.Applied).Scan(&applied); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to check migration %d %q: %v", m.Version, m.Name, err)
+		}
+	}
+	if applied == 0 {
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %d %q: %v", m.Version, m.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, appliedAt) VALUES(?, ?, ?)`, m.Version, m.Name, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to apply migration %d %q: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations, latest first, and
+// returns them, a dry run only returns them.
+func (d *SqliteDB) MigrateDown(ctx context.Context, steps int, dryRun bool) ([]Migration, error) {
+	states, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reverted := []Migration{}
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if states[i].AppliedAt == 0 {
+			continue
+		}
+		m := states[i].Migration
+		if !dryRun {
+			if err := d.revert(ctx, m); err != nil {
+				return reverted, err
+			}
+		}
+		reverted = append(reverted, m)
+	}
+	// The next call creating the storage applies the reverted migrations again.
+	d.createLock.Lock()
+	d.created = false
+	d.createLock.Unlock()
+	return reverted, nil
+}
+
+func (d *SqliteDB) revert(ctx context.Context, m Migration) error {
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revert migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, m.Version); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revert migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to revert migration %d %q: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+)
+
+func TestSqliteMigrate(t *testing.T) {
+	t.Run("apply every migration to a new DB", func(t *testing.T) {
+		var dbPath = "/tmp/realtor13.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		dryRun, err := db.Migrate(context.Background(), true)
+		if err != nil || len(dryRun) != len(sqliteMigrations) {
+			t.Errorf("expected a dry run of %d migrations, got %d, %v", len(sqliteMigrations), len(dryRun), err)
+		}
+		if states, _ := db.MigrationStatus(context.Background()); states[0].AppliedAt != 0 {
+			t.Error("expected the dry run to apply nothing")
+		}
+
+		if err := db.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "1", Address: "1 street|city"}); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		states, err := db.MigrationStatus(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read the migration status: %v", err)
+		}
+		for _, s := range states {
+			if s.AppliedAt == 0 {
+				t.Errorf("expected migration %d to be applied", s.Version)
+			}
+		}
+		if migrated, err := db.Migrate(context.Background(), false); err != nil || len(migrated) != 0 {
+			t.Errorf("expected no pending migration, got %d, %v", len(migrated), err)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+
+	t.Run("migrate the DBs created before the migrations", func(t *testing.T) {
+		for _, photoTable := range []string{
+			`CREATE TABLE photo (photoUrl TEXT PRIMARY KEY, mlsNumber TEXT)`,
+			`CREATE TABLE photo (photoUrl TEXT PRIMARY KEY, mlsNumber TEXT, hash INTEGER)`,
+		} {
+			var dbPath = "/tmp/realtor14.db"
+			db, err := NewSqliteDB(dbPath)
+			if err != nil {
+				t.Error(err)
+			}
+			if _, err := db.db.Exec(photoTable); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.db.Exec(`INSERT INTO photo (photoUrl, mlsNumber) VALUES("https://photo/1.jpg", "1")`); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := db.Migrate(context.Background(), false); err != nil {
+				t.Errorf("Failed to migrate %q: %v", photoTable, err)
+			}
+			if err := db.SavePhotoHash(context.Background(), "https://photo/1.jpg", 42); err != nil {
+				t.Errorf("Failed to save the photo hash after migrating %q: %v", photoTable, err)
+			}
+
+			if err := cleanSqliteDB(dbPath); err != nil {
+				t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+			}
+		}
+	})
+
+	t.Run("skip the migrations another process applied meanwhile", func(t *testing.T) {
+		var dbPath = "/tmp/realtor27.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+		other, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+		states, err := db.MigrationStatus(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to read the migration status: %v", err)
+		}
+		if _, err := other.Migrate(context.Background(), false); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		for _, s := range states {
+			if err := db.migrate(context.Background(), s.Migration); err != nil {
+				t.Errorf("expected migration %d to be skipped, got %v", s.Version, err)
+			}
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+
+	t.Run("revert the last migrations", func(t *testing.T) {
+		var dbPath = "/tmp/realtor15.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+		if _, err := db.Migrate(context.Background(), false); err != nil {
+			t.Fatalf("Failed to migrate: %v", err)
+		}
+		if _, err := db.db.Exec(`INSERT INTO photo (photoUrl, mlsNumber, hash) VALUES("https://photo/1.jpg", "1", 42)`); err != nil {
+			t.Fatal(err)
+		}
+
+		reverted, err := db.MigrateDown(context.Background(), len(sqliteMigrations)-1, false)
+		if err != nil || len(reverted) != len(sqliteMigrations)-1 || reverted[0].Version != len(sqliteMigrations) {
+			t.Fatalf("expected to revert %d migrations latest first, got %v, %v", len(sqliteMigrations)-1, reverted, err)
+		}
+		if _, err := db.db.Exec(`SELECT hash FROM photo`); err == nil {
+			t.Error("expected the photo hash column to be dropped")
+		}
+		var photoURL string
+		if err := db.db.QueryRow(`SELECT photoUrl FROM photo`).Scan(&photoURL); err != nil || photoURL != "https://photo/1.jpg" {
+			t.Errorf("expected to keep the photos, got %q, %v", photoURL, err)
+		}
+
+		if migrated, err := db.Migrate(context.Background(), false); err != nil || len(migrated) != len(sqliteMigrations)-1 {
+			t.Errorf("expected to apply the reverted migrations again, got %d, %v", len(migrated), err)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
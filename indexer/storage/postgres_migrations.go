@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// migrationsLock is the advisory lock the indexers and listing servers migrate
+// a shared DB under, one at a time.
+const migrationsLock = 7310522
+
+// postgresMigrations are the versions of sqliteMigrations on postgres. The MLS
+// numbers and tags sort in byte order as in sqlite, and the id columns keep
+// the insertion order the sqlite rowid keeps.
+var postgresMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create the listing tables",
+		// The city of a property keeps its case, unlike the city table, so
+		// they are not constrained.
+		Up: `CREATE TABLE IF NOT EXISTS listingStatus (
+				statusId SERIAL PRIMARY KEY,
+				status TEXT UNIQUE);
+			INSERT INTO listingStatus (status) VALUES ('Open'), ('Pending'), ('Sold'), ('Delisted'), ('Closed') ON CONFLICT DO NOTHING;
+			CREATE TABLE IF NOT EXISTS city (
+				name TEXT NOT NULL,
+				state TEXT NOT NULL,
+				PRIMARY KEY (name, state));
+			CREATE TABLE IF NOT EXISTS property (
+				address TEXT PRIMARY KEY,
+				zipcode TEXT NOT NULL,
+				latitude DOUBLE PRECISION,
+				longitude DOUBLE PRECISION,
+				city TEXT,
+				state TEXT);
+			CREATE TABLE IF NOT EXISTS mls (
+				mlsNumber TEXT COLLATE "C" PRIMARY KEY,
+				mlsId TEXT,
+				mlsUrl TEXT,
+				bathrooms TEXT,
+				bedrooms TEXT,
+				landSize TEXT,
+				parking TEXT,
+				publicRemark TEXT,
+				stories TEXT,
+				propertyType TEXT,
+				availableTimestamp BIGINT,
+				statusId INTEGER REFERENCES listingStatus(statusId),
+				source TEXT,
+				address TEXT REFERENCES property(address));
+			CREATE TABLE IF NOT EXISTS photo (
+				photoUrl TEXT PRIMARY KEY,
+				id BIGSERIAL,
+				mlsNumber TEXT REFERENCES mls(mlsNumber));
+			CREATE INDEX IF NOT EXISTS photo_mlsNumber ON photo (mlsNumber);
+			CREATE TABLE IF NOT EXISTS priceHistory (
+				id BIGSERIAL PRIMARY KEY,
+				mlsNumber TEXT REFERENCES mls(mlsNumber),
+				price INTEGER,
+				priceTimestamp BIGINT);
+			CREATE INDEX IF NOT EXISTS priceHistory_mlsNumber ON priceHistory (mlsNumber, priceTimestamp)`,
+		Down: `DROP TABLE priceHistory;
+			DROP TABLE photo;
+			DROP TABLE mls;
+			DROP TABLE property;
+			DROP TABLE city;
+			DROP TABLE listingStatus`,
+	},
+	{
+		Version: 2,
+		Name:    "add the photo hash",
+		Up:      `ALTER TABLE photo ADD COLUMN hash BIGINT`,
+		Down:    `ALTER TABLE photo DROP COLUMN hash`,
+	},
+	{
+		Version: 3,
+		Name:    "create the listing tag table",
+		Up: `CREATE TABLE IF NOT EXISTS listingTag (
+				mlsNumber TEXT REFERENCES mls(mlsNumber),
+				tag TEXT COLLATE "C",
+				PRIMARY KEY (mlsNumber, tag))`,
+		Down: `DROP TABLE listingTag`,
+	},
+	{
+		Version: 4,
+		Name:    "create the localized text table",
+		Up: `CREATE TABLE IF NOT EXISTS localizedText (
+				mlsNumber TEXT REFERENCES mls(mlsNumber),
+				language TEXT,
+				publicRemark TEXT,
+				propertyType TEXT,
+				PRIMARY KEY (mlsNumber, language))`,
+		Down: `DROP TABLE localizedText`,
+	},
+	{
+		Version: 5,
+		Name:    "create the checkpoint and listing seen tables",
+		Up: `CREATE TABLE IF NOT EXISTS checkpoint (
+				source TEXT PRIMARY KEY,
+				runId TEXT,
+				region TEXT,
+				tile INTEGER,
+				page INTEGER,
+				startedAt BIGINT);
+			CREATE TABLE IF NOT EXISTS listingSeen (
+				mlsNumber TEXT PRIMARY KEY REFERENCES mls(mlsNumber),
+				lastSeen BIGINT)`,
+		Down: `DROP TABLE listingSeen;
+			DROP TABLE checkpoint`,
+	},
+	{
+		Version: 6,
+		Name:    "create the collection runs table",
+		Up: `CREATE TABLE IF NOT EXISTS collection_runs (
+				runId TEXT PRIMARY KEY,
+				source TEXT,
+				region TEXT,
+				startedAt BIGINT,
+				endedAt BIGINT,
+				pages INTEGER,
+				httpErrors INTEGER,
+				newListings INTEGER,
+				updatedListings INTEGER,
+				delistedListings INTEGER,
+				error TEXT)`,
+		Down: `DROP TABLE collection_runs`,
+	},
+	{
+		Version: 7,
+		Name:    "create the status history table",
+		Up: `CREATE TABLE IF NOT EXISTS statusHistory (
+				id BIGSERIAL PRIMARY KEY,
+				mlsNumber TEXT REFERENCES mls(mlsNumber),
+				status TEXT,
+				statusTimestamp BIGINT);
+			CREATE INDEX IF NOT EXISTS statusHistory_mlsNumber ON statusHistory (mlsNumber)`,
+		Down: `DROP TABLE statusHistory`,
+	},
+	{
+		Version: 8,
+		Name:    "add the checkpoint coverage",
+		Up:      `ALTER TABLE checkpoint ADD COLUMN covered BOOLEAN NOT NULL DEFAULT FALSE`,
+		Down:    `ALTER TABLE checkpoint DROP COLUMN covered`,
+	},
+}
+
+func (d *PostgresDB) createSchemaMigrationsTable(ctx context.Context) error {
+	sqlStatement := `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT,
+		appliedAt BIGINT)`
+	if _, err := d.db.ExecContext(ctx, sqlStatement); err != nil {
+		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+	}
+	return nil
+}
+
+// MigrationStatus lists the migrations in order with when they were applied.
+func (d *PostgresDB) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	if err := d.createSchemaMigrationsTable(ctx); err != nil {
+		return nil, err
+	}
+	rows, err := d.db.QueryContext(ctx, `SELECT version, appliedAt FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %v", err)
+	}
+	defer rows.Close()
+	applied := map[int]int64{}
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read the applied migrations: %v", err)
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the applied migrations: %v", err)
+	}
+
+	states := []MigrationState{}
+	for _, m := range postgresMigrations {
+		states = append(states, MigrationState{Migration: m, AppliedAt: applied[m.Version]})
+	}
+	return states, nil
+}
+
+// Migrate applies the pending migrations in order and returns them, a dry run
+// only returns them.
+func (d *PostgresDB) Migrate(ctx context.Context, dryRun bool) ([]Migration, error) {
+	states, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	migrated := []Migration{}
+	for _, s := range states {
+		if s.AppliedAt > 0 {
+			continue
+		}
+		if !dryRun {
+			if err := d.migrate(ctx, s.Migration); err != nil {
+				return migrated, err
+			}
+		}
+		migrated = append(migrated, s.Migration)
+	}
+	return migrated, nil
+}
+
+// migrate applies the migration unless another process applied it while
+// this one waited for the lock.
+func (d *PostgresDB) migrate(ctx context.Context, m Migration) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	var applied int
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsLock); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to lock the migrations: %v", err)
+	}
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = $1`, m.Version).Scan(&applied); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if applied > 0 {
+		return tx.Rollback()
+	}
+	if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to apply migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, appliedAt) VALUES($1, $2, $3)`, m.Version, m.Name, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to apply migration %d %q: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
+
+// MigrateDown reverts the last steps applied migrations, latest first, and
+// returns them, a dry run only returns them.
+func (d *PostgresDB) MigrateDown(ctx context.Context, steps int, dryRun bool) ([]Migration, error) {
+	states, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reverted := []Migration{}
+	for i := len(states) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if states[i].AppliedAt == 0 {
+			continue
+		}
+		m := states[i].Migration
+		if !dryRun {
+			if err := d.revert(ctx, m); err != nil {
+				return reverted, err
+			}
+		}
+		reverted = append(reverted, m)
+	}
+	// The next call creating the storage applies the reverted migrations again.
+	d.createLock.Lock()
+	d.created = false
+	d.createLock.Unlock()
+	return reverted, nil
+}
+
+func (d *PostgresDB) revert(ctx context.Context, m Migration) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsLock); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to lock the migrations: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revert migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to revert migration %d %q: %v", m.Version, m.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to revert migration %d %q: %v", m.Version, m.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+)
+
+// ListingSort is the order QueryListings returns the listings in, the
+// listings with the same sort value are ordered by MLS number.
+type ListingSort int
+
+const (
+	// SortByDate sorts by the first seen timestamp.
+	SortByDate ListingSort = iota
+	// SortByPrice sorts by the latest price.
+	SortByPrice
+	// SortByPriceChange sorts by the latest price less the first price, ie.
+	// the biggest drops first in ascending order.
+	SortByPriceChange
+)
+
+// DefaultQueryLimit is the page size of a query without a limit.
+const DefaultQueryLimit = 20
+
+// ListingQuery filters, sorts and pages the listings read by QueryListings,
+// the zero value of a filter does not filter.
+type ListingQuery struct {
+	// City, State and PostalPrefix match regardless of case.
+	City         string
+	State        string
+	PostalPrefix string
+	Status       string
+	PropertyType string
+	Source       string
+	// The ranges are inclusive, the price is the latest price of a listing.
+	MinPrice int32
+	MaxPrice int32
+	// Bedrooms count the bedrooms above and below grade, ie. 4 for "3 + 1".
+	MinBedrooms  int
+	MaxBedrooms  int
+	MinBathrooms int
+	MaxBathrooms int
+	// FirstSeen is the timestamp of the first price of a listing.
+	FirstSeenFrom int64
+	FirstSeenTo   int64
+
+	Sort       ListingSort
+	Descending bool
+	// Limit is the page size, DefaultQueryLimit when it is not set.
+	Limit int
+	// Cursor is the NextCursor of the previous page, the first page when empty.
+	Cursor string
+}
+
+// ListingPage is a page of the listings of a query, NextCursor is empty on the
+// last page.
+type ListingPage struct {
+	Listings   *mlspb.Listings
+	NextCursor string
+}
+
+// cursor is the sort value and MLS number of the last listing of a page.
+type cursor struct {
+	Value     int64  `json:"v"`
+	MlsNumber string `json:"m"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %q: %v", s, err)
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("invalid cursor %q: %v", s, err)
+	}
+	return c, nil
+}
+
+func (q ListingQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultQueryLimit
+	}
+	return q.Limit
+}
+
+func (q ListingQuery) validate() error {
+	switch q.Sort {
+	case SortByDate, SortByPrice, SortByPriceChange:
+		return nil
+	}
+	return fmt.Errorf("invalid listing sort %d", q.Sort)
+}
+
+// after reports whether the sort value and MLS number of a listing come after
+// the cursor in the order of the query.
+func (q ListingQuery) after(c *cursor, value int64, mlsNumber string) bool {
+	if c == nil {
+		return true
+	}
+	if q.Descending {
+		return value < c.Value || (value == c.Value && mlsNumber < c.MlsNumber)
+	}
+	return value > c.Value || (value == c.Value && mlsNumber > c.MlsNumber)
+}
+
+// leadingInt reads the integer a text starts with as sqlite casts it, ie. 1
+// for " 1.5" and 0 for "".
+func leadingInt(s string) int {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	n, sign := 0, 1
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		if s[0] == '-' {
+			sign = -1
+		}
+		s = s[1:]
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+	}
+	return sign * n
+}
+
+// countBedrooms adds the bedrooms above and below grade, ie. 4 for "3 + 1".
+func countBedrooms(bedrooms string) int {
+	n := leadingInt(bedrooms)
+	if i := strings.Index(bedrooms, "+"); i >= 0 {
+		n += leadingInt(bedrooms[i+1:])
+	}
+	return n
+}
+
+// listingValues are the values of a listing the queries filter and sort on.
+type listingValues struct {
+	latestPrice int32
+	firstPrice  int32
+	firstSeen   int64
+}
+
+// priceValues finds the first and latest prices by timestamp, the price saved
+// last wins a tie.
+func priceValues(prices []*mlspb.PriceHistory) listingValues {
+	v := listingValues{}
+	var first, latest *mlspb.PriceHistory
+	for _, p := range prices {
+		if first == nil || p.Timestamp < first.Timestamp {
+			first = p
+		}
+		if latest == nil || p.Timestamp >= latest.Timestamp {
+			latest = p
+		}
+	}
+	if first != nil {
+		v.firstPrice, v.firstSeen, v.latestPrice = first.Price, first.Timestamp, latest.Price
+	}
+	return v
+}
+
+func (q ListingQuery) sortValue(v listingValues) int64 {
+	switch q.Sort {
+	case SortByPrice:
+		return int64(v.latestPrice)
+	case SortByPriceChange:
+		return int64(v.latestPrice) - int64(v.firstPrice)
+	}
+	return v.firstSeen
+}
+
+// matches reports whether a listing passes the filters of the query.
+func (q ListingQuery) matches(p *mlspb.Property, v listingValues) bool {
+	if q.City != "" && strings.ToLower(p.City) != strings.ToLower(q.City) {
+		return false
+	}
+	if q.State != "" && strings.ToLower(p.State) != strings.ToLower(q.State) {
+		return false
+	}
+	if q.PostalPrefix != "" && !strings.HasPrefix(strings.ToLower(p.Zipcode), strings.ToLower(q.PostalPrefix)) {
+		return false
+	}
+	if (q.Status != "" && p.Status != q.Status) ||
+		(q.PropertyType != "" && p.PropertyType != q.PropertyType) ||
+		(q.Source != "" && p.Source != q.Source) {
+		return false
+	}
+	if (q.MinPrice > 0 && v.latestPrice < q.MinPrice) || (q.MaxPrice > 0 && v.latestPrice > q.MaxPrice) {
+		return false
+	}
+	bedrooms, bathrooms := countBedrooms(p.Bedrooms), leadingInt(p.Bathrooms)
+	if (q.MinBedrooms > 0 && bedrooms < q.MinBedrooms) || (q.MaxBedrooms > 0 && bedrooms > q.MaxBedrooms) {
+		return false
+	}
+	if (q.MinBathrooms > 0 && bathrooms < q.MinBathrooms) || (q.MaxBathrooms > 0 && bathrooms > q.MaxBathrooms) {
+		return false
+	}
+	if (q.FirstSeenFrom > 0 && v.firstSeen < q.FirstSeenFrom) || (q.FirstSeenTo > 0 && v.firstSeen > q.FirstSeenTo) {
+		return false
+	}
+	return true
+}
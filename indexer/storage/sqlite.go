@@ -1,228 +1,141 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/sirupsen/logrus"
 	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
 )
 
-var dbCreated = false
+// sqliteOptions open every connection in WAL mode, so the reads go on while
+// a write is in progress. A write waits for the lock held by another process
+// instead of failing with "database is locked".
+const sqliteOptions = "_journal_mode=WAL&_busy_timeout=5000&_txlock=immediate"
 
 // DB creates the sqlite DB reference used to store data locally.
 type SqliteDB struct {
 	db *sql.DB
+
+	lock       sync.Mutex
+	statements map[string]*sql.Stmt
+
+	// writer is the single writer slot, the writes of the instance queue for
+	// it while the reads use the pool.
+	writer chan struct{}
+
+	createLock sync.Mutex
+	created    bool
 }
 
 // NewDBStorage creates an instance of the sqlite database used to store
 // the data locally.
 func NewSqliteDB(dbPath string) (*SqliteDB, error) {
-	// dbCreated is reset whenever a new sqlite DB is initiated.
-	dbCreated = false
-	database, err := sql.Open("sqlite3", dbPath)
+	dsn := dbPath + "?" + sqliteOptions
+	if strings.Contains(dbPath, "?") {
+		dsn = dbPath + "&" + sqliteOptions
+	}
+	database, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a new database: %v", err)
 	}
 
-	return &SqliteDB{db: database}, nil
+	return &SqliteDB{
+		db:         database,
+		statements: make(map[string]*sql.Stmt),
+		writer:     make(chan struct{}, 1),
+	}, nil
 }
 
-func (d *SqliteDB) listingStatusExisted() bool {
-	rows, err := d.db.Query(`SELECT * FROM listingStatus`)
-	if err != nil {
-		return false
+// lockWriter waits for the writer slot until ctx is done, the caller writes
+// then calls unlock.
+func (d *SqliteDB) lockWriter(ctx context.Context) (unlock func(), err error) {
+	select {
+	case d.writer <- struct{}{}:
+		return func() { <-d.writer }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
-	next := rows.Next()
-	rows.Close()
-	return next
 }
 
-func (d *SqliteDB) createListingStatusTable() error {
-	if d.listingStatusExisted() {
-		return nil
-	}
-
-	sqlStatement := `CREATE TABLE IF NOT EXISTS listingStatus (
-		statusId INTEGER PRIMARY KEY,
-		status TEXT UNIQUE)`
-	statement, err := d.db.Prepare(sqlStatement)
-	if err != nil {
-		return fmt.Errorf("error prepare the create listingStatus table: %v", err)
-	}
-	if _, err := statement.Exec(); err != nil {
-		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
-	}
-	statement.Close()
+// prepared returns the statement of the query within tx, the query is
+// prepared once and cached.
+func (d *SqliteDB) prepared(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
 
-	sqlStatement = `INSERT INTO listingStatus (status)
-			VALUES(?)`
-	statement, err = d.db.Prepare(sqlStatement)
-	if err != nil {
-		return fmt.Errorf("error prepare the insert statement to listingStatus: %v", err)
-	}
-	if _, err := statement.Exec("Open"); err != nil {
-		return fmt.Errorf("error execute %q with value Open: %v", sqlStatement, err)
-	}
-	if _, err := statement.Exec("Pending"); err != nil {
-		return fmt.Errorf("error execute %q with value Pending: %v", sqlStatement, err)
-	}
-	if _, err := statement.Exec("Sold"); err != nil {
-		return fmt.Errorf("error execute %q with value Sold: %v", sqlStatement, err)
-	}
-	if _, err := statement.Exec("Closed"); err != nil {
-		return fmt.Errorf("error execute %q with value Closed: %v", sqlStatement, err)
+	statement, ok := d.statements[query]
+	if !ok {
+		var err error
+		if statement, err = d.db.PrepareContext(ctx, query); err != nil {
+			return nil, fmt.Errorf("error prepare %q: %v", query, err)
+		}
+		d.statements[query] = statement
 	}
-	statement.Close()
-	return nil
+	return tx.StmtContext(ctx, statement), nil
 }
 
-func (d *SqliteDB) createCityTable() error {
-	sqlStatement := `CREATE TABLE IF NOT EXISTS city (
-		name TEXT NOT NULL,
-		state TEXT NOT NULL,
-		PRIMARY KEY (name, state))`
-	statement, err := d.db.Prepare(sqlStatement)
-	if err != nil {
-		return fmt.Errorf("error prepare the create city table: %v", err)
-	}
-	if _, err := statement.Exec(); err != nil {
-		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
-	}
-	statement.Close()
+// CreateStorage for sqlite DB to apply the pending migrations during module first use.
+func (d *SqliteDB) CreateStorage(ctx context.Context) error {
+	d.createLock.Lock()
+	defer d.createLock.Unlock()
 
-	// statement, err = d.db.Prepare(`INSERT INTO city (name, state)
-	// 		VALUES(?, ?)`)
-	// if err != nil {
-	// 	return fmt.Errorf("error prepare the insert statement to city: %v", err)
-	// }
-	// statement.Exec("Windsor", "Ontario")
-	return nil
-}
-
-func (d *SqliteDB) createPropertyTable() error {
-	sqlStatement := `CREATE TABLE IF NOT EXISTS property (
-		address TEXT PRIMARY KEY,
-		zipcode TEXT NOT NULL,
-		latitude REAL,
-		longitude REAL,
-		city TEXT,
-		state TEXT,
-		FOREIGN KEY(city, state) REFERENCES city(name, state))`
-	statement, err := d.db.Prepare(sqlStatement)
-	if err != nil {
-		return fmt.Errorf("error prepare the create property table: %v", err)
+	if d.created {
+		return nil
 	}
-	if _, err := statement.Exec(); err != nil {
-		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+	migrated, err := d.Migrate(ctx, false)
+	if err != nil {
+		return err
 	}
-	statement.Close()
-	return nil
-}
-
-func (d *SqliteDB) createMlsTable() error {
-	sqlStatement := `CREATE TABLE IF NOT EXISTS mls (
-		mlsNumber TEXT PRIMARY KEY,
-		mlsId TEXT,
-		mlsUrl TEXT,
-		bathrooms TEXT,
-		bedrooms TEXT,
-		landSize TEXT,
-		parking TEXT,
-		publicRemark TEXT,
-		stories TEXT,
-		propertyType TEXT,
-		availableTimestamp INTEGER,
-		statusId INTEGER,
-		source TEXT,
-		address TEXT,
- 		FOREIGN KEY(statusId) REFERENCES listingStatus(statusId),
-		FOREIGN KEY(address) REFERENCES property(address))`
-	statement, err := d.db.Prepare(sqlStatement)
-	if err != nil {
-		return fmt.Errorf("error prepare the create mls table: %v", err)
-	}
-	if _, err := statement.Exec(); err != nil {
-		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+	for _, m := range migrated {
+		logrus.Infof("Applied migration %d %q", m.Version, m.Name)
 	}
-	statement.Close()
+	d.created = true
 	return nil
 }
 
-func (d *SqliteDB) createPhotoTable() error {
-	sqlStatement := `CREATE TABLE IF NOT EXISTS photo (
-		photoUrl TEXT PRIMARY KEY,
-		mlsNumber TEXT,
-		FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`
-	statement, err := d.db.Prepare(sqlStatement)
+// UpdateListing appends new pricing information for an existing listing record.
+func (d *SqliteDB) UpdateListing(ctx context.Context, p *mlspb.Property) error {
+	logrus.Debugf("update listing: mlsNumber = %s listing %v\n", p.MlsNumber, p)
+	unlock, err := d.lockWriter(ctx)
 	if err != nil {
-		return fmt.Errorf("error prepare the create photo table: %v", err)
+		return err
 	}
-	if _, err := statement.Exec(); err != nil {
-		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+	defer unlock()
+	if !d.listingExisted(ctx, p.MlsNumber) {
+		return fmt.Errorf("%w: %s", ErrNotFound, p.MlsNumber)
 	}
-	statement.Close()
-	return nil
-}
 
-func (d *SqliteDB) createPriceHistoryTable() error {
-	sqlStatement := `CREATE TABLE IF NOT EXISTS priceHistory (
-		mlsNumber TEXT,
-		price INTEGER,
-		priceTimestamp INTEGER,
-		FOREIGN KEY(mlsNumber) REFERENCES mls(mlsNumber))`
-	statement, err := d.db.Prepare(sqlStatement)
+	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("error prepare the create priceHistory table: %v", err)
-	}
-	if _, err := statement.Exec(); err != nil {
-		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+		return fmt.Errorf("failed to start transaction: %v", err)
 	}
-	statement.Close()
-	return nil
-}
 
-// CreateStorage for sqlite DB to create all the tables during module first use.
-func (d *SqliteDB) CreateStorage() error {
-	if dbCreated {
-		return nil
-	}
-	if err := d.createListingStatusTable(); err != nil {
-		return err
-	}
-	if err := d.createCityTable(); err != nil {
-		return err
-	}
-	if err := d.createPropertyTable(); err != nil {
-		return err
-	}
-	if err := d.createMlsTable(); err != nil {
-		return err
-	}
-	if err := d.createPhotoTable(); err != nil {
-		return err
-	}
-	if err := d.createPriceHistoryTable(); err != nil {
+	if _, err := d.updateStatus(ctx, tx, p); err != nil {
+		tx.Rollback()
 		return err
 	}
-	dbCreated = true
-	return nil
-}
-
-// UpdateListing appends new pricing information for an existing listing record.
-func (d *SqliteDB) UpdateListing(p *mlspb.Property) error {
-	logrus.Debugf("update listing: mlsNumber = %s listing %v\n", p.MlsNumber, p)
 
-	tx, err := d.db.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to start transaction: %v", err)
+	if err := d.insertPriceHistory(ctx, tx, p); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to insert a price history with err: %v", err)
 	}
 
-	if err := d.insertPriceHistory(tx, p); err != nil {
+	if err := d.insertLocalizedText(ctx, tx, p); err != nil {
 		tx.Rollback()
-		return fmt.Errorf("failed to insert a price history with err: %v", err)
+		return fmt.Errorf("failed to insert the localized text with err: %v", err)
+	}
+
+	if len(p.Tags) > 0 {
+		if err := d.saveTags(ctx, tx, p.MlsNumber, p.Tags); err != nil {
+			tx.Rollback()
+			return err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -231,8 +144,8 @@ func (d *SqliteDB) UpdateListing(p *mlspb.Property) error {
 	return nil
 }
 
-func (d *SqliteDB) listingExisted(mlsNumber string) bool {
-	rows, err := d.db.Query(`SELECT * FROM mls WHERE mlsNumber = $1`, mlsNumber)
+func (d *SqliteDB) listingExisted(ctx context.Context, mlsNumber string) bool {
+	rows, err := d.db.QueryContext(ctx, `SELECT * FROM mls WHERE mlsNumber = $1`, mlsNumber)
 	if err != nil {
 		return false
 	}
@@ -241,8 +154,8 @@ func (d *SqliteDB) listingExisted(mlsNumber string) bool {
 	return next
 }
 
-func (d *SqliteDB) cityExisted(city, state string) bool {
-	rows, err := d.db.Query(`SELECT * FROM city WHERE name = $1 AND state = $2`, city, state)
+func (d *SqliteDB) cityExisted(ctx context.Context, city, state string) bool {
+	rows, err := d.db.QueryContext(ctx, `SELECT * FROM city WHERE name = $1 AND state = $2`, city, state)
 	if err != nil {
 		return false
 	}
@@ -251,143 +164,173 @@ func (d *SqliteDB) cityExisted(city, state string) bool {
 	return next
 }
 
-func (d *SqliteDB) insertCity(tx *sql.Tx, city, state string) error {
+func (d *SqliteDB) insertCity(ctx context.Context, tx *sql.Tx, city, state string) error {
 	sqlStatement := `INSERT INTO city (name, state)
 			VALUES(?, ?)`
-	statement, err := d.db.Prepare(sqlStatement)
+	s, err := d.prepared(ctx, tx, sqlStatement)
 	if err != nil {
-		return fmt.Errorf("error prepare the insert city: %v", err)
+		return err
 	}
-	s := tx.Stmt(statement)
-	if _, err := s.Exec(city, state); err != nil {
+	if _, err := s.ExecContext(ctx, city, state); err != nil {
 		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
 	}
-	statement.Close()
-	s.Close()
 
 	return nil
 }
 
-func (d *SqliteDB) insertProperty(tx *sql.Tx, p *mlspb.Property) error {
-	sqlStatement := `INSERT INTO property (
+// insertProperty saves the property of a listing, a home relisted under a new
+// MLS number keeps the property first saved at its address.
+func (d *SqliteDB) insertProperty(ctx context.Context, tx *sql.Tx, p *mlspb.Property) error {
+	sqlStatement := `INSERT OR IGNORE INTO property (
 			address, zipcode, latitude, longitude, city, state)
 			VALUES(?, ?, ?, ?, ?, ?)`
-	statement, err := d.db.Prepare(sqlStatement)
+	s, err := d.prepared(ctx, tx, sqlStatement)
 	if err != nil {
-		return fmt.Errorf("error prepare the insert property: %v", err)
+		return err
 	}
-	s := tx.Stmt(statement)
-	if _, err := s.Exec(p.Address, p.Zipcode, p.Latitude, p.Longitude, p.City, p.State); err != nil {
+	if _, err := s.ExecContext(ctx, p.Address, p.Zipcode, p.Latitude, p.Longitude, p.City, p.State); err != nil {
 		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
 	}
-	statement.Close()
-	s.Close()
 	return nil
 }
 
-func (d *SqliteDB) insertMls(tx *sql.Tx, p *mlspb.Property) error {
+func (d *SqliteDB) insertMls(ctx context.Context, tx *sql.Tx, p *mlspb.Property, status string) error {
 	sqlStatement := `INSERT INTO mls (
 			mlsNumber, mlsId, mlsUrl, bathrooms, bedrooms, landSize, parking,
 			publicRemark, stories, propertyType, availableTimestamp, statusId, source, address)
 			VALUES(?, ?, ?, ?, ?, ?, ?,
-			?, ?, ?, ?, ?, ?, ?)`
-	statement, err := d.db.Prepare(sqlStatement)
+			?, ?, ?, ?, (SELECT statusId FROM listingStatus WHERE status = ?), ?, ?)`
+	s, err := d.prepared(ctx, tx, sqlStatement)
 	if err != nil {
-		return fmt.Errorf("error prepare the insert mls: %v", err)
+		return err
 	}
-	s := tx.Stmt(statement)
-	if _, err := s.Exec(
+	if _, err := s.ExecContext(ctx,
 		p.MlsNumber, p.MlsId, p.MlsUrl, p.Bathrooms, p.Bedrooms, p.LandSize, strings.Join(p.Parking, ";"),
-		p.PublicRemarks, p.Stories, p.PropertyType, p.ListTimestamp, 1, p.Source, p.Address); err != nil {
+		p.PublicRemarks, p.Stories, p.PropertyType, p.ListTimestamp, status, p.Source, p.Address); err != nil {
 		return fmt.Errorf("error execute %q: %v", sqlStatement, err)
 	}
-	statement.Close()
-	s.Close()
 	return nil
 }
 
-func (d *SqliteDB) insertPhoto(tx *sql.Tx, p *mlspb.Property) error {
+func (d *SqliteDB) insertPhoto(ctx context.Context, tx *sql.Tx, p *mlspb.Property) error {
 	sqlStatement := `INSERT INTO photo (
 			photoUrl, mlsNumber)
 			VALUES(?, ?)`
-	statement, err := d.db.Prepare(sqlStatement)
+	s, err := d.prepared(ctx, tx, sqlStatement)
 	if err != nil {
-		return fmt.Errorf("error prepare the insert photo: %v", err)
+		return err
 	}
-	s := tx.Stmt(statement)
 	for _, ph := range p.PhotoUrl {
-		if _, err := s.Exec(ph, p.MlsNumber); err != nil {
+		if _, err := s.ExecContext(ctx, ph, p.MlsNumber); err != nil {
 			return fmt.Errorf("error execute %q: %v", sqlStatement, err)
 		}
 	}
-	statement.Close()
-	s.Close()
 	return nil
 }
 
-func (d *SqliteDB) insertPriceHistory(tx *sql.Tx, p *mlspb.Property) error {
+func (d *SqliteDB) insertPriceHistory(ctx context.Context, tx *sql.Tx, p *mlspb.Property) error {
 	sqlStatement := `INSERT INTO priceHistory (
 			mlsNumber, price, priceTimestamp)
 			VALUES(?, ?, ?)`
-	statement, err := d.db.Prepare(sqlStatement)
+	s, err := d.prepared(ctx, tx, sqlStatement)
 	if err != nil {
-		return fmt.Errorf("error prepare insert statement to photo: %s", err)
+		return err
 	}
-	s := tx.Stmt(statement)
 	for _, pr := range p.Price {
-		if _, err := s.Exec(p.MlsNumber, pr.Price, pr.Timestamp); err != nil {
+		if _, err := s.ExecContext(ctx, p.MlsNumber, pr.Price, pr.Timestamp); err != nil {
+			return fmt.Errorf("error execute %q: %v", sqlStatement, err)
+		}
+	}
+	return nil
+}
+
+func (d *SqliteDB) insertLocalizedText(ctx context.Context, tx *sql.Tx, p *mlspb.Property) error {
+	sqlStatement := `INSERT OR REPLACE INTO localizedText (
+			mlsNumber, language, publicRemark, propertyType)
+			VALUES(?, ?, ?, ?)`
+	s, err := d.prepared(ctx, tx, sqlStatement)
+	if err != nil {
+		return err
+	}
+	for lang, text := range p.LocalizedText {
+		if _, err := s.ExecContext(ctx, p.MlsNumber, lang, text.PublicRemarks, text.PropertyType); err != nil {
 			return fmt.Errorf("error execute %q: %v", sqlStatement, err)
 		}
 	}
-	statement.Close()
-	s.Close()
 	return nil
 }
 
 // SaveNewListing saves the data collected into the in-memory data structure.
-func (d *SqliteDB) SaveNewListing(p *mlspb.Property) error {
-	if err := d.CreateStorage(); err != nil {
+func (d *SqliteDB) SaveNewListing(ctx context.Context, p *mlspb.Property) error {
+	if err := d.CreateStorage(ctx); err != nil {
 		return fmt.Errorf("failed to create DB: %s", err)
 	}
 
 	logrus.Debugf("save mls: %q", p.MlsNumber)
-	if d.listingExisted(p.MlsNumber) {
-		return fmt.Errorf("listing exists: %s", p.MlsNumber)
+	if p.MlsNumber == "" {
+		return fmt.Errorf("%w: missing MLS number", ErrInvalidListing)
 	}
-	tx, err := d.db.Begin()
+	status, err := savedStatus(p)
+	if err != nil {
+		return err
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if d.listingExisted(ctx, p.MlsNumber) {
+		return fmt.Errorf("%w: %s", ErrListingExists, p.MlsNumber)
+	}
+	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to start transaction: %v", err)
 	}
 
 	city := strings.ToLower(p.City)
 	state := strings.ToLower(p.State)
-	if !d.cityExisted(city, state) {
-		if err := d.insertCity(tx, city, state); err != nil {
+	if !d.cityExisted(ctx, city, state) {
+		if err := d.insertCity(ctx, tx, city, state); err != nil {
 			tx.Rollback()
 			return fmt.Errorf("failed to insert a new city '%s, %s' with err: %v", city, state, err)
 		}
 	}
 
-	if err := d.insertProperty(tx, p); err != nil {
+	if err := d.insertProperty(ctx, tx, p); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to insert a new property with err: %v", err)
 	}
 
-	if err := d.insertMls(tx, p); err != nil {
+	if err := d.insertMls(ctx, tx, p, status); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to insert a new mls listing %q with err: %v", p.MlsNumber, err)
 	}
 
-	if err := d.insertPhoto(tx, p); err != nil {
+	if err := d.insertPhoto(ctx, tx, p); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to insert a listing photo with err: %v", err)
 	}
 
-	if err := d.insertPriceHistory(tx, p); err != nil {
+	if err := d.insertPriceHistory(ctx, tx, p); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to insert a price history with err: %v", err)
 	}
 
+	if err := d.insertLocalizedText(ctx, tx, p); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to insert the localized text with err: %v", err)
+	}
+
+	if err := d.saveTags(ctx, tx, p.MlsNumber, p.Tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO statusHistory (mlsNumber, status, statusTimestamp) VALUES(?, ?, ?)`, p.MlsNumber, status, listedTimestamp(p)); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to insert the status history with err: %v", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("failed to save new listing: %v", err)
@@ -395,86 +338,950 @@ func (d *SqliteDB) SaveNewListing(p *mlspb.Property) error {
 	return nil
 }
 
-func (d *SqliteDB) ReadListing(mlsNumber string) (string, error) {
-	return "", nil
+// latestPrice is the latest saved price of a listing, priced is false when it
+// has none.
+type latestPrice struct {
+	price  int32
+	priced bool
 }
 
-func (d *SqliteDB) ReadListings() (*mlspb.Listings, error) {
-	listings := &mlspb.Listings{}
-	rows, err := d.db.Query(`SELECT mlsNumber, mlsId, mlsUrl, bathrooms, bedrooms, landSize, publicRemark, stories, propertyType, availableTimestamp, status, source, mls.address, zipcode, city, state, parking, latitude, longitude
+// readLatestPrices reads the latest prices of the saved listings among the
+// MLS numbers.
+func (d *SqliteDB) readLatestPrices(ctx context.Context, mlsNumbers []string) (map[string]latestPrice, error) {
+	prices := map[string]latestPrice{}
+	// sqlite allows at most 999 parameters.
+	for start := 0; start < len(mlsNumbers); start += 500 {
+		end := start + 500
+		if end > len(mlsNumbers) {
+			end = len(mlsNumbers)
+		}
+		args := []interface{}{}
+		for _, mlsNumber := range mlsNumbers[start:end] {
+			args = append(args, mlsNumber)
+		}
+		rows, err := d.db.QueryContext(ctx, `SELECT mlsNumber,
+				(SELECT price FROM priceHistory h WHERE h.mlsNumber = mls.mlsNumber ORDER BY priceTimestamp DESC, rowid DESC LIMIT 1)
+			FROM mls
+			WHERE mlsNumber IN (?`+strings.Repeat(", ?", len(args)-1)+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var mlsNumber string
+			var price sql.NullInt64
+			if err := rows.Scan(&mlsNumber, &price); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			prices[mlsNumber] = latestPrice{price: int32(price.Int64), priced: price.Valid}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return prices, nil
+}
+
+// SaveListings saves the new listings and appends the new prices of the saved
+// ones in one transaction. A listing that fails is rolled back alone.
+func (d *SqliteDB) SaveListings(ctx context.Context, listings []*mlspb.Property) ([]*SaveResult, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+	results := make([]*SaveResult, len(listings))
+	if len(listings) == 0 {
+		return results, nil
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	mlsNumbers := []string{}
+	for _, p := range listings {
+		mlsNumbers = append(mlsNumbers, p.MlsNumber)
+	}
+	saved, err := d.readLatestPrices(ctx, mlsNumbers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the saved listings: %v", err)
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	for i, p := range listings {
+		r := &SaveResult{MlsNumber: p.MlsNumber}
+		results[i] = r
+		if _, err := tx.ExecContext(ctx, `SAVEPOINT listing`); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to save listing %s: %v", p.MlsNumber, err)
+		}
+		latest, ok := saved[p.MlsNumber]
+		if ok {
+			r.Outcome, r.Err = d.updateListing(ctx, tx, p, latest)
+		} else {
+			r.Outcome, r.Err = Created, d.insertListing(ctx, tx, p)
+		}
+		if r.Err != nil {
+			r.Outcome = Failed
+			if _, err := tx.ExecContext(ctx, `ROLLBACK TO listing`); err != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to roll back listing %s: %v", p.MlsNumber, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `RELEASE listing`); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to save listing %s: %v", p.MlsNumber, err)
+		}
+		if r.Outcome != Failed {
+			// A listing repeated in the batch is compared to the prices saved
+			// before it.
+			if len(p.Price) > 0 {
+				latest = latestPrice{price: p.Price[len(p.Price)-1].Price, priced: true}
+			}
+			saved[p.MlsNumber] = latest
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to save the listings: %v", err)
+	}
+	return results, nil
+}
+
+// SaveOrUpdateListing saves a new listing or appends the new prices of the
+// saved one in one transaction.
+func (d *SqliteDB) SaveOrUpdateListing(ctx context.Context, p *mlspb.Property) (SaveOutcome, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return Failed, fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return Failed, err
+	}
+	defer unlock()
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Failed, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	var saved int
+	var price sql.NullInt64
+	err = tx.QueryRowContext(ctx, `SELECT COUNT(*),
+			(SELECT price FROM priceHistory WHERE mlsNumber = ? ORDER BY priceTimestamp DESC, rowid DESC LIMIT 1)
+		FROM mls WHERE mlsNumber = ?`, p.MlsNumber, p.MlsNumber).Scan(&saved, &price)
+	if err != nil {
+		tx.Rollback()
+		return Failed, fmt.Errorf("failed to read listing %s: %v", p.MlsNumber, err)
+	}
+
+	outcome := Created
+	if saved > 0 {
+		outcome, err = d.updateListing(ctx, tx, p, latestPrice{price: int32(price.Int64), priced: price.Valid})
+	} else {
+		err = d.insertListing(ctx, tx, p)
+	}
+	if err != nil {
+		tx.Rollback()
+		return Failed, err
+	}
+	if err := tx.Commit(); err != nil {
+		return Failed, fmt.Errorf("failed to save listing %s: %v", p.MlsNumber, err)
+	}
+	return outcome, nil
+}
+
+// insertListing saves a new listing within tx.
+func (d *SqliteDB) insertListing(ctx context.Context, tx *sql.Tx, p *mlspb.Property) error {
+	if p.MlsNumber == "" {
+		return fmt.Errorf("%w: missing MLS number", ErrInvalidListing)
+	}
+	status, err := savedStatus(p)
+	if err != nil {
+		return err
+	}
+	city, err := d.prepared(ctx, tx, `INSERT OR IGNORE INTO city (name, state) VALUES(?, ?)`)
+	if err != nil {
+		return err
+	}
+	if _, err := city.ExecContext(ctx, strings.ToLower(p.City), strings.ToLower(p.State)); err != nil {
+		return fmt.Errorf("failed to insert the city of listing %s: %v", p.MlsNumber, err)
+	}
+	if err := d.insertProperty(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := d.insertMls(ctx, tx, p, status); err != nil {
+		return err
+	}
+	if err := d.insertPhoto(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := d.insertPriceHistory(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := d.insertLocalizedText(ctx, tx, p); err != nil {
+		return err
+	}
+	if err := d.saveTags(ctx, tx, p.MlsNumber, p.Tags); err != nil {
+		return err
+	}
+	history, err := d.prepared(ctx, tx, `INSERT INTO statusHistory (mlsNumber, status, statusTimestamp) VALUES(?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	if _, err := history.ExecContext(ctx, p.MlsNumber, status, listedTimestamp(p)); err != nil {
+		return fmt.Errorf("failed to insert the status history of listing %s: %v", p.MlsNumber, err)
+	}
+	return nil
+}
+
+// updateListing appends the prices of a saved listing that change its latest
+// price, changes its status and replaces its localized text and tags within
+// tx.
+func (d *SqliteDB) updateListing(ctx context.Context, tx *sql.Tx, p *mlspb.Property, latest latestPrice) (SaveOutcome, error) {
+	changed, err := d.updateStatus(ctx, tx, p)
+	if err != nil {
+		return Failed, err
+	}
+	prices := newPrices(latest.price, latest.priced, p.Price)
+	if err := d.insertPriceHistory(ctx, tx, &mlspb.Property{MlsNumber: p.MlsNumber, Price: prices}); err != nil {
+		return Failed, err
+	}
+	if err := d.insertLocalizedText(ctx, tx, p); err != nil {
+		return Failed, err
+	}
+	if len(p.Tags) > 0 {
+		if err := d.saveTags(ctx, tx, p.MlsNumber, p.Tags); err != nil {
+			return Failed, err
+		}
+	}
+	if len(prices) == 0 && !changed {
+		return Unchanged, nil
+	}
+	return Updated, nil
+}
+
+// updateStatus changes a saved listing to the status collected, if any,
+// within tx. changed is false when the listing has it.
+func (d *SqliteDB) updateStatus(ctx context.Context, tx *sql.Tx, p *mlspb.Property) (changed bool, err error) {
+	if p.Status == "" {
+		return false, nil
+	}
+	status, err := savedStatus(p)
+	if err != nil {
+		return false, err
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE mls
+			SET statusId = (SELECT statusId FROM listingStatus WHERE status = ?)
+			WHERE mlsNumber = ? AND statusId != (SELECT statusId FROM listingStatus WHERE status = ?)`, status, p.MlsNumber, status)
+	if err != nil {
+		return false, fmt.Errorf("failed to update the status of listing %s: %v", p.MlsNumber, err)
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO statusHistory (mlsNumber, status, statusTimestamp) VALUES(?, ?, ?)`, p.MlsNumber, status, time.Now().Unix()); err != nil {
+		return false, fmt.Errorf("failed to insert the status history of listing %s: %v", p.MlsNumber, err)
+	}
+	return true, nil
+}
+
+// ReadListing reads a listing by MLS number.
+func (d *SqliteDB) ReadListing(ctx context.Context, mlsNumber string) (*mlspb.Property, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+
+	listings, err := d.readListings(ctx, selectListings+`
+		WHERE mlsNumber = $1`, mlsNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read listing %s: %v", mlsNumber, err)
+	}
+	if len(listings.Property) == 0 {
+		return nil, ErrNotFound
+	}
+	return listings.Property[0], nil
+}
+
+const selectListings = `SELECT mlsNumber, mlsId, mlsUrl, bathrooms, bedrooms, landSize, publicRemark, stories, propertyType, availableTimestamp, status, source, mls.address, zipcode, city, state, parking, latitude, longitude
 		FROM mls
 		INNER JOIN property ON mls.address = property.address
-		INNER JOIN listingStatus ON mls.statusId = listingStatus.statusId
-		WHERE status = "Open" LIMIT 10`)
+		INNER JOIN listingStatus ON mls.statusId = listingStatus.statusId`
+
+// ReadListings reads every listing in MLS number order, whatever its status.
+func (d *SqliteDB) ReadListings(ctx context.Context) (*mlspb.Listings, error) {
+	return d.readListings(ctx, selectListings+`
+		ORDER BY mlsNumber`)
+}
+
+// ReadListingsByTags reads the listings tagged with every one of the tags.
+func (d *SqliteDB) ReadListingsByTags(ctx context.Context, tags []string) (*mlspb.Listings, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+	if len(tags) == 0 {
+		return d.readListings(ctx, selectListings)
+	}
+
+	tags = uniqueTags(tags)
+	placeholders := make([]string, len(tags))
+	args := []interface{}{}
+	for i, tag := range tags {
+		placeholders[i] = "?"
+		args = append(args, tag)
+	}
+	args = append(args, len(tags))
+	return d.readListings(ctx, selectListings+`
+		WHERE mlsNumber IN (
+			SELECT mlsNumber FROM listingTag
+			WHERE tag IN (`+strings.Join(placeholders, ", ")+`)
+			GROUP BY mlsNumber
+			HAVING COUNT(DISTINCT tag) = ?)`, args...)
+}
+
+// selectListingValues selects the values of the listings the queries filter and
+// sort on, as ListingQuery.matches and priceValues compute them.
+const selectListingValues = `SELECT mls.mlsNumber AS mlsNumber, status, source, propertyType, city, state, zipcode,
+			CAST(bedrooms AS INTEGER) + CASE WHEN instr(bedrooms, '+') > 0 THEN CAST(substr(bedrooms, instr(bedrooms, '+') + 1) AS INTEGER) ELSE 0 END AS bedroomCount,
+			CAST(bathrooms AS INTEGER) AS bathroomCount,
+			IFNULL((SELECT price FROM priceHistory h WHERE h.mlsNumber = mls.mlsNumber ORDER BY priceTimestamp DESC, rowid DESC LIMIT 1), 0) AS latestPrice,
+			IFNULL((SELECT price FROM priceHistory h WHERE h.mlsNumber = mls.mlsNumber ORDER BY priceTimestamp, rowid LIMIT 1), 0) AS firstPrice,
+			IFNULL((SELECT MIN(priceTimestamp) FROM priceHistory h WHERE h.mlsNumber = mls.mlsNumber), 0) AS firstSeen
+		FROM mls
+		INNER JOIN property ON mls.address = property.address
+		INNER JOIN listingStatus ON mls.statusId = listingStatus.statusId`
+
+// QueryListings reads a page of the listings matching the query.
+func (d *SqliteDB) QueryListings(ctx context.Context, q ListingQuery) (*ListingPage, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+	if err := q.validate(); err != nil {
+		return nil, err
+	}
+	c, err := decodeCursor(q.Cursor)
 	if err != nil {
 		return nil, err
 	}
+
+	sortValue := map[ListingSort]string{
+		SortByDate:        "firstSeen",
+		SortByPrice:       "latestPrice",
+		SortByPriceChange: "latestPrice - firstPrice",
+	}[q.Sort]
+	conditions := []string{}
+	args := []interface{}{}
+	where := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+	if q.City != "" {
+		where("lower(city) = lower(?)", q.City)
+	}
+	if q.State != "" {
+		where("lower(state) = lower(?)", q.State)
+	}
+	if q.PostalPrefix != "" {
+		where("substr(lower(zipcode), 1, length(?)) = lower(?)", q.PostalPrefix, q.PostalPrefix)
+	}
+	if q.Status != "" {
+		where("status = ?", q.Status)
+	}
+	if q.PropertyType != "" {
+		where("propertyType = ?", q.PropertyType)
+	}
+	if q.Source != "" {
+		where("source = ?", q.Source)
+	}
+	if q.MinPrice > 0 {
+		where("latestPrice >= ?", q.MinPrice)
+	}
+	if q.MaxPrice > 0 {
+		where("latestPrice <= ?", q.MaxPrice)
+	}
+	if q.MinBedrooms > 0 {
+		where("bedroomCount >= ?", q.MinBedrooms)
+	}
+	if q.MaxBedrooms > 0 {
+		where("bedroomCount <= ?", q.MaxBedrooms)
+	}
+	if q.MinBathrooms > 0 {
+		where("bathroomCount >= ?", q.MinBathrooms)
+	}
+	if q.MaxBathrooms > 0 {
+		where("bathroomCount <= ?", q.MaxBathrooms)
+	}
+	if q.FirstSeenFrom > 0 {
+		where("firstSeen >= ?", q.FirstSeenFrom)
+	}
+	if q.FirstSeenTo > 0 {
+		where("firstSeen <= ?", q.FirstSeenTo)
+	}
+	order, direction := "ASC", ">"
+	if q.Descending {
+		order, direction = "DESC", "<"
+	}
+	if c != nil {
+		where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND mlsNumber %[2]s ?))", sortValue, direction), c.Value, c.Value, c.MlsNumber)
+	}
+	query := fmt.Sprintf("SELECT mlsNumber, %s FROM (%s)", sortValue, selectListingValues)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// One more listing than the page tells whether there is a next page.
+	query += fmt.Sprintf(" ORDER BY %s %s, mlsNumber %s LIMIT ?", sortValue, order, order)
+	args = append(args, q.limit()+1)
+
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the listings: %v", err)
+	}
 	defer rows.Close()
+	found := []cursor{}
 	for rows.Next() {
-		var (
-			mlsNumber, mlsID, mlsURL, bathrooms, bedrooms, landSize, publicRemark, stories, propertyType, status, source, address, zipcode, city, state, parking string
-			availableTimestamp                                                                                                                                   int64
-			latitude, longitude                                                                                                                                  float64
-		)
-		if err := rows.Scan(&mlsNumber, &mlsID, &mlsURL, &bathrooms, &bedrooms, &landSize, &publicRemark, &stories, &propertyType, &availableTimestamp, &status, &source, &address, &zipcode, &city, &state, &parking, &latitude, &longitude); err != nil {
-			return nil, err
+		var f cursor
+		if err := rows.Scan(&f.MlsNumber, &f.Value); err != nil {
+			return nil, fmt.Errorf("failed to query the listings: %v", err)
 		}
-		parkings := []string{parking}
+		found = append(found, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query the listings: %v", err)
+	}
 
-		photoRows, err := d.db.Query(`SELECT photoUrl FROM photo WHERE mlsNumber = $1`, mlsNumber)
-		if err != nil {
+	page := &ListingPage{Listings: &mlspb.Listings{}}
+	if len(found) > q.limit() {
+		found = found[:q.limit()]
+		page.NextCursor = encodeCursor(found[len(found)-1])
+	}
+	if len(found) == 0 {
+		return page, nil
+	}
+	placeholders := make([]string, len(found))
+	mlsNumbers := make([]interface{}, len(found))
+	for i, f := range found {
+		placeholders[i] = "?"
+		mlsNumbers[i] = f.MlsNumber
+	}
+	listings, err := d.readListings(ctx, selectListings+`
+		WHERE mlsNumber IN (`+strings.Join(placeholders, ", ")+`)`, mlsNumbers...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the queried listings: %v", err)
+	}
+	byMlsNumber := map[string]*mlspb.Property{}
+	for _, p := range listings.Property {
+		byMlsNumber[p.MlsNumber] = p
+	}
+	for _, f := range found {
+		page.Listings.Property = append(page.Listings.Property, byMlsNumber[f.MlsNumber])
+	}
+	return page, nil
+}
+
+// listingsBatchSize is the number of listings whose details are read by one
+// query, sqlite allows at most 999 parameters.
+const listingsBatchSize = 500
+
+// eachRow calls scan on every row of the query and closes the rows.
+func (d *SqliteDB) eachRow(ctx context.Context, scan func(*sql.Rows) error, query string, args ...interface{}) error {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// readListings reads the listings of a selectListings query with their
+// details.
+func (d *SqliteDB) readListings(ctx context.Context, query string, args ...interface{}) (*mlspb.Listings, error) {
+	listings := &mlspb.Listings{}
+	if err := d.eachRow(ctx, func(rows *sql.Rows) error {
+		var parking string
+		p := &mlspb.Property{Price: []*mlspb.PriceHistory{}}
+		if err := rows.Scan(&p.MlsNumber, &p.MlsId, &p.MlsUrl, &p.Bathrooms, &p.Bedrooms, &p.LandSize, &p.PublicRemarks, &p.Stories, &p.PropertyType, &p.ListTimestamp, &p.Status, &p.Source, &p.Address, &p.Zipcode, &p.City, &p.State, &parking, &p.Latitude, &p.Longitude); err != nil {
+			return err
+		}
+		if parking != "" {
+			p.Parking = strings.Split(parking, ";")
+		}
+		listings.Property = append(listings.Property, p)
+		return nil
+	}, query, args...); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < len(listings.Property); start += listingsBatchSize {
+		end := start + listingsBatchSize
+		if end > len(listings.Property) {
+			end = len(listings.Property)
+		}
+		if err := d.readDetails(ctx, listings.Property[start:end]); err != nil {
 			return nil, err
 		}
-		var photos []string
-		for photoRows.Next() {
-			var photoUrl string
-			if err := photoRows.Scan(&photoUrl); err != nil {
-				return nil, err
-			}
-			photos = append(photos, photoUrl)
+	}
+	return listings, nil
+}
+
+// readDetails reads the photos, prices, tags, localized text and status
+// history of the listings, one query each.
+func (d *SqliteDB) readDetails(ctx context.Context, listings []*mlspb.Property) error {
+	byMlsNumber := map[string]*mlspb.Property{}
+	args := []interface{}{}
+	for _, p := range listings {
+		byMlsNumber[p.MlsNumber] = p
+		args = append(args, p.MlsNumber)
+	}
+	in := `WHERE mlsNumber IN (?` + strings.Repeat(", ?", len(args)-1) + `)`
+
+	if err := d.eachRow(ctx, func(rows *sql.Rows) error {
+		var mlsNumber, photoURL string
+		if err := rows.Scan(&mlsNumber, &photoURL); err != nil {
+			return err
 		}
+		p := byMlsNumber[mlsNumber]
+		p.PhotoUrl = append(p.PhotoUrl, photoURL)
+		return nil
+	}, `SELECT mlsNumber, photoUrl FROM photo `+in+` ORDER BY rowid`, args...); err != nil {
+		return fmt.Errorf("failed to read the photos: %v", err)
+	}
 
-		priceRows, err := d.db.Query(`SELECT price, priceTimestamp FROM priceHistory WHERE mlsNumber = $1`, mlsNumber)
+	if err := d.eachRow(ctx, func(rows *sql.Rows) error {
+		var mlsNumber string
+		price := &mlspb.PriceHistory{}
+		if err := rows.Scan(&mlsNumber, &price.Price, &price.Timestamp); err != nil {
+			return err
+		}
+		p := byMlsNumber[mlsNumber]
+		p.Price = append(p.Price, price)
+		return nil
+	}, `SELECT mlsNumber, price, priceTimestamp FROM priceHistory `+in+` ORDER BY priceTimestamp, rowid`, args...); err != nil {
+		return fmt.Errorf("failed to read the price history: %v", err)
+	}
+
+	if err := d.eachRow(ctx, func(rows *sql.Rows) error {
+		var mlsNumber, tag string
+		if err := rows.Scan(&mlsNumber, &tag); err != nil {
+			return err
+		}
+		p := byMlsNumber[mlsNumber]
+		p.Tags = append(p.Tags, tag)
+		return nil
+	}, `SELECT mlsNumber, tag FROM listingTag `+in+` ORDER BY tag`, args...); err != nil {
+		return fmt.Errorf("failed to read the tags: %v", err)
+	}
+
+	if err := d.eachRow(ctx, func(rows *sql.Rows) error {
+		var mlsNumber, lang string
+		text := &mlspb.LocalizedText{}
+		if err := rows.Scan(&mlsNumber, &lang, &text.PublicRemarks, &text.PropertyType); err != nil {
+			return err
+		}
+		p := byMlsNumber[mlsNumber]
+		if p.LocalizedText == nil {
+			p.LocalizedText = make(map[string]*mlspb.LocalizedText)
+		}
+		p.LocalizedText[lang] = text
+		return nil
+	}, `SELECT mlsNumber, language, publicRemark, propertyType FROM localizedText `+in, args...); err != nil {
+		return fmt.Errorf("failed to read the localized text: %v", err)
+	}
+
+	if err := d.eachRow(ctx, func(rows *sql.Rows) error {
+		var mlsNumber string
+		status := &mlspb.StatusHistory{}
+		if err := rows.Scan(&mlsNumber, &status.Status, &status.Timestamp); err != nil {
+			return err
+		}
+		p := byMlsNumber[mlsNumber]
+		p.StatusHistory = append(p.StatusHistory, status)
+		return nil
+	}, `SELECT mlsNumber, status, statusTimestamp FROM statusHistory `+in+` ORDER BY rowid`, args...); err != nil {
+		return fmt.Errorf("failed to read the status history: %v", err)
+	}
+	return nil
+}
+
+// sqliteIterator reads the listings by pages of listingsBatchSize in MLS
+// number order.
+type sqliteIterator struct {
+	ctx     context.Context
+	d       *SqliteDB
+	page    []*mlspb.Property
+	current *mlspb.Property
+	// after is the MLS number of the last listing read.
+	after string
+	done  bool
+	err   error
+}
+
+// IterateListings reads every listing one at a time in MLS number order.
+func (d *SqliteDB) IterateListings(ctx context.Context) (ListingIterator, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+	return &sqliteIterator{ctx: ctx, d: d}, nil
+}
+
+func (it *sqliteIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if len(it.page) == 0 {
+		if it.done {
+			return false
+		}
+		listings, err := it.d.readListings(it.ctx, selectListings+`
+			WHERE mlsNumber > ? ORDER BY mlsNumber LIMIT ?`, it.after, listingsBatchSize)
 		if err != nil {
+			it.err = fmt.Errorf("failed to read the listings after %q: %v", it.after, err)
+			return false
+		}
+		it.page = listings.Property
+		it.done = len(it.page) < listingsBatchSize
+		if len(it.page) == 0 {
+			return false
+		}
+		it.after = it.page[len(it.page)-1].MlsNumber
+	}
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *sqliteIterator) Listing() *mlspb.Property {
+	return it.current
+}
+
+func (it *sqliteIterator) Err() error {
+	return it.err
+}
+
+func (it *sqliteIterator) Close() error {
+	it.page, it.done = nil, true
+	return nil
+}
+
+// SavePhotoHash records the perceptual hash of a saved listing photo.
+func (d *SqliteDB) SavePhotoHash(ctx context.Context, photoURL string, hash uint64) error {
+	if err := d.CreateStorage(ctx); err != nil {
+		return fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// sqlite integers are signed 64 bit, the hash bits are stored as is.
+	result, err := d.db.ExecContext(ctx, `UPDATE photo SET hash = $1 WHERE photoUrl = $2`, int64(hash), photoURL)
+	if err != nil {
+		return fmt.Errorf("failed to save hash of photo %s: %v", photoURL, err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save hash of photo %s: %v", photoURL, err)
+	}
+	if updated == 0 {
+		return fmt.Errorf("photo %s does not exist", photoURL)
+	}
+	return nil
+}
+
+// FindDuplicatePhotos finds the listings that share the same pictures.
+func (d *SqliteDB) FindDuplicatePhotos(ctx context.Context) ([]*PhotoDuplicate, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+
+	rows, err := d.db.QueryContext(ctx, `SELECT DISTINCT hash, mlsNumber FROM photo WHERE hash IS NOT NULL`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	listingsByHash := make(map[uint64]map[string]bool)
+	for rows.Next() {
+		var (
+			hash      int64
+			mlsNumber string
+		)
+		if err := rows.Scan(&hash, &mlsNumber); err != nil {
 			return nil, err
 		}
-		prices := []*mlspb.PriceHistory{}
-		for priceRows.Next() {
-			var p int32
-			var t int64
-			if err := priceRows.Scan(&p, &t); err != nil {
-				return nil, err
-			}
-			prices = append(prices, &mlspb.PriceHistory{
-				Price:     p,
-				Timestamp: t,
-			})
-		}
-
-		p := &mlspb.Property{
-			Address:       address,
-			Bathrooms:     bathrooms,
-			Bedrooms:      bedrooms,
-			LandSize:      landSize,
-			MlsId:         mlsID,
-			MlsNumber:     mlsNumber,
-			MlsUrl:        mlsURL,
-			Parking:       parkings,
-			PhotoUrl:      photos,
-			Price:         prices,
-			PublicRemarks: publicRemark,
-			Stories:       stories,
-			PropertyType:  propertyType,
-			ListTimestamp: availableTimestamp,
-			Source:        source,
-			Latitude:      latitude,
-			Longitude:     longitude,
-			City:          city,
-			State:         state,
-			Zipcode:       zipcode,
-			Status:        status,
+		if _, ok := listingsByHash[uint64(hash)]; !ok {
+			listingsByHash[uint64(hash)] = make(map[string]bool)
 		}
-		listings.Property = append(listings.Property, p)
+		listingsByHash[uint64(hash)][mlsNumber] = true
 	}
-	return listings, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return duplicatePhotos(listingsByHash), nil
+}
+
+// SaveListingTags replaces the feature tags of an existing listing.
+func (d *SqliteDB) SaveListingTags(ctx context.Context, mlsNumber string, tags []string) error {
+	if err := d.CreateStorage(ctx); err != nil {
+		return fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !d.listingExisted(ctx, mlsNumber) {
+		return fmt.Errorf("%w: %s", ErrNotFound, mlsNumber)
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	if err := d.saveTags(ctx, tx, mlsNumber, tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save listing tags: %v", err)
+	}
+	return nil
+}
+
+// saveTags replaces the tags of a listing within tx.
+func (d *SqliteDB) saveTags(ctx context.Context, tx *sql.Tx, mlsNumber string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM listingTag WHERE mlsNumber = $1`, mlsNumber); err != nil {
+		return fmt.Errorf("failed to clear the tags of listing %s: %v", mlsNumber, err)
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO listingTag (mlsNumber, tag) VALUES(?, ?)`, mlsNumber, tag); err != nil {
+			return fmt.Errorf("failed to tag listing %s with %q: %v", mlsNumber, tag, err)
+		}
+	}
+	return nil
+}
+
+// SaveCheckpoint saves the progress of the current run of a source.
+func (d *SqliteDB) SaveCheckpoint(ctx context.Context, c *Checkpoint) error {
+	if err := d.CreateStorage(ctx); err != nil {
+		return fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.db.ExecContext(ctx, `INSERT OR REPLACE INTO checkpoint (
+			source, runId, region, tile, page, startedAt, covered)
+			VALUES(?, ?, ?, ?, ?, ?, ?)`, c.Source, c.RunID, c.Region, c.Tile, c.Page, c.StartedAt, c.Covered); err != nil {
+		return fmt.Errorf("failed to save the checkpoint of %q: %v", c.Source, err)
+	}
+	return nil
+}
+
+// ReadCheckpoint reads the progress of the current run of a source.
+func (d *SqliteDB) ReadCheckpoint(ctx context.Context, source string) (*Checkpoint, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+
+	c := &Checkpoint{Source: source}
+	err := d.db.QueryRowContext(ctx, `SELECT runId, region, tile, page, startedAt, covered FROM checkpoint WHERE source = $1`, source).Scan(
+		&c.RunID, &c.Region, &c.Tile, &c.Page, &c.StartedAt, &c.Covered)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the checkpoint of %q: %v", source, err)
+	}
+	return c, nil
+}
+
+// DeleteCheckpoint deletes the progress of a source once its run completes.
+func (d *SqliteDB) DeleteCheckpoint(ctx context.Context, source string) error {
+	if err := d.CreateStorage(ctx); err != nil {
+		return fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.db.ExecContext(ctx, `DELETE FROM checkpoint WHERE source = $1`, source); err != nil {
+		return fmt.Errorf("failed to delete the checkpoint of %q: %v", source, err)
+	}
+	return nil
+}
+
+// MarkListingSeen records when a listing was last seen on its source. A
+// delisted listing seen again is open again.
+func (d *SqliteDB) MarkListingSeen(ctx context.Context, mlsNumber string, timestamp int64) error {
+	if err := d.CreateStorage(ctx); err != nil {
+		return fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if !d.listingExisted(ctx, mlsNumber) {
+		return fmt.Errorf("%w: %s", ErrNotFound, mlsNumber)
+	}
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO listingSeen (mlsNumber, lastSeen) VALUES(?, ?)`, mlsNumber, timestamp); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to mark listing %s seen: %v", mlsNumber, err)
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE mls
+			SET statusId = (SELECT statusId FROM listingStatus WHERE status = "Open")
+			WHERE mlsNumber = $1 AND statusId = (SELECT statusId FROM listingStatus WHERE status = "Delisted")`, mlsNumber)
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to reopen listing %s: %v", mlsNumber, err)
+	}
+	if reopened, _ := result.RowsAffected(); reopened > 0 {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO statusHistory (mlsNumber, status, statusTimestamp) VALUES(?, "Open", ?)`, mlsNumber, timestamp); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to reopen listing %s: %v", mlsNumber, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to mark listing %s seen: %v", mlsNumber, err)
+	}
+	return nil
+}
+
+// DelistUnseen delists the open listings of source not seen since the
+// timestamp.
+func (d *SqliteDB) DelistUnseen(ctx context.Context, source string, since int64) (int64, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return 0, fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	unseen := `FROM mls
+			WHERE source = ?
+			AND statusId = (SELECT statusId FROM listingStatus WHERE status = "Open")
+			AND mlsNumber NOT IN (SELECT mlsNumber FROM listingSeen WHERE lastSeen >= ?)`
+	if _, err := tx.ExecContext(ctx, `INSERT INTO statusHistory (mlsNumber, status, statusTimestamp)
+			SELECT mlsNumber, "Delisted", ? `+unseen, time.Now().Unix(), source, since); err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to delist the unseen listings of %q: %v", source, err)
+	}
+	result, err := tx.ExecContext(ctx, `UPDATE mls
+			SET statusId = (SELECT statusId FROM listingStatus WHERE status = "Delisted")
+			WHERE mlsNumber IN (SELECT mlsNumber `+unseen+`)`, source, since)
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to delist the unseen listings of %q: %v", source, err)
+	}
+	delisted, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to delist the unseen listings of %q: %v", source, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to delist the unseen listings of %q: %v", source, err)
+	}
+	return delisted, nil
+}
+
+// ReadLatestPrice returns the last price saved for a listing.
+func (d *SqliteDB) ReadLatestPrice(ctx context.Context, mlsNumber string) (int32, bool, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return 0, false, fmt.Errorf("failed to create DB: %s", err)
+	}
+	if !d.listingExisted(ctx, mlsNumber) {
+		return 0, false, nil
+	}
+
+	var price int32
+	err := d.db.QueryRowContext(ctx, `SELECT price FROM priceHistory
+		WHERE mlsNumber = $1
+		ORDER BY priceTimestamp DESC, rowid DESC
+		LIMIT 1`, mlsNumber).Scan(&price)
+	if err == sql.ErrNoRows {
+		return 0, true, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read the price of listing %s: %v", mlsNumber, err)
+	}
+	return price, true, nil
+}
+
+// SaveCollectionRun adds or replaces the record of a collector run.
+func (d *SqliteDB) SaveCollectionRun(ctx context.Context, r *mlspb.CollectionRun) error {
+	if err := d.CreateStorage(ctx); err != nil {
+		return fmt.Errorf("failed to create DB: %s", err)
+	}
+	unlock, err := d.lockWriter(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := d.db.ExecContext(ctx, `INSERT OR REPLACE INTO collection_runs (
+			runId, source, region, startedAt, endedAt, pages, httpErrors,
+			newListings, updatedListings, delistedListings, error)
+			VALUES(?, ?, ?, ?, ?, ?, ?,
+			?, ?, ?, ?)`,
+		r.RunId, r.Source, strings.Join(r.Region, ";"), r.StartedAt, r.EndedAt, r.Pages, r.HttpErrors,
+		r.NewListings, r.UpdatedListings, r.DelistedListings, r.Error); err != nil {
+		return fmt.Errorf("failed to save collection run %s: %v", r.RunId, err)
+	}
+	return nil
+}
+
+// ReadCollectionRuns reads the limit most recent collector runs.
+func (d *SqliteDB) ReadCollectionRuns(ctx context.Context, limit int) (*mlspb.CollectionRuns, error) {
+	if err := d.CreateStorage(ctx); err != nil {
+		return nil, fmt.Errorf("failed to create DB: %s", err)
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+
+	rows, err := d.db.QueryContext(ctx, `SELECT runId, source, region, startedAt, endedAt, pages, httpErrors,
+			newListings, updatedListings, delistedListings, error
+		FROM collection_runs
+		ORDER BY startedAt DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the collection runs: %v", err)
+	}
+	defer rows.Close()
+
+	runs := &mlspb.CollectionRuns{}
+	for rows.Next() {
+		r := &mlspb.CollectionRun{}
+		var region string
+		if err := rows.Scan(&r.RunId, &r.Source, &region, &r.StartedAt, &r.EndedAt, &r.Pages, &r.HttpErrors,
+			&r.NewListings, &r.UpdatedListings, &r.DelistedListings, &r.Error); err != nil {
+			return nil, fmt.Errorf("failed to read the collection runs: %v", err)
+		}
+		if region != "" {
+			r.Region = strings.Split(region, ";")
+		}
+		runs.Run = append(runs.Run, r)
+	}
+	return runs, rows.Err()
 }
@@ -1,7 +1,12 @@
 package storage
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -9,6 +14,12 @@ import (
 )
 
 func cleanSqliteDB(dbPath string) error {
+	// The WAL files are left once the connections close.
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
 	return os.Remove(dbPath)
 }
 
@@ -50,7 +61,7 @@ func TestSqliteSaveNewListing(t *testing.T) {
 				Zipcode:       "A0B1C2",
 			},
 		}
-		if err := db.SaveNewListing(listings[mlsNumber]); err != nil {
+		if err := db.SaveNewListing(context.Background(), listings[mlsNumber]); err != nil {
 			t.Errorf("Failed to save the new listing: %v", err)
 		}
 
@@ -96,11 +107,11 @@ func TestSqliteSaveNewListing(t *testing.T) {
 				Zipcode:       "A0B1C2",
 			},
 		}
-		if err := db.SaveNewListing(listings[mlsNumber]); err != nil {
+		if err := db.SaveNewListing(context.Background(), listings[mlsNumber]); err != nil {
 			t.Errorf("Failed to save the new listing: %v", err)
 		}
 
-		if err := db.SaveNewListing(listings[mlsNumber]); err == nil {
+		if err := db.SaveNewListing(context.Background(), listings[mlsNumber]); err == nil {
 			t.Errorf("Save the same listing should fail: %v", err)
 		}
 		if err := cleanSqliteDB(dbPath); err != nil {
@@ -147,16 +158,16 @@ func TestSqliteReadListings(t *testing.T) {
 				Zipcode:       "A0B1C2",
 			},
 		}
-		if err := db.SaveNewListing(listings[mlsNumber]); err != nil {
+		if err := db.SaveNewListing(context.Background(), listings[mlsNumber]); err != nil {
 			t.Errorf("Failed to save the new listing: %v", err)
 		}
-		results, err := db.ReadListings()
+		results, err := db.ReadListings(context.Background())
 		if err != nil {
 			t.Errorf("Failed to read the saved listing: %v", err)
 		}
 
 		if results.Property[0].MlsId != listings[mlsNumber].MlsId {
-			t.Errorf("mlsID incorrectly saved, expected %d, got %s", listings[mlsNumber].MlsId, results.Property[0].MlsId)
+			t.Errorf("mlsID incorrectly saved, expected %s, got %s", listings[mlsNumber].MlsId, results.Property[0].MlsId)
 		}
 
 		if results.Property[0].MlsUrl != listings[mlsNumber].MlsUrl {
@@ -180,3 +191,670 @@ func TestSqliteReadListings(t *testing.T) {
 		}
 	})
 }
+
+func TestSqliteFindDuplicatePhotos(t *testing.T) {
+	t.Run("find listings sharing the same photo", func(t *testing.T) {
+		var dbPath = "/tmp/realtor4.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		listings := []*mlspb.Property{
+			{
+				Address:   "1234 street|city, province A0B1C2",
+				MlsNumber: "19016321",
+				PhotoUrl:  []string{"https://picture/listings/high/1.jpg", "https://picture/listings/high/2.jpg"},
+				City:      "city",
+				State:     "province",
+				Zipcode:   "A0B1C2",
+			},
+			{
+				Address:   "1234 Street|City, Province A0B1C2",
+				MlsNumber: "19016322",
+				PhotoUrl:  []string{"https://picture/listings/high/3.jpg"},
+				City:      "city",
+				State:     "province",
+				Zipcode:   "A0B1C2",
+			},
+			{
+				Address:   "5678 street|city, province A0B1C2",
+				MlsNumber: "19016323",
+				PhotoUrl:  []string{"https://picture/listings/high/4.jpg"},
+				City:      "city",
+				State:     "province",
+				Zipcode:   "A0B1C2",
+			},
+		}
+		for _, l := range listings {
+			if err := db.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+
+		hashes := map[string]uint64{
+			"https://picture/listings/high/1.jpg": 0xf0f0000000000000,
+			"https://picture/listings/high/2.jpg": 0x0f0f,
+			"https://picture/listings/high/3.jpg": 0xf0f0000000000000,
+			"https://picture/listings/high/4.jpg": 0xffff,
+		}
+		for url, hash := range hashes {
+			if err := db.SavePhotoHash(context.Background(), url, hash); err != nil {
+				t.Errorf("Failed to save the photo hash: %v", err)
+			}
+		}
+		if err := db.SavePhotoHash(context.Background(), "https://picture/listings/high/unknown.jpg", 1); err == nil {
+			t.Error("Save the hash of an unknown photo should fail")
+		}
+
+		duplicates, err := db.FindDuplicatePhotos(context.Background())
+		if err != nil {
+			t.Errorf("Failed to find duplicate photos: %v", err)
+		}
+		if len(duplicates) != 1 {
+			t.Fatalf("expected 1 duplicate photo, got %d", len(duplicates))
+		}
+		if duplicates[0].Hash != 0xf0f0000000000000 {
+			t.Errorf("duplicate hash incorrect, expected %x, got %x", uint64(0xf0f0000000000000), duplicates[0].Hash)
+		}
+		if len(duplicates[0].MlsNumbers) != 2 || duplicates[0].MlsNumbers[0] != "19016321" || duplicates[0].MlsNumbers[1] != "19016322" {
+			t.Errorf("duplicate listings incorrect, expected [19016321 19016322], got %v", duplicates[0].MlsNumbers)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteReadListingsByTags(t *testing.T) {
+	t.Run("read listings having all the tags", func(t *testing.T) {
+		var dbPath = "/tmp/realtor5.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		listings := []*mlspb.Property{
+			{Address: "1 street|city, province A0B1C2", MlsNumber: "19016324", City: "city", State: "province", Zipcode: "A0B1C2"},
+			{Address: "2 street|city, province A0B1C2", MlsNumber: "19016325", City: "city", State: "province", Zipcode: "A0B1C2"},
+		}
+		for _, l := range listings {
+			if err := db.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		if err := db.SaveListingTags(context.Background(), "19016324", []string{"pool", "garage"}); err != nil {
+			t.Errorf("Failed to save the listing tags: %v", err)
+		}
+		if err := db.SaveListingTags(context.Background(), "19016325", []string{"pool"}); err != nil {
+			t.Errorf("Failed to save the listing tags: %v", err)
+		}
+		if err := db.SaveListingTags(context.Background(), "unknown", []string{"pool"}); err == nil {
+			t.Error("Save the tags of an unknown listing should fail")
+		}
+
+		results, err := db.ReadListingsByTags(context.Background(), []string{"garage", "pool"})
+		if err != nil {
+			t.Errorf("Failed to read the listings by tags: %v", err)
+		}
+		if len(results.Property) != 1 || results.Property[0].MlsNumber != "19016324" {
+			t.Fatalf("expected only listing 19016324, got %v", results.Property)
+		}
+		if len(results.Property[0].Tags) != 2 || results.Property[0].Tags[0] != "garage" || results.Property[0].Tags[1] != "pool" {
+			t.Errorf("tags incorrectly saved, expected [garage pool], got %v", results.Property[0].Tags)
+		}
+
+		results, err = db.ReadListingsByTags(context.Background(), []string{"pool"})
+		if err != nil {
+			t.Errorf("Failed to read the listings by tags: %v", err)
+		}
+		if len(results.Property) != 2 {
+			t.Errorf("expected 2 listings tagged pool, got %d", len(results.Property))
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteLocalizedText(t *testing.T) {
+	t.Run("save and read the listing text in every language", func(t *testing.T) {
+		var dbPath = "/tmp/realtor6.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		listing := &mlspb.Property{
+			Address:   "1234 street|city, province A0B1C2",
+			MlsNumber: "19016326",
+			City:      "city",
+			State:     "province",
+			Zipcode:   "A0B1C2",
+			LocalizedText: map[string]*mlspb.LocalizedText{
+				"en": {PublicRemarks: "HOUSE DESCRIPTION", PropertyType: "House"},
+			},
+		}
+		if err := db.SaveNewListing(context.Background(), listing); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		listing.LocalizedText = map[string]*mlspb.LocalizedText{
+			"fr": {PublicRemarks: "DESCRIPTION DE LA MAISON", PropertyType: "Maison"},
+		}
+		if err := db.UpdateListing(context.Background(), listing); err != nil {
+			t.Errorf("Failed to update the listing: %v", err)
+		}
+
+		results, err := db.ReadListings(context.Background())
+		if err != nil {
+			t.Errorf("Failed to read the saved listing: %v", err)
+		}
+		localized := results.Property[0].LocalizedText
+		if len(localized) != 2 {
+			t.Fatalf("expected text in 2 languages, got %v", localized)
+		}
+		if localized["en"].PublicRemarks != "HOUSE DESCRIPTION" {
+			t.Errorf("english remarks incorrectly saved, expected %s, got %s", "HOUSE DESCRIPTION", localized["en"].PublicRemarks)
+		}
+		if localized["fr"].PropertyType != "Maison" {
+			t.Errorf("french property type incorrectly saved, expected %s, got %s", "Maison", localized["fr"].PropertyType)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteCheckpoint(t *testing.T) {
+	t.Run("save, read and delete the checkpoint of a source", func(t *testing.T) {
+		var dbPath = "/tmp/realtor7.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if c, err := db.ReadCheckpoint(context.Background(), "mls-canada"); c != nil || err != nil {
+			t.Errorf("expected no checkpoint, got %v, %v", c, err)
+		}
+		want := &Checkpoint{RunID: "1", Source: "mls-canada", Region: "windsor", Tile: 2, Page: 3, StartedAt: 100}
+		if err := db.SaveCheckpoint(context.Background(), want); err != nil {
+			t.Errorf("Failed to save the checkpoint: %v", err)
+		}
+		want.Page = 4
+		if err := db.SaveCheckpoint(context.Background(), want); err != nil {
+			t.Errorf("Failed to save the checkpoint: %v", err)
+		}
+		got, err := db.ReadCheckpoint(context.Background(), "mls-canada")
+		if err != nil || *got != *want {
+			t.Errorf("got checkpoint %v, want %v", got, want)
+		}
+		if err := db.DeleteCheckpoint(context.Background(), "mls-canada"); err != nil {
+			t.Errorf("Failed to delete the checkpoint: %v", err)
+		}
+		if c, _ := db.ReadCheckpoint(context.Background(), "mls-canada"); c != nil {
+			t.Errorf("expected the checkpoint to be deleted, got %v", c)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteDelistUnseen(t *testing.T) {
+	t.Run("delist the open listings of the source not seen since the run started", func(t *testing.T) {
+		var dbPath = "/tmp/realtor8.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		for i, l := range []*mlspb.Property{
+			{MlsNumber: "seen", Source: "mls-canada"},
+			{MlsNumber: "unseen", Source: "mls-canada"},
+			{MlsNumber: "other", Source: "reso"},
+		} {
+			l.Address = fmt.Sprintf("%d street|city, province A0B1C2", i)
+			if err := db.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		db.MarkListingSeen(context.Background(), "seen", 200)
+		db.MarkListingSeen(context.Background(), "unseen", 50)
+
+		delisted, err := db.DelistUnseen(context.Background(), "mls-canada", 100)
+		if err != nil || delisted != 1 {
+			t.Errorf("expected 1 delisted listing, got %d, %v", delisted, err)
+		}
+		results, _ := db.ReadListings(context.Background())
+		open := 0
+		for _, p := range results.Property {
+			if p.Status == "Open" {
+				open++
+			}
+		}
+		if open != 2 {
+			t.Errorf("expected 2 open listings, got %d", open)
+		}
+
+		if err := db.MarkListingSeen(context.Background(), "unseen", 300); err != nil {
+			t.Errorf("Failed to mark the listing seen: %v", err)
+		}
+		results, _ = db.ReadListings(context.Background())
+		if len(results.Property) != 3 {
+			t.Errorf("expected the delisted listing to be open again, got %d open listings", len(results.Property))
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteReadLatestPrice(t *testing.T) {
+	t.Run("read the last price of a listing", func(t *testing.T) {
+		var dbPath = "/tmp/realtor9.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		listing := &mlspb.Property{
+			Address:   "1234 street|city, province A0B1C2",
+			MlsNumber: "19016318",
+			Price:     []*mlspb.PriceHistory{{Price: 10000, Timestamp: 1}},
+		}
+		if err := db.SaveNewListing(context.Background(), listing); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		listing.Price = []*mlspb.PriceHistory{{Price: 9000, Timestamp: 2}}
+		if err := db.UpdateListing(context.Background(), listing); err != nil {
+			t.Errorf("Failed to update the listing: %v", err)
+		}
+
+		price, found, err := db.ReadLatestPrice(context.Background(), "19016318")
+		if err != nil || !found || price != 9000 {
+			t.Errorf("got price %d, found %v, err %v, want 9000", price, found, err)
+		}
+		if _, found, _ := db.ReadLatestPrice(context.Background(), "missing"); found {
+			t.Error("expected a missing listing not to be found")
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteCollectionRuns(t *testing.T) {
+	t.Run("read the most recent runs first", func(t *testing.T) {
+		var dbPath = "/tmp/realtor10.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		for i := int64(1); i <= 3; i++ {
+			if err := db.SaveCollectionRun(context.Background(), &mlspb.CollectionRun{
+				RunId:            fmt.Sprintf("run-%d", i),
+				Source:           "mls-canada",
+				Region:           []string{"windsor", "tecumseh"},
+				StartedAt:        i,
+				EndedAt:          i + 1,
+				Pages:            2,
+				HttpErrors:       1,
+				NewListings:      3,
+				UpdatedListings:  4,
+				DelistedListings: 5,
+			}); err != nil {
+				t.Errorf("Failed to save the run: %v", err)
+			}
+		}
+		if err := db.SaveCollectionRun(context.Background(), &mlspb.CollectionRun{RunId: "run-3", StartedAt: 3, Error: "HTTP get error"}); err != nil {
+			t.Errorf("Failed to replace the run: %v", err)
+		}
+
+		runs, err := db.ReadCollectionRuns(context.Background(), 2)
+		if err != nil {
+			t.Fatalf("Failed to read the runs: %v", err)
+		}
+		if len(runs.Run) != 2 || runs.Run[0].RunId != "run-3" || runs.Run[1].RunId != "run-2" {
+			t.Fatalf("unexpected runs %v", runs.Run)
+		}
+		if runs.Run[0].Error != "HTTP get error" || len(runs.Run[0].Region) != 0 {
+			t.Errorf("expected the run to be replaced, got %v", runs.Run[0])
+		}
+		run := runs.Run[1]
+		if strings.Join(run.Region, ",") != "windsor,tecumseh" {
+			t.Errorf("got regions %v, want [windsor tecumseh]", run.Region)
+		}
+		if run.EndedAt != 3 || run.Pages != 2 || run.HttpErrors != 1 || run.NewListings != 3 ||
+			run.UpdatedListings != 4 || run.DelistedListings != 5 {
+			t.Errorf("unexpected run %v", run)
+		}
+		if runs, _ := db.ReadCollectionRuns(context.Background(), 0); len(runs.Run) != 3 {
+			t.Errorf("expected every run without a limit, got %d", len(runs.Run))
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteReadListing(t *testing.T) {
+	t.Run("read a listing with its price and status history", func(t *testing.T) {
+		var dbPath = "/tmp/realtor11.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if err := db.SaveNewListing(context.Background(), &mlspb.Property{
+			Address:       "1234 street|city, province A0B1C2",
+			MlsNumber:     "19016318",
+			Source:        "mls-canada",
+			PhotoUrl:      []string{"https://picture/listings/high/456.jpg", "https://picture/listings/high/457.jpg"},
+			ListTimestamp: 100,
+			Price:         []*mlspb.PriceHistory{{Price: 10000, Timestamp: 100}},
+		}); err != nil {
+			t.Errorf("Failed to save the new listing: %v", err)
+		}
+		if err := db.UpdateListing(context.Background(), &mlspb.Property{MlsNumber: "19016318", Price: []*mlspb.PriceHistory{{Price: 9000, Timestamp: 200}}}); err != nil {
+			t.Errorf("Failed to update the listing: %v", err)
+		}
+		if _, err := db.DelistUnseen(context.Background(), "mls-canada", 300); err != nil {
+			t.Errorf("Failed to delist the listing: %v", err)
+		}
+		if err := db.MarkListingSeen(context.Background(), "19016318", 400); err != nil {
+			t.Errorf("Failed to mark the listing seen: %v", err)
+		}
+
+		p, err := db.ReadListing(context.Background(), "19016318")
+		if err != nil {
+			t.Fatalf("Failed to read the listing: %v", err)
+		}
+		if len(p.PhotoUrl) != 2 || len(p.Price) != 2 || p.Price[1].Price != 9000 || p.Status != "Open" {
+			t.Errorf("unexpected listing %v", p)
+		}
+		statuses := []string{}
+		for _, s := range p.StatusHistory {
+			statuses = append(statuses, s.Status)
+		}
+		if strings.Join(statuses, ",") != "Open,Delisted,Open" || p.StatusHistory[0].Timestamp != 100 || p.StatusHistory[2].Timestamp != 400 {
+			t.Errorf("unexpected status history %v", p.StatusHistory)
+		}
+
+		if _, err := db.ReadListing(context.Background(), "missing"); err != ErrNotFound {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteSameAddress(t *testing.T) {
+	t.Run("keep the property of an address relisted under a new MLS number", func(t *testing.T) {
+		var dbPath = "/tmp/realtor26.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		for _, l := range []*mlspb.Property{
+			{MlsNumber: "1", Latitude: 42.3, Longitude: -83.0},
+			{MlsNumber: "2", Latitude: 45.5, Longitude: -73.6},
+		} {
+			l.Address = "1234 street|city, province A0B1C2"
+			if err := db.SaveNewListing(context.Background(), l); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		for _, mlsNumber := range []string{"1", "2"} {
+			p, err := db.ReadListing(context.Background(), mlsNumber)
+			if err != nil {
+				t.Fatalf("Failed to read the listing: %v", err)
+			}
+			if p.Latitude != 42.3 || p.Longitude != -83.0 {
+				t.Errorf("expected listing %s at the first saved property, got %v, %v", mlsNumber, p.Latitude, p.Longitude)
+			}
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteQueryListings(t *testing.T) {
+	t.Run("filter, sort and page the listings", func(t *testing.T) {
+		var dbPath = "/tmp/realtor12.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		for _, p := range queryFixture() {
+			if err := db.SaveNewListing(context.Background(), p); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		checkQueries(t, db)
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteSaveListings(t *testing.T) {
+	t.Run("save a batch of new and saved listings", func(t *testing.T) {
+		var dbPath = "/tmp/realtor16.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		checkSaveListings(t, db)
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+
+	t.Run("roll back only the listings that fail", func(t *testing.T) {
+		var dbPath = "/tmp/realtor17.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		results, err := db.SaveListings(context.Background(), []*mlspb.Property{
+			{MlsNumber: "1", Address: "1 street|city", PhotoUrl: []string{"https://photo/1.jpg"}},
+			// The photo of another listing fails after the listing is inserted.
+			{MlsNumber: "2", Address: "2 street|city", PhotoUrl: []string{"https://photo/1.jpg"}},
+		})
+		if err != nil {
+			t.Fatalf("Failed to save the listings: %v", err)
+		}
+		if results[0].Outcome != Created || results[1].Outcome != Failed {
+			t.Errorf("unexpected outcomes %v %v", results[0], results[1])
+		}
+		if _, err := db.ReadListing(context.Background(), "2"); err != ErrNotFound {
+			t.Errorf("expected the failed listing to be rolled back, got %v", err)
+		}
+		if _, err := db.ReadListing(context.Background(), "1"); err != nil {
+			t.Errorf("expected the listing to be saved, got %v", err)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteSaveOrUpdateListing(t *testing.T) {
+	t.Run("save then update a listing with typed errors", func(t *testing.T) {
+		var dbPath = "/tmp/realtor19.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		checkSaveOrUpdateListing(t, db)
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteCancelledContext(t *testing.T) {
+	t.Run("stop the queries of a cancelled context", func(t *testing.T) {
+		var dbPath = "/tmp/realtor20.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		checkCancelledContext(t, db)
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteIterateListings(t *testing.T) {
+	t.Run("read every listing by pages in MLS number order", func(t *testing.T) {
+		var dbPath = "/tmp/realtor18.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		checkIterateListings(t, db, 2*listingsBatchSize+3)
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+func TestSqliteCreateStorage(t *testing.T) {
+	t.Run("create the storage of every instance", func(t *testing.T) {
+		first, second := "/tmp/realtor21.db", "/tmp/realtor22.db"
+		db1, err := NewSqliteDB(first)
+		if err != nil {
+			t.Error(err)
+		}
+		db2, err := NewSqliteDB(second)
+		if err != nil {
+			t.Error(err)
+		}
+
+		for _, db := range []*SqliteDB{db1, db2} {
+			if err := db.SaveNewListing(context.Background(), &mlspb.Property{MlsNumber: "1", Address: "1 street|city"}); err != nil {
+				t.Errorf("Failed to save the new listing: %v", err)
+			}
+		}
+		var mode string
+		if err := db2.db.QueryRow(`PRAGMA journal_mode`).Scan(&mode); err != nil || mode != "wal" {
+			t.Errorf("expected the wal journal mode, got %q, %v", mode, err)
+		}
+
+		for _, dbPath := range []string{first, second} {
+			if err := cleanSqliteDB(dbPath); err != nil {
+				t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+			}
+		}
+	})
+}
+
+func TestSqliteConcurrentWrites(t *testing.T) {
+	t.Run("queue the writes of concurrent collectors", func(t *testing.T) {
+		var dbPath = "/tmp/realtor23.db"
+		db, err := NewSqliteDB(dbPath)
+		if err != nil {
+			t.Error(err)
+		}
+
+		ctx := context.Background()
+		var wg sync.WaitGroup
+		errs := make(chan error, 100)
+		for w := 0; w < 4; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 25; i++ {
+					mlsNumber := fmt.Sprintf("%d-%d", w, i)
+					p := &mlspb.Property{MlsNumber: mlsNumber, Address: mlsNumber + " street|city", Source: "mls-canada"}
+					if err := db.SaveNewListing(ctx, p); err != nil {
+						errs <- err
+					}
+					if err := db.MarkListingSeen(ctx, mlsNumber, 100); err != nil {
+						errs <- err
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("Failed to write concurrently: %v", err)
+		}
+
+		if results, err := db.ReadListings(ctx); err != nil || len(results.Property) != 100 {
+			t.Errorf("expected 100 listings, got %v", err)
+		}
+
+		if err := cleanSqliteDB(dbPath); err != nil {
+			t.Errorf("Failed to cleanup the test sqlite db: %v", err)
+		}
+	})
+}
+
+// BenchmarkSqliteConcurrentIngest saves listings from parallel collectors while
+// the listing server queries them, none of them fails on a locked database.
+func BenchmarkSqliteConcurrentIngest(b *testing.B) {
+	var dbPath = "/tmp/realtor24.db"
+	db, err := NewSqliteDB(dbPath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cleanSqliteDB(dbPath)
+	ctx := context.Background()
+	if err := db.CreateStorage(ctx); err != nil {
+		b.Fatal(err)
+	}
+
+	var n int64
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			i := atomic.AddInt64(&n, 1)
+			if i%2 == 0 {
+				if _, err := db.QueryListings(ctx, ListingQuery{Status: "Open", Sort: SortByPrice, Limit: 10}); err != nil {
+					b.Errorf("Failed to query the listings: %v", err)
+				}
+				continue
+			}
+			// The collectors see the same listings again with new prices.
+			mlsNumber := fmt.Sprintf("%d", i%200)
+			p := &mlspb.Property{
+				MlsNumber: mlsNumber,
+				Address:   mlsNumber + " street|city",
+				City:      "city",
+				Price:     []*mlspb.PriceHistory{{Price: int32(i), Timestamp: i}},
+			}
+			if _, err := db.SaveOrUpdateListing(ctx, p); err != nil {
+				b.Errorf("Failed to save the listing: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,699 @@
+// Package storagetest is the conformance suite of the storage backends, every
+// storage.DBInterface implementation runs it in its tests.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+)
+
+// NewDB returns an empty DB for one test of the suite.
+type NewDB func(t *testing.T) storage.DBInterface
+
+// Run runs every test of the suite, each on a new DB.
+func Run(t *testing.T, newDB NewDB) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, db storage.DBInterface)
+	}{
+		{"save and read a listing", testSaveListing},
+		{"save the listings of the same address", testSameAddress},
+		{"reject the invalid and saved listings", testSaveErrors},
+		{"update a listing", testUpdateListing},
+		{"save or update a listing", testSaveOrUpdateListing},
+		{"save a batch of listings", testSaveListings},
+		{"read the latest price", testLatestPrice},
+		{"read every listing", testReadListings},
+		{"iterate the listings", testIterateListings},
+		{"query the listings", testQueryListings},
+		{"tag the listings", testTags},
+		{"save the collected tags", testCollectedTags},
+		{"find the duplicate photos", testPhotoHashes},
+		{"delist and reopen the listings", testStatusTransitions},
+		{"save the collected statuses", testCollectedStatus},
+		{"save the checkpoints", testCheckpoints},
+		{"save the collection runs", testCollectionRuns},
+		{"save and read concurrently", testConcurrency},
+		{"stop on a cancelled context", testCancelledContext},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			db := newDB(t)
+			if err := db.CreateStorage(context.Background()); err != nil {
+				t.Fatalf("Failed to create the storage: %v", err)
+			}
+			tc.test(t, db)
+		})
+	}
+}
+
+// listing returns a listing with every field the backends save, and a price
+// at every timestamp.
+func listing(mlsNumber string, prices ...int32) *mlspb.Property {
+	p := &mlspb.Property{
+		Address:       mlsNumber + " street|Windsor, Ontario N9A1A1",
+		Bathrooms:     "2",
+		Bedrooms:      "3 + 1",
+		LandSize:      "40 x 120 FT",
+		MlsId:         "id-" + mlsNumber,
+		MlsNumber:     mlsNumber,
+		MlsUrl:        "/listing/" + mlsNumber,
+		Parking:       []string{"Attached Garage", "Gravel"},
+		PhotoUrl:      []string{"https://photo/" + mlsNumber + "-1.jpg", "https://photo/" + mlsNumber + "-2.jpg"},
+		PublicRemarks: "Renovated bungalow",
+		Stories:       "1",
+		PropertyType:  "House",
+		ListTimestamp: 50,
+		Latitude:      42.3149,
+		Longitude:     -83.0364,
+		City:          "Windsor",
+		State:         "Ontario",
+		Zipcode:       "N9A1A1",
+		Source:        "mls-canada",
+		LocalizedText: map[string]*mlspb.LocalizedText{
+			"fr": {PublicRemarks: "Bungalow rénové", PropertyType: "Maison"},
+		},
+	}
+	for i, price := range prices {
+		p.Price = append(p.Price, &mlspb.PriceHistory{Price: price, Timestamp: int64(100 * (i + 1))})
+	}
+	return p
+}
+
+// saved returns the listing as the backends read it back once saved.
+func saved(p *mlspb.Property) *mlspb.Property {
+	s := proto.Clone(p).(*mlspb.Property)
+	s.Status = "Open"
+	s.StatusHistory = []*mlspb.StatusHistory{{Status: "Open", Timestamp: p.ListTimestamp}}
+	return s
+}
+
+func save(t *testing.T, db storage.DBInterface, listings ...*mlspb.Property) {
+	t.Helper()
+	for _, p := range listings {
+		if err := db.SaveNewListing(context.Background(), p); err != nil {
+			t.Fatalf("Failed to save listing %s: %v", p.MlsNumber, err)
+		}
+	}
+}
+
+func read(t *testing.T, db storage.DBInterface, mlsNumber string) *mlspb.Property {
+	t.Helper()
+	p, err := db.ReadListing(context.Background(), mlsNumber)
+	if err != nil {
+		t.Fatalf("Failed to read listing %s: %v", mlsNumber, err)
+	}
+	return p
+}
+
+func mlsNumbers(listings *mlspb.Listings) string {
+	numbers := []string{}
+	for _, p := range listings.Property {
+		numbers = append(numbers, p.MlsNumber)
+	}
+	return strings.Join(numbers, ",")
+}
+
+func prices(p *mlspb.Property) string {
+	values := []string{}
+	for _, pr := range p.Price {
+		values = append(values, fmt.Sprintf("%d@%d", pr.Price, pr.Timestamp))
+	}
+	return strings.Join(values, ",")
+}
+
+func statuses(p *mlspb.Property) string {
+	values := []string{}
+	for _, s := range p.StatusHistory {
+		values = append(values, s.Status)
+	}
+	return strings.Join(values, ",")
+}
+
+func testSaveListing(t *testing.T, db storage.DBInterface) {
+	p := listing("1", 300000, 290000)
+	save(t, db, p)
+	if got, want := read(t, db, "1"), saved(p); !proto.Equal(got, want) {
+		t.Errorf("got listing\n%v\nwant\n%v", got, want)
+	}
+
+	bare := &mlspb.Property{MlsNumber: "2", Address: "2 street|Windsor", ListTimestamp: 50}
+	save(t, db, bare)
+	if got, want := read(t, db, "2"), saved(bare); !proto.Equal(got, want) {
+		t.Errorf("got listing\n%v\nwant\n%v", got, want)
+	}
+}
+
+func testSameAddress(t *testing.T, db storage.DBInterface) {
+	// A home relisted under a new MLS number keeps its address.
+	relisted := listing("2", 280000)
+	relisted.Address = listing("1").Address
+	save(t, db, listing("1", 300000), relisted)
+	if p := read(t, db, "2"); p.Address != relisted.Address || prices(p) != "280000@100" {
+		t.Errorf("unexpected relisted listing %v", p)
+	}
+}
+
+func testSaveErrors(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	save(t, db, listing("1", 300000))
+	if err := db.SaveNewListing(ctx, listing("1", 290000)); !errors.Is(err, storage.ErrListingExists) {
+		t.Errorf("expected ErrListingExists, got %v", err)
+	}
+	if p := read(t, db, "1"); prices(p) != "300000@100" {
+		t.Errorf("expected the saved listing not to change, got prices %s", prices(p))
+	}
+
+	if err := db.SaveNewListing(ctx, listing("")); !errors.Is(err, storage.ErrInvalidListing) {
+		t.Errorf("expected ErrInvalidListing, got %v", err)
+	}
+	if outcome, err := db.SaveOrUpdateListing(ctx, listing("")); outcome != storage.Failed || !errors.Is(err, storage.ErrInvalidListing) {
+		t.Errorf("expected ErrInvalidListing, got %s, %v", outcome, err)
+	}
+	results, err := db.SaveListings(ctx, []*mlspb.Property{listing("")})
+	if err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+	if results[0].Outcome != storage.Failed || !errors.Is(results[0].Err, storage.ErrInvalidListing) {
+		t.Errorf("expected ErrInvalidListing, got %v", results[0])
+	}
+
+	if _, err := db.ReadListing(ctx, "missing"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := db.UpdateListing(ctx, listing("missing", 1)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := db.SaveListingTags(ctx, "missing", []string{"garage"}); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := db.MarkListingSeen(ctx, "missing", 100); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := db.SavePhotoHash(ctx, "https://photo/missing.jpg", 1); err == nil {
+		t.Error("expected the hash of a missing photo to fail")
+	}
+}
+
+func testUpdateListing(t *testing.T, db storage.DBInterface) {
+	save(t, db, listing("1", 300000))
+	update := listing("1")
+	update.Price = []*mlspb.PriceHistory{{Price: 290000, Timestamp: 200}}
+	update.LocalizedText = map[string]*mlspb.LocalizedText{
+		"fr": {PublicRemarks: "Prix réduit", PropertyType: "Maison"},
+		"en": {PublicRemarks: "Price reduced", PropertyType: "House"},
+	}
+	if err := db.UpdateListing(context.Background(), update); err != nil {
+		t.Fatalf("Failed to update the listing: %v", err)
+	}
+
+	p := read(t, db, "1")
+	if prices(p) != "300000@100,290000@200" {
+		t.Errorf("got prices %s", prices(p))
+	}
+	if len(p.LocalizedText) != 2 || p.LocalizedText["fr"].PublicRemarks != "Prix réduit" || p.LocalizedText["en"].PublicRemarks != "Price reduced" {
+		t.Errorf("unexpected localized text %v", p.LocalizedText)
+	}
+}
+
+func testSaveOrUpdateListing(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	for _, step := range []struct {
+		listing *mlspb.Property
+		want    storage.SaveOutcome
+	}{
+		{listing("1", 300000), storage.Created},
+		{listing("1", 300000), storage.Unchanged},
+		{listing("1", 300000, 290000), storage.Updated},
+		{listing("1", 290000), storage.Unchanged},
+	} {
+		if outcome, err := db.SaveOrUpdateListing(ctx, step.listing); err != nil || outcome != step.want {
+			t.Errorf("got %s, %v, want %s", outcome, err, step.want)
+		}
+	}
+	if p := read(t, db, "1"); prices(p) != "300000@100,290000@200" {
+		t.Errorf("got prices %s", prices(p))
+	}
+}
+
+func testSaveListings(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	outcomes := func(results []*storage.SaveResult) string {
+		o := []string{}
+		for _, r := range results {
+			o = append(o, r.Outcome.String())
+		}
+		return strings.Join(o, ",")
+	}
+
+	results, err := db.SaveListings(ctx, []*mlspb.Property{listing("1", 300000), listing("2", 400000), listing("1", 300000, 290000)})
+	if err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+	if got := outcomes(results); got != "created,created,updated" {
+		t.Errorf("got outcomes %s", got)
+	}
+	results, err = db.SaveListings(ctx, []*mlspb.Property{listing("2", 400000), listing("3")})
+	if err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+	if got := outcomes(results); got != "unchanged,created" {
+		t.Errorf("got outcomes %s", got)
+	}
+	if results[1].MlsNumber != "3" {
+		t.Errorf("unexpected result %v", results[1])
+	}
+	if results, err := db.SaveListings(ctx, nil); err != nil || len(results) != 0 {
+		t.Errorf("expected no result, got %v, %v", results, err)
+	}
+}
+
+func testLatestPrice(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	// The prices are read in time order, whatever order they were saved in.
+	p := listing("1")
+	p.Price = []*mlspb.PriceHistory{{Price: 290000, Timestamp: 200}, {Price: 300000, Timestamp: 100}}
+	save(t, db, p, listing("2"))
+
+	if got := prices(read(t, db, "1")); got != "300000@100,290000@200" {
+		t.Errorf("got prices %s", got)
+	}
+	if price, found, err := db.ReadLatestPrice(ctx, "1"); err != nil || !found || price != 290000 {
+		t.Errorf("got latest price %d, %t, %v, want 290000", price, found, err)
+	}
+	if price, found, err := db.ReadLatestPrice(ctx, "2"); err != nil || !found || price != 0 {
+		t.Errorf("got latest price %d, %t, %v of a listing without price", price, found, err)
+	}
+	if _, found, err := db.ReadLatestPrice(ctx, "missing"); err != nil || found {
+		t.Errorf("expected a missing listing not to be found, got %t, %v", found, err)
+	}
+}
+
+func testReadListings(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	save(t, db, listing("3"), listing("1"), listing("2"))
+	db.MarkListingSeen(ctx, "1", 200)
+	db.MarkListingSeen(ctx, "2", 200)
+	if _, err := db.DelistUnseen(ctx, "mls-canada", 100); err != nil {
+		t.Fatalf("Failed to delist the listings: %v", err)
+	}
+
+	// Every listing is read in MLS number order, whatever its status.
+	listings, err := db.ReadListings(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read the listings: %v", err)
+	}
+	if got := mlsNumbers(listings); got != "1,2,3" {
+		t.Errorf("got listings %s", got)
+	}
+	if got := listings.Property[2].Status; got != "Delisted" {
+		t.Errorf("got status %q, want Delisted", got)
+	}
+}
+
+func testIterateListings(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	save(t, db, listing("3", 300000), listing("1", 100000), listing("2", 200000))
+	it, err := db.IterateListings(ctx)
+	if err != nil {
+		t.Fatalf("Failed to iterate the listings: %v", err)
+	}
+	defer it.Close()
+
+	listings := &mlspb.Listings{}
+	for it.Next() {
+		listings.Property = append(listings.Property, it.Listing())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Failed to iterate the listings: %v", err)
+	}
+	if got := mlsNumbers(listings); got != "1,2,3" {
+		t.Errorf("got listings %s", got)
+	}
+	if got := prices(listings.Property[1]); got != "200000@100" {
+		t.Errorf("got prices %s", got)
+	}
+}
+
+func testQueryListings(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	toronto := listing("4", 150000)
+	toronto.City, toronto.Zipcode = "Toronto", "M5V1A1"
+	save(t, db, listing("1", 300000), listing("2", 100000, 90000), listing("3", 200000), toronto)
+
+	q := storage.ListingQuery{City: "windsor", Sort: storage.SortByPrice, Limit: 2}
+	page, err := db.QueryListings(ctx, q)
+	if err != nil {
+		t.Fatalf("Failed to query the listings: %v", err)
+	}
+	if got := mlsNumbers(page.Listings); got != "2,3" || page.NextCursor == "" {
+		t.Errorf("got first page %s, cursor %q", got, page.NextCursor)
+	}
+	q.Cursor = page.NextCursor
+	if page, err = db.QueryListings(ctx, q); err != nil {
+		t.Fatalf("Failed to query the listings: %v", err)
+	}
+	if got := mlsNumbers(page.Listings); got != "1" || page.NextCursor != "" {
+		t.Errorf("got last page %s, cursor %q", got, page.NextCursor)
+	}
+
+	page, err = db.QueryListings(ctx, storage.ListingQuery{Sort: storage.SortByPriceChange, MaxPrice: 200000})
+	if err != nil {
+		t.Fatalf("Failed to query the listings: %v", err)
+	}
+	if got := mlsNumbers(page.Listings); got != "2,3,4" {
+		t.Errorf("got listings %s", got)
+	}
+	if _, err := db.QueryListings(ctx, storage.ListingQuery{Cursor: "not a cursor"}); err == nil {
+		t.Error("expected an invalid cursor to fail")
+	}
+}
+
+func testTags(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	save(t, db, listing("1"), listing("2"), listing("3"))
+	for mlsNumber, tags := range map[string][]string{
+		"1": {"pool", "garage", "pool"},
+		"2": {"garage"},
+	} {
+		if err := db.SaveListingTags(ctx, mlsNumber, tags); err != nil {
+			t.Fatalf("Failed to tag listing %s: %v", mlsNumber, err)
+		}
+	}
+	// Saving the tags replaces the previous ones.
+	if err := db.SaveListingTags(ctx, "3", []string{"pool"}); err != nil {
+		t.Fatalf("Failed to tag listing 3: %v", err)
+	}
+	if err := db.SaveListingTags(ctx, "3", []string{"renovated"}); err != nil {
+		t.Fatalf("Failed to tag listing 3: %v", err)
+	}
+
+	if got := strings.Join(read(t, db, "1").Tags, ","); got != "garage,pool" {
+		t.Errorf("got tags %s", got)
+	}
+	for tags, want := range map[string]string{
+		"garage":        "1,2",
+		"garage,pool":   "1",
+		"garage,garage": "1,2",
+		"renovated":     "3",
+		"basement":      "",
+	} {
+		listings, err := db.ReadListingsByTags(ctx, strings.Split(tags, ","))
+		if err != nil {
+			t.Fatalf("Failed to read the listings tagged %s: %v", tags, err)
+		}
+		sort.Slice(listings.Property, func(i, j int) bool { return listings.Property[i].MlsNumber < listings.Property[j].MlsNumber })
+		if got := mlsNumbers(listings); got != want {
+			t.Errorf("got listings %q tagged %s, want %q", got, tags, want)
+		}
+	}
+}
+
+func testCollectedTags(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	tagged := func(mlsNumber string, tags ...string) *mlspb.Property {
+		p := listing(mlsNumber)
+		p.Tags = tags
+		return p
+	}
+	save(t, db, tagged("new", "pool"))
+	if _, err := db.SaveListings(ctx, []*mlspb.Property{tagged("batch", "garage")}); err != nil {
+		t.Fatalf("Failed to save the listings: %v", err)
+	}
+	if _, err := db.SaveOrUpdateListing(ctx, tagged("single", "waterfront")); err != nil {
+		t.Fatalf("Failed to save the listing: %v", err)
+	}
+	// An update with tags replaces them, an update without keeps them.
+	if err := db.UpdateListing(ctx, tagged("new", "renovated")); err != nil {
+		t.Fatalf("Failed to update the listing: %v", err)
+	}
+	if _, err := db.SaveOrUpdateListing(ctx, tagged("single")); err != nil {
+		t.Fatalf("Failed to update the listing: %v", err)
+	}
+	if _, err := db.SaveListings(ctx, []*mlspb.Property{tagged("batch", "garage", "pool")}); err != nil {
+		t.Fatalf("Failed to update the listings: %v", err)
+	}
+
+	for mlsNumber, want := range map[string]string{"new": "renovated", "batch": "garage,pool", "single": "waterfront"} {
+		if got := strings.Join(read(t, db, mlsNumber).Tags, ","); got != want {
+			t.Errorf("got tags %q of listing %s, want %q", got, mlsNumber, want)
+		}
+	}
+}
+
+func testPhotoHashes(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	save(t, db, listing("1"), listing("2"), listing("3"), listing("4"))
+	for url, hash := range map[string]uint64{
+		"https://photo/1-1.jpg": 0xF0F0F0F0F0F0F0F0,
+		"https://photo/1-2.jpg": 42,
+		"https://photo/2-2.jpg": 0xF0F0F0F0F0F0F0F0,
+		// The same picture re-encoded.
+		"https://photo/3-1.jpg": 0xF0F0F0F0F0F0F0F3,
+		"https://photo/4-1.jpg": 0xF0F0F0F0F0F0F00F,
+		"https://photo/4-2.jpg": 43,
+	} {
+		if err := db.SavePhotoHash(ctx, url, hash); err != nil {
+			t.Fatalf("Failed to save the hash of %s: %v", url, err)
+		}
+	}
+
+	duplicates, err := db.FindDuplicatePhotos(ctx)
+	if err != nil {
+		t.Fatalf("Failed to find the duplicate photos: %v", err)
+	}
+	if len(duplicates) != 2 || duplicates[0].Hash != 42 || strings.Join(duplicates[0].MlsNumbers, ",") != "1,4" ||
+		duplicates[1].Hash != 0xF0F0F0F0F0F0F0F0 || strings.Join(duplicates[1].MlsNumbers, ",") != "1,2,3" {
+		t.Errorf("unexpected duplicates %+v", duplicates)
+	}
+}
+
+func testStatusTransitions(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	other := listing("other")
+	other.Source = "reso"
+	save(t, db, listing("seen"), listing("unseen"), listing("never"), other)
+	db.MarkListingSeen(ctx, "seen", 200)
+	db.MarkListingSeen(ctx, "unseen", 50)
+
+	delisted, err := db.DelistUnseen(ctx, "mls-canada", 100)
+	if err != nil || delisted != 2 {
+		t.Errorf("expected 2 delisted listings, got %d, %v", delisted, err)
+	}
+	for mlsNumber, want := range map[string]string{"seen": "Open", "unseen": "Delisted", "never": "Delisted", "other": "Open"} {
+		if got := read(t, db, mlsNumber).Status; got != want {
+			t.Errorf("listing %s has status %q, want %q", mlsNumber, got, want)
+		}
+	}
+	// A delisted listing is not delisted again.
+	if delisted, err := db.DelistUnseen(ctx, "mls-canada", 100); err != nil || delisted != 0 {
+		t.Errorf("expected no delisted listing, got %d, %v", delisted, err)
+	}
+
+	if err := db.MarkListingSeen(ctx, "unseen", 300); err != nil {
+		t.Fatalf("Failed to mark the listing seen: %v", err)
+	}
+	p := read(t, db, "unseen")
+	if p.Status != "Open" || statuses(p) != "Open,Delisted,Open" {
+		t.Errorf("expected the listing to be open again, got %s with history %s", p.Status, statuses(p))
+	}
+	if last := p.StatusHistory[2]; last.Timestamp != 300 {
+		t.Errorf("got reopened timestamp %d, want 300", last.Timestamp)
+	}
+	// Seeing an open listing does not change its status.
+	db.MarkListingSeen(ctx, "seen", 400)
+	if got := statuses(read(t, db, "seen")); got != "Open" {
+		t.Errorf("got status history %s", got)
+	}
+}
+
+func testCollectedStatus(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	pending := listing("1", 300000)
+	pending.Status = "Pending"
+	save(t, db, pending)
+	if p := read(t, db, "1"); p.Status != "Pending" || statuses(p) != "Pending" {
+		t.Errorf("expected a pending listing, got %s with history %s", p.Status, statuses(p))
+	}
+
+	sold := listing("1", 300000)
+	sold.Status = "Sold"
+	if outcome, err := db.SaveOrUpdateListing(ctx, sold); outcome != storage.Updated || err != nil {
+		t.Errorf("expected the status change to update the listing, got %s, %v", outcome, err)
+	}
+	// A listing collected without a status keeps the saved one.
+	if outcome, err := db.SaveOrUpdateListing(ctx, listing("1", 300000)); outcome != storage.Unchanged || err != nil {
+		t.Errorf("expected an unchanged listing, got %s, %v", outcome, err)
+	}
+	if outcome, err := db.SaveOrUpdateListing(ctx, sold); outcome != storage.Unchanged || err != nil {
+		t.Errorf("expected an unchanged listing, got %s, %v", outcome, err)
+	}
+	if p := read(t, db, "1"); p.Status != "Sold" || statuses(p) != "Pending,Sold" {
+		t.Errorf("expected a sold listing, got %s with history %s", p.Status, statuses(p))
+	}
+
+	unknown := listing("1", 290000)
+	unknown.Status = "Bogus"
+	if outcome, err := db.SaveOrUpdateListing(ctx, unknown); outcome != storage.Failed || !errors.Is(err, storage.ErrInvalidListing) {
+		t.Errorf("expected ErrInvalidListing, got %s, %v", outcome, err)
+	}
+	if err := db.UpdateListing(ctx, unknown); !errors.Is(err, storage.ErrInvalidListing) {
+		t.Errorf("expected ErrInvalidListing, got %v", err)
+	}
+	if p := read(t, db, "1"); prices(p) != "300000@100" || p.Status != "Sold" {
+		t.Errorf("expected the invalid listing not to change the saved one, got %s %s", p.Status, prices(p))
+	}
+	unknown.MlsNumber = "2"
+	if err := db.SaveNewListing(ctx, unknown); !errors.Is(err, storage.ErrInvalidListing) {
+		t.Errorf("expected ErrInvalidListing, got %v", err)
+	}
+}
+
+func testCheckpoints(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	if c, err := db.ReadCheckpoint(ctx, "mls-canada"); err != nil || c != nil {
+		t.Errorf("expected no checkpoint, got %v, %v", c, err)
+	}
+	c := &storage.Checkpoint{RunID: "1", Source: "mls-canada", Region: "windsor", Tile: 2, Page: 3, StartedAt: 100}
+	if err := db.SaveCheckpoint(ctx, c); err != nil {
+		t.Fatalf("Failed to save the checkpoint: %v", err)
+	}
+	c.Page, c.Covered = 4, true
+	if err := db.SaveCheckpoint(ctx, c); err != nil {
+		t.Fatalf("Failed to save the checkpoint: %v", err)
+	}
+	if got, err := db.ReadCheckpoint(ctx, "mls-canada"); err != nil || got == nil || *got != *c {
+		t.Errorf("got checkpoint %v, %v, want %v", got, err, c)
+	}
+	if err := db.DeleteCheckpoint(ctx, "mls-canada"); err != nil {
+		t.Fatalf("Failed to delete the checkpoint: %v", err)
+	}
+	if c, err := db.ReadCheckpoint(ctx, "mls-canada"); err != nil || c != nil {
+		t.Errorf("expected the checkpoint to be deleted, got %v, %v", c, err)
+	}
+}
+
+func testCollectionRuns(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		r := &mlspb.CollectionRun{
+			RunId:       fmt.Sprintf("run-%d", i),
+			Source:      "mls-canada",
+			Region:      []string{"windsor", "toronto"},
+			StartedAt:   int64(100 * i),
+			NewListings: int32(i),
+		}
+		if err := db.SaveCollectionRun(ctx, r); err != nil {
+			t.Fatalf("Failed to save the collection run: %v", err)
+		}
+	}
+	// A run is saved again once it finishes.
+	finished := &mlspb.CollectionRun{RunId: "run-3", Source: "mls-canada", StartedAt: 300, EndedAt: 400, Error: "stopped"}
+	if err := db.SaveCollectionRun(ctx, finished); err != nil {
+		t.Fatalf("Failed to save the collection run: %v", err)
+	}
+
+	runs, err := db.ReadCollectionRuns(ctx, 2)
+	if err != nil {
+		t.Fatalf("Failed to read the collection runs: %v", err)
+	}
+	if len(runs.Run) != 2 || !proto.Equal(runs.Run[0], finished) || runs.Run[1].RunId != "run-2" {
+		t.Errorf("unexpected runs %v", runs.Run)
+	}
+	if got := strings.Join(runs.Run[1].Region, ","); got != "windsor,toronto" {
+		t.Errorf("got regions %s", got)
+	}
+	if runs, err := db.ReadCollectionRuns(ctx, 0); err != nil || len(runs.Run) != 3 {
+		t.Errorf("expected every run without a limit, got %v, %v", runs, err)
+	}
+}
+
+func testConcurrency(t *testing.T, db storage.DBInterface) {
+	ctx := context.Background()
+	const workers, listings = 8, 20
+	var wg sync.WaitGroup
+	created := make([]int, listings)
+	var lock sync.Mutex
+	errs := make(chan error, workers*listings*2)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// Every worker saves every listing, with its own price.
+			for i := 0; i < listings; i++ {
+				p := listing(fmt.Sprintf("%02d", i))
+				p.Price = []*mlspb.PriceHistory{{Price: int32(100000 + w), Timestamp: int64(100 + w)}}
+				outcome, err := db.SaveOrUpdateListing(ctx, p)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if outcome == storage.Created {
+					lock.Lock()
+					created[i]++
+					lock.Unlock()
+				}
+				if _, err := db.ReadListing(ctx, p.MlsNumber); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Failed to save concurrently: %v", err)
+	}
+
+	for i, n := range created {
+		if n != 1 {
+			t.Errorf("listing %02d was created %d times", i, n)
+		}
+	}
+	all, err := db.ReadListings(ctx)
+	if err != nil {
+		t.Fatalf("Failed to read the listings: %v", err)
+	}
+	if len(all.Property) != listings {
+		t.Errorf("got %d listings, want %d", len(all.Property), listings)
+	}
+	for _, p := range all.Property {
+		if len(p.Price) != workers {
+			t.Errorf("listing %s has %d prices, want %d", p.MlsNumber, len(p.Price), workers)
+		}
+	}
+}
+
+func testCancelledContext(t *testing.T, db storage.DBInterface) {
+	save(t, db, listing("1"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := db.ReadListings(ctx); err == nil {
+		t.Error("expected ReadListings to fail")
+	}
+	if _, err := db.QueryListings(ctx, storage.ListingQuery{}); err == nil {
+		t.Error("expected QueryListings to fail")
+	}
+	if _, err := db.SaveListings(ctx, []*mlspb.Property{listing("2")}); err == nil {
+		t.Error("expected SaveListings to fail")
+	}
+	if _, err := db.DelistUnseen(ctx, "mls-canada", 100); err == nil {
+		t.Error("expected DelistUnseen to fail")
+	}
+	if p := read(t, db, "1"); p.Status != "Open" {
+		t.Errorf("expected the listing to stay open, got %s", p.Status)
+	}
+	if _, err := db.ReadListing(context.Background(), "2"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the cancelled save not to save, got %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+// package tagger extracts feature tags such as "pool" or "renovated" from the
+// free text public remarks of a listing.
+package tagger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// negationWindow is the number of words before a phrase searched for a
+	// negation, ie. "no attached garage".
+	negationWindow = 3
+)
+
+var (
+	// DefaultRules is the phrase dictionary used when no rule file is given.
+	DefaultRules = []Rule{
+		{Tag: "renovated", Phrases: []string{"renovated", "updated", "remodeled", "remodelled", "new kitchen", "new roof"}},
+		{Tag: "as-is", Phrases: []string{"as-is", "sold as is", "as is where is", "in as is condition"}},
+		{Tag: "handyman special", Phrases: []string{"handyman special", "handyman", "fixer upper", "needs work", "tlc"}},
+		{Tag: "in-law suite", Phrases: []string{"in law suite", "in law", "granny suite", "secondary suite", "basement apartment"}},
+		{Tag: "pool", Phrases: []string{"pool", "inground pool", "above ground pool", "swimming pool"}},
+		{Tag: "garage", Phrases: []string{"garage", "attached garage", "detached garage", "double garage"}},
+		{Tag: "waterfront", Phrases: []string{"waterfront", "water front", "lakefront", "riverfront", "on the water"}},
+	}
+
+	// DefaultNegations are the words that cancel a phrase following them.
+	DefaultNegations = []string{"no", "not", "without", "non", "never", "lacks"}
+)
+
+// Rule maps a tag to the phrases in the remarks that mention it. A phrase
+// with a hyphen only matches hyphenated words, ie. "as-is" does not match
+// "such as is", while "in law" matches both "in law" and "in-law".
+type Rule struct {
+	Tag     string   `json:"tag"`
+	Phrases []string `json:"phrases"`
+}
+
+// Config is the format of a rule file loaded by Load.
+type Config struct {
+	Rules     []Rule   `json:"rules"`
+	Negations []string `json:"negations"`
+}
+
+type phrase struct {
+	tag   string
+	words []word
+}
+
+// word is a word of the remarks, hyphenated when a hyphen joins it to the
+// previous one.
+type word struct {
+	text       string
+	hyphenated bool
+}
+
+// Tagger tags listings from their remarks using a phrase dictionary.
+type Tagger struct {
+	phrases   []phrase
+	negations map[string]bool
+}
+
+// New creates a tagger from the given rules and the default negation words.
+func New(rules []Rule) *Tagger {
+	return NewWithNegations(rules, DefaultNegations)
+}
+
+// NewWithNegations creates a tagger from the given rules and negation words.
+func NewWithNegations(rules []Rule, negations []string) *Tagger {
+	t := &Tagger{negations: make(map[string]bool)}
+	for _, n := range negations {
+		t.negations[strings.ToLower(strings.TrimSpace(n))] = true
+	}
+	for _, r := range rules {
+		for _, p := range r.Phrases {
+			words := tokenize(p)
+			if len(words) == 0 {
+				continue
+			}
+			t.phrases = append(t.phrases, phrase{tag: r.Tag, words: words})
+		}
+	}
+	return t
+}
+
+// Load creates a tagger from a JSON rule file. Negations default to
+// DefaultNegations when the file does not list any.
+func Load(path string) (*Tagger, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tagger rules %q: %v", path, err)
+	}
+
+	var c Config
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse tagger rules %q: %v", path, err)
+	}
+	if len(c.Negations) == 0 {
+		c.Negations = DefaultNegations
+	}
+	return NewWithNegations(c.Rules, c.Negations), nil
+}
+
+// Tags returns the sorted tags mentioned by the remarks. A phrase preceded by
+// a negation in the same clause, ie. "no garage", does not tag the listing.
+func (t *Tagger) Tags(remarks string) []string {
+	clauses := splitClauses(remarks)
+
+	found := make(map[string]bool)
+	for _, words := range clauses {
+		for _, p := range t.phrases {
+			if found[p.tag] {
+				continue
+			}
+			for i := 0; i+len(p.words) <= len(words); i++ {
+				if matchAt(words, i, p.words) && !t.negated(words, i) {
+					found[p.tag] = true
+					break
+				}
+			}
+		}
+	}
+
+	tags := []string{}
+	for tag := range found {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func (t *Tagger) negated(words []word, i int) bool {
+	start := i - negationWindow
+	if start < 0 {
+		start = 0
+	}
+	for k := i - 1; k >= start; k-- {
+		if t.negations[words[k].text] {
+			return true
+		}
+		// A negation carries over a list, ie. "no garage or pool".
+		if (words[k].text == "or" || words[k].text == "nor") && k > 0 {
+			return t.negated(words, k-1)
+		}
+	}
+	return false
+}
+
+func matchAt(words []word, i int, phrase []word) bool {
+	for j, w := range phrase {
+		if words[i+j].text != w.text || (j > 0 && w.hyphenated && !words[i+j].hyphenated) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitClauses breaks the remarks on punctuation so a negation only applies to
+// the clause it appears in.
+func splitClauses(text string) [][]word {
+	clauses := strings.FieldsFunc(text, func(r rune) bool {
+		return strings.ContainsRune(".,;:!?()\n", r)
+	})
+
+	result := [][]word{}
+	for _, c := range clauses {
+		if words := tokenize(c); len(words) > 0 {
+			result = append(result, words)
+		}
+	}
+	return result
+}
+
+// tokenize lower cases the text and splits it into words, treating hyphens and
+// other symbols as separators so "in-law" matches "in law". The words joined by
+// a hyphen are marked hyphenated.
+func tokenize(text string) []word {
+	words := []word{}
+	current, hyphenated := []rune{}, false
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, word{text: string(current), hyphenated: hyphenated})
+			current = current[:0]
+		}
+		hyphenated = false
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'':
+			current = append(current, r)
+		case r == '-' && len(current) > 0:
+			flush()
+			hyphenated = true
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words
+}
@@ -0,0 +1,111 @@
+package tagger
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func assertTags(t *testing.T, got, want []string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got tags '%v', want '%v'", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("got tags '%v', want '%v'", got, want)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	tagger := New(DefaultRules)
+
+	tests := []struct {
+		name    string
+		remarks string
+		want    []string
+	}{
+		{
+			name:    "tag matching phrases",
+			remarks: "Fully RENOVATED bungalow with an inground pool and a double garage.",
+			want:    []string{"garage", "pool", "renovated"},
+		},
+		{
+			name:    "match hyphenated phrases",
+			remarks: "Sold as-is. Separate entrance to the in-law suite!",
+			want:    []string{"as-is", "in-law suite"},
+		},
+		{
+			name:    "match the as-is phrases only",
+			remarks: "Amenities such as is expected in the area. Estate sale, sold as is",
+			want:    []string{"as-is"},
+		},
+		{
+			name:    "skip as is within a sentence",
+			remarks: "Close to schools such as is rare downtown",
+			want:    []string{},
+		},
+		{
+			name:    "skip negated phrases",
+			remarks: "No garage, but the backyard has a pool.",
+			want:    []string{"pool"},
+		},
+		{
+			name:    "skip phrases negated a few words before",
+			remarks: "Home does not have a garage or waterfront access",
+			want:    []string{},
+		},
+		{
+			name:    "negation does not cross clauses",
+			remarks: "Not a handyman special. Waterfront lot with a garage",
+			want:    []string{"garage", "waterfront"},
+		},
+		{
+			name:    "no remarks",
+			remarks: "",
+			want:    []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertTags(t, tagger.Tags(tt.remarks), tt.want)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("load rules from a file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "tagger")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "rules.json")
+		rules := `{
+		  "rules": [
+		    {"tag": "fireplace", "phrases": ["fireplace", "wood stove"]}
+		  ],
+		  "negations": ["sans"]
+		}`
+		if err := ioutil.WriteFile(path, []byte(rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		tagger, err := Load(path)
+		if err != nil {
+			t.Fatalf("failed to load rules: %v", err)
+		}
+		assertTags(t, tagger.Tags("Cozy wood stove and a pool"), []string{"fireplace"})
+		assertTags(t, tagger.Tags("Sans fireplace"), []string{})
+	})
+
+	t.Run("reject a missing file", func(t *testing.T) {
+		if _, err := Load("/nonexistent/rules.json"); err == nil {
+			t.Error("expected an error loading a missing rule file")
+		}
+	})
+}
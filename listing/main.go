@@ -1,12 +1,28 @@
-// web is the server entry point to the MLS data collected by the Collectors.
+// listing is the server entry point to the MLS data collected by the Collectors.
 package main
 
 import (
+	"flag"
+
 	"github.com/sirupsen/logrus"
-	"github.com/tony-yang/realtor-tracker/web/server"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+	"github.com/tony-yang/realtor-tracker/listing/server"
+)
+
+var (
+	dbPath = flag.String("db_path", "/tmp/realtor_prod.db", "The sqlite DB the collectors save to")
+	// dbBackend picks the DB the listings are served from, the sqlite DB of
+	// db_path or the postgres DB of db_dsn.
+	dbBackend = flag.String("db_backend", storage.SqliteBackend, "The storage backend, sqlite or postgres")
+	dbDSN     = flag.String("db_dsn", "", "The DSN of the postgres DB the collectors save to")
 )
 
 func main() {
+	flag.Parse()
 	logrus.Info("Web Server Main")
-	server.StartServer()
+	db, err := storage.OpenBackend(*dbBackend, *dbPath, *dbDSN)
+	if err != nil {
+		logrus.Fatalf("Failed to open the %s DB: %v", *dbBackend, err)
+	}
+	server.StartServer(db)
 }
@@ -1,46 +1,77 @@
-// Package main implements a gRPC indexer server that will serve the collected
+// Package server implements a gRPC indexer server that will serve the collected
 // data to the client in a standardized format defined by the proto.
 package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 
 	"github.com/sirupsen/logrus"
-	"github.com/tony-yang/realtor-tracker/indexer/collector"
 	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var (
 	port = 9000
 )
 
-type indexerServer struct{}
+type indexerServer struct {
+	db storage.DBInterface
+}
 
 func (s *indexerServer) GetListing(ctx context.Context, r *mlspb.Request) (*mlspb.Listings, error) {
-	listings := &mlspb.Listings{}
-
-	for name, c := range collector.Collectors {
-		logrus.Infof("Read from the '%s' collector", name)
-		result, err := c.GetDB().ReadListings()
-		if err != nil {
-			logrus.Errorf("reading property listing failed: %v", err)
-		}
-		logrus.Debug(result.String())
-		listings.Property = append(listings.Property, result.Property...)
+	page, err := s.db.QueryListings(ctx, storage.ListingQuery{Status: "Open", Limit: 10})
+	if err != nil {
+		logrus.Errorf("reading property listing failed: %v", err)
+		return nil, readError(ctx, "failed to read the open listings")
+	}
+	logrus.Debug(page.Listings.String())
+	return page.Listings, nil
+}
+
+func (s *indexerServer) GetListingByMlsNumber(ctx context.Context, r *mlspb.ListingRequest) (*mlspb.Property, error) {
+	p, err := s.db.ReadListing(ctx, r.MlsNumber)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, status.Errorf(codes.NotFound, "listing %s not found", r.MlsNumber)
+	}
+	if err != nil {
+		logrus.Errorf("reading listing %s failed: %v", r.MlsNumber, err)
+		return nil, status.Errorf(codes.Internal, "failed to read listing %s", r.MlsNumber)
+	}
+	return p, nil
+}
+
+func (s *indexerServer) GetCollectionRuns(ctx context.Context, r *mlspb.CollectionRunsRequest) (*mlspb.CollectionRuns, error) {
+	runs, err := s.db.ReadCollectionRuns(ctx, int(r.Limit))
+	if err != nil {
+		logrus.Errorf("reading collection runs failed: %v", err)
+		return nil, readError(ctx, "failed to read the collection runs")
+	}
+	return runs, nil
+}
+
+// readError is the gRPC error of a failed read, the context error when the
+// request was cancelled or timed out.
+func readError(ctx context.Context, format string, args ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return status.FromContextError(err).Err()
 	}
-	return listings, nil
+	return status.Errorf(codes.Internal, format, args...)
 }
 
-func newServer() *indexerServer {
-	s := &indexerServer{}
+func newServer(db storage.DBInterface) *indexerServer {
+	s := &indexerServer{db: db}
 	return s
 }
 
-func StartServer() {
+// StartServer serves the listings of db.
+func StartServer(db storage.DBInterface) {
 	logrus.Info("Starting the Server")
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
@@ -48,6 +79,6 @@ func StartServer() {
 	}
 
 	grpcServer := grpc.NewServer()
-	mlspb.RegisterMlsServiceServer(grpcServer, newServer())
+	mlspb.RegisterMlsServiceServer(grpcServer, newServer(db))
 	grpcServer.Serve(lis)
 }
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/indexer/storage"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// failingDB fails every read of the listings and collection runs.
+type failingDB struct {
+	*storage.MemoryDB
+}
+
+func (d failingDB) QueryListings(ctx context.Context, q storage.ListingQuery) (*storage.ListingPage, error) {
+	return nil, errors.New("disk I/O error")
+}
+
+func (d failingDB) ReadCollectionRuns(ctx context.Context, limit int) (*mlspb.CollectionRuns, error) {
+	return nil, errors.New("disk I/O error")
+}
+
+func TestGetListing(t *testing.T) {
+	mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+	s := newServer(failingDB{mDB})
+
+	t.Run("return an internal error when the listings cannot be read", func(t *testing.T) {
+		listings, err := s.GetListing(context.Background(), &mlspb.Request{})
+		if listings != nil || status.Code(err) != codes.Internal {
+			t.Errorf("got %v, %v, want an internal error", listings, err)
+		}
+	})
+
+	t.Run("return the context error of a timed out request", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		if _, err := s.GetListing(ctx, &mlspb.Request{}); status.Code(err) != codes.DeadlineExceeded {
+			t.Errorf("got %v, want a deadline exceeded error", err)
+		}
+	})
+}
+
+func TestGetCollectionRuns(t *testing.T) {
+	mDB, _ := storage.NewMemoryDB(map[string]*storage.City{})
+	s := newServer(failingDB{mDB})
+
+	t.Run("return an internal error when the runs cannot be read", func(t *testing.T) {
+		runs, err := s.GetCollectionRuns(context.Background(), &mlspb.CollectionRunsRequest{Limit: 1})
+		if runs != nil || status.Code(err) != codes.Internal {
+			t.Errorf("got %v, %v, want an internal error", runs, err)
+		}
+	})
+
+	t.Run("return the context error of a cancelled request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := s.GetCollectionRuns(ctx, &mlspb.CollectionRunsRequest{}); status.Code(err) != codes.Canceled {
+			t.Errorf("got %v, want a cancelled error", err)
+		}
+	})
+}
@@ -10,4 +10,5 @@ func ConfigRoutes(s *server.NewServer) {
 	s.Routes.RegisterRoute("/index", &controllers.Index{})
 	s.Routes.RegisterRoute("/hello", &controllers.Hello{})
 	s.Routes.RegisterRoute("/listings", &controllers.Listing{})
+	s.Routes.RegisterRoute("/status", &controllers.Status{})
 }
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"net/http"
 
-	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/golang/protobuf/proto"
 	"github.com/tony-yang/realtor-tracker/webmvc/base"
 	"github.com/tony-yang/realtor-tracker/webmvc/models"
 )
@@ -18,15 +18,20 @@ type Listing struct {
 func (l *Listing) Get(subpath string, queries map[string]string) *base.HttpResponse {
 	fmt.Println("subpath =", subpath, "queries =", queries)
 	statusCode := http.StatusOK
-	var bodyContent *mlspb.Listings
+	var bodyContent proto.Message
 	var err error
 
 	if subpath != "" {
-		// bodyContent, err = l.ReadListing(subpath)
-		fmt.Println("given subpath query individual")
+		bodyContent, err = l.ReadListing(subpath)
 	} else {
 		bodyContent, err = l.ReadAllListings()
 	}
+	if err == models.ErrNotFound {
+		return &base.HttpResponse{
+			Body:       fmt.Sprintf("listing %s not found", subpath),
+			StatusCode: http.StatusNotFound,
+		}
+	}
 	if err != nil {
 		base.Error("error fetch mls listing:", err)
 		statusCode = http.StatusInternalServerError
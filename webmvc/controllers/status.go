@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/webmvc/base"
+	"github.com/tony-yang/realtor-tracker/webmvc/models"
+)
+
+const defaultRunLimit = 20
+
+var statusPage = template.Must(template.New("status").Funcs(template.FuncMap{
+	"time": func(ts int64) string {
+		if ts == 0 {
+			return "-"
+		}
+		return time.Unix(ts, 0).Format("2006-01-02 15:04:05")
+	},
+	"duration": func(r *mlspb.CollectionRun) string {
+		if r.EndedAt == 0 {
+			return "-"
+		}
+		return (time.Duration(r.EndedAt-r.StartedAt) * time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><title>Collection Runs</title></head>
+<body>
+<h1>Collection Runs</h1>
+<table border="1">
+<tr><th>Source</th><th>Regions</th><th>Started</th><th>Duration</th><th>Pages</th><th>HTTP Errors</th><th>New</th><th>Updated</th><th>Delisted</th><th>Error</th></tr>
+{{range .Run}}<tr>
+<td>{{.Source}}</td><td>{{range $i, $r := .Region}}{{if $i}}, {{end}}{{$r}}{{end}}</td><td>{{time .StartedAt}}</td><td>{{duration .}}</td>
+<td>{{.Pages}}</td><td>{{.HttpErrors}}</td><td>{{.NewListings}}</td><td>{{.UpdatedListings}}</td><td>{{.DelistedListings}}</td><td>{{.Error}}</td>
+</tr>
+{{else}}<tr><td colspan="10">No collection run recorded</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Status shows the most recent collector runs, the limit query sets how many.
+// It fails with a 500 when the indexer cannot read the runs, rather than show
+// that no run was recorded.
+type Status struct {
+	base.Controller
+	models.Status
+}
+
+func (s *Status) Get(subpath string, queries map[string]string) *base.HttpResponse {
+	limit := defaultRunLimit
+	if val, ok := queries["limit"]; ok {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	runs, err := s.ReadCollectionRuns(limit)
+	if err != nil {
+		base.Error("error fetch collection runs:", err)
+		return &base.HttpResponse{
+			Body:       "Failed to read the collection runs",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+
+	var body bytes.Buffer
+	if err := statusPage.Execute(&body, runs); err != nil {
+		base.Error("error render collection runs:", err)
+		return &base.HttpResponse{
+			Body:       "Failed to render the collection runs",
+			StatusCode: http.StatusInternalServerError,
+		}
+	}
+	return &base.HttpResponse{
+		Body:       body.String(),
+		StatusCode: http.StatusOK,
+	}
+}
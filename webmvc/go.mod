@@ -1,8 +1,9 @@
 module github.com/tony-yang/realtor-tracker/webmvc
 
-go 1.12
+go 1.13
 
 require (
+	github.com/golang/protobuf v1.3.2
 	github.com/mattn/go-sqlite3 v1.11.0
 	github.com/sirupsen/logrus v1.4.2
 	github.com/tony-yang/realtor-tracker v0.0.0-20191103202039-112bb5d366c7
@@ -10,3 +11,5 @@ require (
 	google.golang.org/grpc v1.25.0
 	honnef.co/go/tools v0.0.0-20190523083050-ea95bdfd59fc // indirect
 )
+
+replace github.com/tony-yang/realtor-tracker => ../
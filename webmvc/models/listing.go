@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,6 +10,8 @@ import (
 	"github.com/tony-yang/realtor-tracker/webmvc/base"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type Listing struct {
@@ -28,31 +31,35 @@ type Listing struct {
 	ListTimestamp string
 }
 
-func (l *Listing) ReadListing(mlsNumber string) (map[string]Listing, error) {
+// ErrNotFound is returned when the listing read is not collected.
+var ErrNotFound = errors.New("listing not found")
+
+// ReadListing reads a listing with its photos, price and status history.
+func (l *Listing) ReadListing(mlsNumber string) (*mlspb.Property, error) {
 	base.Debug("reading listing: mlsNumber =", mlsNumber)
-	listings := make(map[string]Listing)
-	listings["19016318"] = Listing{
-		Address:       "1234 street|city, province A0B1C2",
-		Bathrooms:     "1",
-		Bedrooms:      "3 + 0",
-		LandSize:      "0X",
-		MlsId:         "1234",
-		MlsNumber:     "19016318",
-		MlsUrl:        "/abc/20552312/house",
-		Parking:       "None",
-		PhotoUrl:      "https://picture/listings/high/456.jpg",
-		Price:         "$10,000",
-		PublicRemarks: "HOUSE DESCRIPTION",
-		Stories:       "1.5",
-		PropertyType:  "House",
-		ListTimestamp: "123456789",
+	addr := "127.0.0.1:9000"
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
 	}
+	defer conn.Close()
+	c := mlspb.NewMlsServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	if property, ok := listings[mlsNumber]; ok {
-		return map[string]Listing{mlsNumber: property}, nil
-	} else {
-		return listings, nil
+	request := &mlspb.ListingRequest{MlsNumber: mlsNumber}
+	listing, err := c.GetListingByMlsNumber(ctx, request)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrNotFound
 	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read listing %s: %v", mlsNumber, err)
+	}
+
+	return listing, nil
 }
 
 func (l *Listing) ReadAllListings() (*mlspb.Listings, error) {
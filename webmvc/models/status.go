@@ -0,0 +1,39 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	mlspb "github.com/tony-yang/realtor-tracker/indexer/mls"
+	"github.com/tony-yang/realtor-tracker/webmvc/base"
+
+	"google.golang.org/grpc"
+)
+
+type Status struct{}
+
+// ReadCollectionRuns reads the limit most recent collector runs.
+func (s *Status) ReadCollectionRuns(limit int) (*mlspb.CollectionRuns, error) {
+	base.Debug("reading collection runs: limit =", limit)
+	addr := "127.0.0.1:9000"
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	c := mlspb.NewMlsServiceClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	request := &mlspb.CollectionRunsRequest{Limit: int32(limit)}
+	runs, err := c.GetCollectionRuns(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read the collection runs: %v", err)
+	}
+
+	return runs, nil
+}